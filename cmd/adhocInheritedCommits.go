@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/sorter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// adhocInheritedCommitsCmd represents the adhocInheritedCommits command
+var adhocInheritedCommitsCmd = &cobra.Command{
+	Use:   "adhoc-inherited-commits",
+	Short: "Runs the inherited commits function ad-hoc",
+	Long: `Run an ad-hoc iteration of the inherited commits function.
+
+This finds commits whose SHA is in more than one repo, keeps the copy in the repo it most likely originated in, and flags the others as inherited so they're left out of analytics.
+A repo is ranked by how many tracked forks it is away from the root of its history, then by when it was created on GitHub, then by its first commit.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		// Run ad-hoc
+		sorter.RunInheritedCommits(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adhocInheritedCommitsCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/enrich"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// adhocRepoMetadataCmd represents the adhocRepoMetadata command
+var adhocRepoMetadataCmd = &cobra.Command{
+	Use:   "adhoc-repo-metadata",
+	Short: "Runs the repo metadata enrichment ad-hoc",
+	Long: `Run an ad-hoc iteration of the repo metadata enrichment.
+
+This looks up the GitHub attributes (stars, forks, topics, language, archived status, etc.) of every repo in the repos table,
+updates the repo_metadata table, and records today's snapshot in the repo_snapshots table.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Init github package with auth tokens from flags
+		initGitHub()
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		// Run ad-hoc
+		enrich.RunRepoMetadata(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adhocRepoMetadataCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/delivery"
+	"github.com/chia-network/ecosystem-activity/internal/report"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// adhocReportDeliveryCmd represents the adhocReportDelivery command
+var adhocReportDeliveryCmd = &cobra.Command{
+	Use:   "adhoc-report-delivery",
+	Short: "Runs the report delivery ad-hoc",
+	Long: `Run an ad-hoc iteration of the report delivery.
+
+This emails a month's report to the recipients in the config file and posts it to the --report-webhook-url, along with
+retrying any earlier deliveries that failed. Each delivery is recorded in the report_deliveries table, and recipients who
+were already sent the month's report aren't sent it again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		periodFlag, _ := cmd.Flags().GetString("period")
+		period, err := report.ParsePeriod(periodFlag, time.Now().UTC())
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		opts := deliveryOptions()
+		if !opts.Enabled() {
+			log.Fatalln("no report email recipients or webhook are configured")
+		}
+
+		// Init db package
+		err = db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		// Run ad-hoc
+		delivery.Run(cmd.Context(), period, opts)
+	},
+}
+
+func init() {
+	adhocReportDeliveryCmd.Flags().String("period", "", "The month to deliver the report for in YYYY-MM format (default: the last full month)")
+	rootCmd.AddCommand(adhocReportDeliveryCmd)
+}
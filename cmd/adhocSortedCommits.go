@@ -23,7 +23,7 @@ This deletes the rows in the sorted_commits table, gets a list of commits from t
 		}
 
 		// Run ad-hoc
-		sorter.RunSortedCommits()
+		sorter.RunSortedCommits(cmd.Context())
 	},
 }
 
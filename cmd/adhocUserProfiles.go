@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/enrich"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// adhocUserProfilesCmd represents the adhocUserProfiles command
+var adhocUserProfilesCmd = &cobra.Command{
+	Use:   "adhoc-user-profiles",
+	Short: "Runs the user profile enrichment ad-hoc",
+	Long: `Run an ad-hoc iteration of the user profile enrichment.
+
+This looks up the GitHub profile (name, company, location, account creation date, account type) and public organization memberships
+of every user whose profile is missing or more than a week old, updates the user_profiles and user_orgs tables, and classifies every
+user as internal, partner, or community by the affiliations rules in the config file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Init github package with auth tokens from flags
+		initGitHub()
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		// Run ad-hoc
+		enrich.RunUserProfiles(cmd.Context(), cfg.Affiliations)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adhocUserProfilesCmd)
+}
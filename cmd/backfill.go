@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"database/sql"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/chia-network/ecosystem-activity/internal/db"
-	gh "github.com/chia-network/ecosystem-activity/internal/github"
 )
 
 // backfillCmd represents the backfill command
@@ -14,8 +16,10 @@ var backfillCmd = &cobra.Command{
 	Use:   "backfill",
 	Short: "Fills missing data for repos and users",
 	Run: func(cmd *cobra.Command, args []string) {
-		// Init github package with auth token
-		gh.Init(viper.GetString("github-token"))
+		ctx := cmd.Context()
+
+		// Init github package with auth tokens from flags
+		initGitHub()
 
 		// Init db package
 		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
@@ -23,7 +27,7 @@ var backfillCmd = &cobra.Command{
 			log.Error(err)
 		}
 
-		rows, err := db.Query("select id, owner, repo from repos where first_commit IS NULL or last_commit IS NULL")
+		rows, err := db.QueryContext(ctx, "select id, owner, repo from repos where first_commit IS NULL or last_commit IS NULL")
 		if err != nil {
 			log.Fatalf("Error querying repos: %s\n", err.Error())
 		}
@@ -39,13 +43,13 @@ var backfillCmd = &cobra.Command{
 
 			log.Printf("Looking up data on repo %s/%s\n", owner, repo)
 
-			firstCommit := getFirstCommit(owner, repo)
-			lastCommit := getLastCommit(owner, repo)
+			firstCommit := getFirstCommit(ctx, owner, repo)
+			lastCommit := getLastCommit(ctx, owner, repo)
 
 			if firstCommit == "" || lastCommit == "" {
 				continue
 			}
-			_, err = db.Exec("Update repos set first_commit = ?, last_commit = ? where id = ?", firstCommit, lastCommit, id)
+			_, err = db.ExecContext(ctx, "Update repos set first_commit = ?, last_commit = ? where id = ?", firstCommit, lastCommit, id)
 			if err != nil {
 				log.Fatalf("Error updating record for %s/%s: %s\n", owner, repo, err.Error())
 			}
@@ -55,7 +59,7 @@ var backfillCmd = &cobra.Command{
 			log.Errorf("error closing sql rows: %v", err)
 		}
 
-		rows, err = db.Query("select id, username from users where first_commit IS NULL or last_commit IS NULL")
+		rows, err = db.QueryContext(ctx, "select id, username from users where first_commit IS NULL or last_commit IS NULL")
 		if err != nil {
 			log.Fatalf("Error querying repos: %s\n", err.Error())
 		}
@@ -69,9 +73,9 @@ var backfillCmd = &cobra.Command{
 			}
 
 			log.Printf("Looking up data on user %s\n", username)
-			firstCommit := getFirstCommitForUser(id)
-			lastCommit := getLastCommitForUser(id)
-			_, err = db.Exec("Update users set first_commit = ?, last_commit = ? where id = ?", firstCommit, lastCommit, id)
+			firstCommit := getFirstCommitForUser(ctx, id)
+			lastCommit := getLastCommitForUser(ctx, id)
+			_, err = db.ExecContext(ctx, "Update users set first_commit = ?, last_commit = ? where id = ?", firstCommit, lastCommit, id)
 			if err != nil {
 				log.Fatalf("Error updating record for %s: %s\n", username, err.Error())
 			}
@@ -88,13 +92,13 @@ func init() {
 }
 
 // Gets the first commit based on commit data in this DB
-func getFirstCommit(owner, repo string) string {
-	repoID, err := getRepoID(owner, repo)
+func getFirstCommit(ctx context.Context, owner, repo string) string {
+	repoID, err := getRepoID(ctx, owner, repo)
 	if err != nil {
 		return ""
 	}
 
-	rows, err := db.Query("select date from commits where repo_id = ? order by date asc limit 1", repoID)
+	rows, err := db.QueryContext(ctx, "select date from commits where repo_id = ? order by date asc limit 1", repoID)
 	if err != nil {
 		return ""
 	}
@@ -108,13 +112,13 @@ func getFirstCommit(owner, repo string) string {
 }
 
 // Gets the last commit based on commit data in this DB
-func getLastCommit(owner, repo string) string {
-	repoID, err := getRepoID(owner, repo)
+func getLastCommit(ctx context.Context, owner, repo string) string {
+	repoID, err := getRepoID(ctx, owner, repo)
 	if err != nil {
 		return ""
 	}
 
-	rows, err := db.Query("select date from commits where repo_id = ? order by date desc limit 1", repoID)
+	rows, err := db.QueryContext(ctx, "select date from commits where repo_id = ? order by date desc limit 1", repoID)
 	if err != nil {
 		return ""
 	}
@@ -128,8 +132,8 @@ func getLastCommit(owner, repo string) string {
 }
 
 // Gets the first commit based on commit data in this DB
-func getFirstCommitForUser(id int) string {
-	rows, err := db.Query("select date from commits where user_id = ? order by date asc limit 1", id)
+func getFirstCommitForUser(ctx context.Context, id int) string {
+	rows, err := db.QueryContext(ctx, "select date from commits where user_id = ? order by date asc limit 1", id)
 	if err != nil {
 		return ""
 	}
@@ -143,8 +147,8 @@ func getFirstCommitForUser(id int) string {
 }
 
 // Gets the last commit based on commit data in this DB
-func getLastCommitForUser(id int) string {
-	rows, err := db.Query("select date from commits where user_id = ? order by date desc limit 1", id)
+func getLastCommitForUser(ctx context.Context, id int) string {
+	rows, err := db.QueryContext(ctx, "select date from commits where user_id = ? order by date desc limit 1", id)
 	if err != nil {
 		return ""
 	}
@@ -156,3 +160,25 @@ func getLastCommitForUser(id int) string {
 	}
 	return date
 }
+
+func getRepoID(ctx context.Context, owner, repo string) (int, error) {
+	var id int
+	result, err := db.QueryContext(ctx, "select id from repos where owner = ? and repo = ?", owner, repo)
+	if err != nil {
+		return 0, err
+	}
+	defer func(*sql.Rows) {
+		err := result.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(result)
+
+	result.Next()
+	err = result.Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
@@ -27,6 +27,8 @@ var deleteBotRowsCmd = &cobra.Command{
 
 This uses the bot matching utility functions to discover bots in the prod dataset, and deletes bot related data as it's irrelevant to developer metrics.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
 		// Format start date string to time
 		var err error
 		startDateTime, err = time.Parse("2006-01-02", startDate)
@@ -41,7 +43,7 @@ This uses the bot matching utility functions to discover bots in the prod datase
 		}
 
 		// Get all user rows that match listed bot matchers
-		botUsers, err := users.GetBotUserRows()
+		botUsers, err := users.GetBotUserRows(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -52,7 +54,7 @@ This uses the bot matching utility functions to discover bots in the prod datase
 
 		for _, u := range botUsers {
 			// Get all commit rows for the user
-			cmts, err := commits.GetAllRowsByUserID(u.ID)
+			cmts, err := commits.GetAllRowsByUserID(ctx, u.ID)
 			if err != nil {
 				log.Error(err)
 				continue
@@ -70,7 +72,7 @@ This uses the bot matching utility functions to discover bots in the prod datase
 				log.Infof("deleting commit %s for user %s, commit date %s was after start time %s", cmt.SHA, u.Username, cmt.Date.Format("2006-01-02 15:04:05"), startDateTime.Format("2006-01-02 15:04:05"))
 
 				// Delete the row in sorted_commits table
-				err = sortedcommits.DeleteRow(cmt.ID)
+				err = sortedcommits.DeleteRow(ctx, cmt.ID)
 				if err != nil {
 					log.Error(err)
 					deletedAll = false
@@ -78,7 +80,7 @@ This uses the bot matching utility functions to discover bots in the prod datase
 				}
 
 				// Delete the row in commits table
-				err = commits.DeleteRow(cmt.ID)
+				err = commits.DeleteRow(ctx, cmt.ID)
 				if err != nil {
 					log.Error(err)
 					deletedAll = false
@@ -89,7 +91,7 @@ This uses the bot matching utility functions to discover bots in the prod datase
 			// Delete the user row if all rows for the user in the commits tables were deleted
 			if deletedAll {
 				log.Infof("deleting user %s because all of their commits were deleted", u.Username)
-				err = users.DeleteRow(u.ID)
+				err = users.DeleteRow(ctx, u.ID)
 				if err != nil {
 					log.Error(err)
 				}
@@ -97,7 +99,7 @@ This uses the bot matching utility functions to discover bots in the prod datase
 		}
 
 		// Run the adhoc sorted commits function, this will usually take a while in prod, grab a cup of coffee
-		sorter.RunSortedCommits()
+		sorter.RunSortedCommits(ctx)
 	},
 }
 
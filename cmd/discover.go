@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	discoverycandidates "github.com/chia-network/ecosystem-activity/internal/db/discovery_candidates"
+	"github.com/chia-network/ecosystem-activity/internal/discovery"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Searches GitHub for repos to collect that aren't in the config file",
+	Long: `Search GitHub with the queries under discovery in the config file, and print the candidate repos found.
+
+Repository queries use GitHub's repository search syntax, ie. "topic:chialisp" or "chia in:readme", and code queries use
+GitHub's code search syntax, ie. "chia_rs language:rust". Repos that are already collected, archived, or that fall short
+of min_stars or haven't been pushed to in active_days are skipped, along with forks when exclude_forks is set.
+
+Candidates are recorded in the discovery_candidates table. New candidates wait for review with "discover approve" or
+"discover reject", unless auto_add is set, which approves them right away. The collector picks up approved candidates
+on its next pass. Searches also run on the --discovery-schedule when it's set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(cfg.Discovery.RepositoryQueries) == 0 && len(cfg.Discovery.CodeQueries) == 0 {
+			log.Fatalln("no discovery queries are configured")
+		}
+
+		// Init github package with auth tokens from flags
+		initGitHub()
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		candidates, err := discovery.Run(cmd.Context(), cfg)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		err = printCandidates(os.Stdout, candidates)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+// printCandidates writes a table of discovery candidates with their stars and last push
+func printCandidates(out io.Writer, candidates []discoverycandidates.Candidate) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REPO\tSTARS\tPUSHED\tLANGUAGE\tSTATUS\tURL")
+	for _, c := range candidates {
+		pushed := "never"
+		if !c.PushedAt.IsZero() {
+			pushed = c.PushedAt.Format("2006-01-02")
+		}
+		_, _ = fmt.Fprintf(w, "%s/%s\t%d\t%s\t%s\t%s\t%s\n", c.Owner, c.Repo, c.Stars, pushed, c.Language, c.Status, c.URL)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+}
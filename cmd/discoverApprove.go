@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	discoverycandidates "github.com/chia-network/ecosystem-activity/internal/db/discovery_candidates"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// discoverApproveCmd represents the discover approve command
+var discoverApproveCmd = &cobra.Command{
+	Use:   "approve owner/repo...",
+	Short: "Approves discovered repos for collection",
+	Long: `Approve candidates in the discovery_candidates table, so the collector collects them alongside the repos in the config file
+from its next pass on. Candidates that were rejected can be approved too.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reviewCandidates(cmd.Context(), args, discoverycandidates.StatusApproved)
+	},
+}
+
+// reviewCandidates sets the status of each candidate named in args in owner/repo format
+func reviewCandidates(ctx context.Context, args []string, status string) {
+	// Init db package
+	err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+	if err != nil {
+		log.Error(err)
+	}
+
+	now := time.Now().UTC()
+	for _, arg := range args {
+		owner, repo, ok := strings.Cut(arg, "/")
+		if !ok {
+			log.Fatalf("expected a repo in owner/repo format, got \"%s\"", arg)
+		}
+		found, err := discoverycandidates.SetStatusByName(ctx, owner, repo, status, now)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if !found {
+			log.Fatalf("%s is not a discovery candidate", arg)
+		}
+		fmt.Printf("%s: %s\n", arg, status)
+	}
+}
+
+func init() {
+	discoverCmd.AddCommand(discoverApproveCmd)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	discoverycandidates "github.com/chia-network/ecosystem-activity/internal/db/discovery_candidates"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// discoverListCmd represents the discover list command
+var discoverListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the repos found by discovery",
+	Long: `List the repos in the discovery_candidates table with their stars and last push, from the most starred to the least.
+Only candidates waiting for review are listed unless --status is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, _ := cmd.Flags().GetString("status")
+		if status == "all" {
+			status = ""
+		} else if status != discoverycandidates.StatusPending && status != discoverycandidates.StatusApproved && status != discoverycandidates.StatusRejected {
+			log.Fatalf("unsupported status \"%s\", expected one of pending, approved, rejected, or all", status)
+		}
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		candidates, err := discoverycandidates.GetRows(cmd.Context(), status)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		err = printCandidates(os.Stdout, candidates)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func init() {
+	discoverListCmd.Flags().String("status", discoverycandidates.StatusPending, "The candidates to list, one of pending, approved, rejected, or all")
+	discoverCmd.AddCommand(discoverListCmd)
+}
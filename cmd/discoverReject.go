@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	discoverycandidates "github.com/chia-network/ecosystem-activity/internal/db/discovery_candidates"
+	"github.com/spf13/cobra"
+)
+
+// discoverRejectCmd represents the discover reject command
+var discoverRejectCmd = &cobra.Command{
+	Use:   "reject owner/repo...",
+	Short: "Rejects discovered repos",
+	Long: `Reject candidates in the discovery_candidates table. Rejected candidates stay in the table so later discovery runs don't
+queue them for review again. Rejecting a candidate that was approved stops new commits being collected from it on the
+collector's next restart, and keeps the commits already collected.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reviewCandidates(cmd.Context(), args, discoverycandidates.StatusRejected)
+	},
+}
+
+func init() {
+	discoverCmd.AddCommand(discoverRejectCmd)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/export"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:       "export {commits|users|repos}",
+	Short:     "Exports commits, users, or repos to CSV, JSON Lines, or Parquet",
+	ValidArgs: []string{"commits", "users", "repos"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	Long: `Export commits, users, or repos for offline analysis.
+
+Commits are joined with their repo's owner and name and their author's username. Rows are streamed from the db as
+they're written, so exports of any size don't need to fit in memory.
+
+The --since, --until, --owner, and --repo filters narrow commits by date and repo. Users are narrowed to those who
+authored a matching commit, and repos to those matching the owner and repo which had a commit in the date range.
+Usernames matching the bot list can be included, excluded, or exported alone with --bots.`,
+	Example: `  ecosystem-activity export commits --format parquet --since 2026-01-01 --owner Chia-Network --output commits.parquet
+  ecosystem-activity export users --bots exclude --format jsonl`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		format, _ := cmd.Flags().GetString("format")
+		owner, _ := cmd.Flags().GetString("owner")
+		repo, _ := cmd.Flags().GetString("repo")
+		bots, _ := cmd.Flags().GetString("bots")
+		opts := export.Options{
+			Filter: commits.Filter{Owner: owner, Repo: repo},
+			Bots:   bots,
+		}
+		err := opts.Validate()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			opts.Filter.Since, err = time.Parse("2006-01-02", since)
+			if err != nil {
+				log.Fatalf("error parsing --since, expected YYYY-MM-DD: %v", err)
+			}
+		}
+		if until, _ := cmd.Flags().GetString("until"); until != "" {
+			u, err := time.Parse("2006-01-02", until)
+			if err != nil {
+				log.Fatalf("error parsing --until, expected YYYY-MM-DD: %v", err)
+			}
+			// --until is inclusive, the filter isn't
+			opts.Filter.Until = u.AddDate(0, 0, 1)
+		}
+
+		// Init db package
+		err = db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		var w io.Writer = os.Stdout
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				log.Fatalf("error creating export file: %v", err)
+			}
+			defer func(f *os.File) {
+				err := f.Close()
+				if err != nil {
+					log.Errorf("error closing export file: %v", err)
+				}
+			}(f)
+			w = f
+		}
+
+		var n int
+		switch args[0] {
+		case "commits":
+			n, err = export.Commits(ctx, w, format, opts)
+		case "users":
+			n, err = export.Users(ctx, w, format, opts)
+		case "repos":
+			n, err = export.Repos(ctx, w, format, opts)
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if output != "" {
+			log.Infof("Exported %d %s to %s", n, args[0], output)
+		}
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("format", export.FormatCSV, fmt.Sprintf("Output format, one of %s, %s, or %s", export.FormatCSV, export.FormatJSONL, export.FormatParquet))
+	exportCmd.Flags().String("output", "", "Write the export to a file instead of stdout")
+	exportCmd.Flags().String("since", "", "Only export commits on or after this date, in YYYY-MM-DD format")
+	exportCmd.Flags().String("until", "", "Only export commits on or before this date, in YYYY-MM-DD format")
+	exportCmd.Flags().String("owner", "", "Only export commits to repos with this owner")
+	exportCmd.Flags().String("repo", "", "Only export commits to repos with this name")
+	exportCmd.Flags().String("bots", export.BotsInclude, "Whether to include, exclude, or only export usernames matching the bot list")
+	rootCmd.AddCommand(exportCmd)
+}
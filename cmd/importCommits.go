@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"database/sql"
-	"encoding/csv"
 	"os"
 
 	log "github.com/sirupsen/logrus"
@@ -10,7 +8,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/chia-network/ecosystem-activity/internal/db"
-	"github.com/chia-network/ecosystem-activity/internal/utils"
+	"github.com/chia-network/ecosystem-activity/internal/importer"
 )
 
 var (
@@ -20,15 +18,31 @@ var (
 // importCommitsCmd represents the importCommits command
 var importCommitsCmd = &cobra.Command{
 	Use:   "import-commits",
-	Short: "Imports commits from a CSV generated by the old reporting tool",
-	Long: `Imports commits from a CSV generated by the old reporting tool.
-
-This importer expects that the repos and users tables already have data, and the importer will look up repo and user 
-IDs from that table based on the Owner and Repository fields in the CSV.
-
-CSV is expected to have the following fields:
-Owner,Repository,Commit Author, Commit SHA, Commit Date`,
+	Short: "Imports commits from a CSV or JSON Lines file",
+	Long: `Imports commits from a CSV or JSON Lines file, such as a CSV generated by the old reporting tool or a commits export.
+
+Columns are detected from the CSV header or the JSON keys, which need to include the repo, username, SHA, and date of
+each commit, along with the repo owner unless repos are given as owner/repo. Common names like "Repository",
+"Commit Author", and "Commit SHA" are recognized. CSVs without a recognizable header are read in the old reporting tool's
+order: Owner,Repository,Commit Author,Commit SHA,Commit Date
+
+Commits to repos or by users that aren't in the repos and users tables are rejected unless --create-missing is set.
+Commits with invalid dates or SHAs (only full 40 or 64 character SHAs are accepted), commits that were already imported,
+and commits by bots are rejected too. Rejected
+rows don't stop the import, they're written to the reject file with their line number and the reason, and the import
+finishes with a summary.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		if format == "" {
+			format = importer.DetectFormat(file)
+		}
+		createMissing, _ := cmd.Flags().GetBool("create-missing")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		rejectFile, _ := cmd.Flags().GetString("reject-file")
+		if rejectFile == "" {
+			rejectFile = file + ".rejects.csv"
+		}
+
 		// Init db package
 		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
 		if err != nil {
@@ -47,44 +61,34 @@ Owner,Repository,Commit Author, Commit SHA, Commit Date`,
 			}
 		}(f)
 
-		csvReader := csv.NewReader(f)
-		data, err := csvReader.ReadAll()
+		rejects, err := os.Create(rejectFile)
 		if err != nil {
-			log.Fatalf("Error reading CSV: %s\n", err.Error())
+			log.Fatalf("Error creating reject file: %s\n", err.Error())
 		}
 
-		for rowNum, line := range data {
-			if rowNum == 0 {
-				continue
-			}
-			owner := line[0]
-			repo := line[1]
-			commitAuthor := line[2]
-			commitSHA := line[3]
-			commitDate := line[4]
-
-			if isPossibleBot := utils.MatchesBot(commitAuthor); isPossibleBot {
-				// Skip this commit since it matched a bot account username matcher
-				continue
-			}
-
-			repoID, err := getRepoID(owner, repo)
-			if err != nil {
-				log.Fatalf("Error getting repo %s/%s: %s\n", owner, repo, err.Error())
-			}
-
-			authorID, err := getCommitAuthorID(commitAuthor)
-			if err != nil {
-				log.Fatalf("Error getting commit author %s: %s\n", commitAuthor, err.Error())
-			}
-
-			log.Printf("%s/%s (%d) %s (%d) %s %s\n", owner, repo, repoID, commitAuthor, authorID, commitSHA, commitDate)
+		summary, err := importer.Run(cmd.Context(), f, rejects, importer.Options{
+			Format:        format,
+			CreateMissing: createMissing,
+			DryRun:        dryRun,
+		})
+		closeErr := rejects.Close()
+		if closeErr != nil {
+			log.Errorf("Error closing reject file: %s\n", closeErr.Error())
+		}
+		if err != nil {
+			log.Fatalf("Error importing %s after %s: %s\n", file, summary, err.Error())
+		}
 
-			_, err = db.Exec("INSERT INTO commits (repo_id, user_id, date, sha) VALUES (?, ?, ?, ?)", repoID, authorID, commitDate, commitSHA)
-			if err != nil {
-				log.Fatalf("Error writing to DB: %s\n", err.Error())
-			}
+		if dryRun {
+			log.Infof("Dry run, nothing was written to the db. %s", summary)
+		} else {
+			log.Infof("Finished importing %s. %s", file, summary)
+		}
+		if summary.Rejected() == 0 {
+			_ = os.Remove(rejectFile)
+			return
 		}
+		log.Infof("Wrote %d rejected rows to %s", summary.Rejected(), rejectFile)
 	},
 }
 
@@ -92,48 +96,8 @@ func init() {
 	rootCmd.AddCommand(importCommitsCmd)
 
 	importCommitsCmd.Flags().StringVar(&file, "file", "", "The file to import")
-}
-
-func getCommitAuthorID(username string) (int, error) {
-	var id int
-	result, err := db.Query("select id from users where username = ?", username)
-	if err != nil {
-		return 0, err
-	}
-	defer func(*sql.Rows) {
-		err := result.Close()
-		if err != nil {
-			log.Errorf("error closing sql rows: %v", err)
-		}
-	}(result)
-
-	result.Next()
-	err = result.Scan(&id)
-	if err != nil {
-		return 0, err
-	}
-
-	return id, nil
-}
-
-func getRepoID(owner, repo string) (int, error) {
-	var id int
-	result, err := db.Query("select id from repos where owner = ? and repo = ?", owner, repo)
-	if err != nil {
-		return 0, err
-	}
-	defer func(*sql.Rows) {
-		err := result.Close()
-		if err != nil {
-			log.Errorf("error closing sql rows: %v", err)
-		}
-	}(result)
-
-	result.Next()
-	err = result.Scan(&id)
-	if err != nil {
-		return 0, err
-	}
-
-	return id, nil
+	importCommitsCmd.Flags().String("format", "", "The format of the file, one of csv or jsonl (default: detected from the file extension)")
+	importCommitsCmd.Flags().Bool("create-missing", false, "Create repos and users that aren't in the db yet, instead of rejecting their commits")
+	importCommitsCmd.Flags().Bool("dry-run", false, "Validate the file and report what would be imported, without writing to the db")
+	importCommitsCmd.Flags().String("reject-file", "", "Where to write rows that weren't imported, as CSV (default: the file with .rejects.csv appended)")
 }
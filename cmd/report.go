@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/report"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportCmd represents the report command, which prints a monthly ecosystem summary and is the parent for the more detailed reports
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Prints a monthly summary of ecosystem activity, or one of the detailed reports",
+	Long: `Print a summary of ecosystem activity for one month, compared to the month before it.
+
+The summary includes monthly active developers, new contributors, returning contributors (who committed after going the
+retention churn_days from the config file without a commit), commits and active repos, each with its month-over-month change,
+along with the top repos by activity and the top repos whose first commit was in the month. It's generated from the commits
+and repos tables, leaving out commits forks inherited from their parents.
+
+The month defaults to the last full month. HTML reports are a single self-contained file.`,
+	Example: `  ecosystem-activity report --period 2026-09 --format html --output 2026-09.html`,
+	Run: func(cmd *cobra.Command, args []string) {
+		periodFlag, _ := cmd.Flags().GetString("period")
+		period, err := report.ParsePeriod(periodFlag, time.Now().UTC())
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		format, _ := cmd.Flags().GetString("format")
+
+		// Init db package
+		err = db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		r, err := report.Build(cmd.Context(), period, cfg.Retention.WithDefaults().ChurnDays)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		var w io.Writer = os.Stdout
+		if output, _ := cmd.Flags().GetString("output"); output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				log.Fatalf("error creating report file: %v", err)
+			}
+			defer func(f *os.File) {
+				err := f.Close()
+				if err != nil {
+					log.Errorf("error closing report file: %v", err)
+				}
+			}(f)
+			w = f
+		}
+		err = report.Render(w, r, format)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func init() {
+	reportCmd.Flags().String("period", "", "The month to report on in YYYY-MM format (default: the last full month)")
+	reportCmd.Flags().String("format", report.FormatMarkdown, "Output format, one of md, html, or json")
+	reportCmd.Flags().String("output", "", "Write the report to a file instead of stdout")
+	rootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/cohorts"
+	"github.com/chia-network/ecosystem-activity/internal/sorter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportCohortsCmd represents the report cohorts command
+var reportCohortsCmd = &cobra.Command{
+	Use:   "cohorts",
+	Short: "Prints contributor retention by cohort, or churn by month",
+	Long: `Print contributor retention by cohort from the cohort_retention table.
+
+Users are grouped into cohorts by the month of their first commit, and each row counts the users of a cohort who committed
+in the month some number of months later. With --churn, the number of developers who churned (went the configured number of
+days without a commit, 90 by default) and returned (committed again after churning) in each month is printed instead.
+The churn window can be changed under retention in the config file.
+
+The cohort_retention and churn_events tables are rebuilt on the sorter schedule, or before printing with --refresh.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "csv" && format != "json" {
+			log.Fatalf("unsupported format \"%s\", expected csv or json", format)
+		}
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+			sorter.RunCohorts(ctx, cfg.Retention)
+		}
+
+		if churn, _ := cmd.Flags().GetBool("churn"); churn {
+			rows, err := cohorts.GetMonthlyChurn(ctx)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			if rows == nil {
+				rows = []cohorts.MonthlyChurn{}
+			}
+			records := [][]string{{"month", "churned", "returned"}}
+			for _, c := range rows {
+				records = append(records, []string{c.Month.Format("2006-01"), strconv.Itoa(c.Churned), strconv.Itoa(c.Returned)})
+			}
+			err = writeReport(format, rows, records)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			return
+		}
+
+		rows, err := cohorts.GetRetention(ctx)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if rows == nil {
+			rows = []cohorts.Retention{}
+		}
+		records := [][]string{{"cohort_month", "months_since", "cohort_size", "active_users", "share"}}
+		for _, r := range rows {
+			records = append(records, []string{r.CohortMonth.Format("2006-01"), strconv.Itoa(r.MonthsSince), strconv.Itoa(r.CohortSize), strconv.Itoa(r.ActiveUsers), fmt.Sprintf("%.1f", r.Share())})
+		}
+		err = writeReport(format, rows, records)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+// writeReport prints a report to stdout, either as indented JSON of v or as CSV records
+func writeReport(format string, v any, records [][]string) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := csv.NewWriter(os.Stdout)
+	err := w.WriteAll(records)
+	if err != nil {
+		return fmt.Errorf("error writing CSV report: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	reportCohortsCmd.Flags().String("format", "csv", "Output format, one of csv or json")
+	reportCohortsCmd.Flags().Bool("churn", false, "Print the number of developers who churned and returned each month instead of cohort retention")
+	reportCohortsCmd.Flags().Bool("refresh", false, "Rebuild the cohort_retention and churn_events tables from the commits table before printing")
+	reportCmd.AddCommand(reportCohortsCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	developermonths "github.com/chia-network/ecosystem-activity/internal/db/developer_months"
+	"github.com/chia-network/ecosystem-activity/internal/sorter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportDevelopersCmd represents the report developers command
+var reportDevelopersCmd = &cobra.Command{
+	Use:   "developers",
+	Short: "Prints the number of full-time, part-time, and one-time developers per month",
+	Long: `Print the number of full-time, part-time, and one-time developers per month from the developer_months table.
+
+Each user is classified in each month by the distinct days they committed on in a rolling window ending with that month
+(84 days by default). Developers averaging 10 or more active days per 28 days are full-time, developers below that who were
+only active in one 28 day period of the window are one-time, and everyone else is part-time. The thresholds can be changed
+under developer_classification in the config file.
+
+With --tag, developers are classified the same way by their commits to repos with the tag alone, so a developer who only
+committed to the tag's repos once in the window is one-time there, whatever they did elsewhere.
+
+The developer_months table is rebuilt on the sorter schedule, or before printing with --refresh.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+			sorter.RunDeveloperMonths(ctx, cfg.DeveloperClassification)
+		}
+
+		tag, _ := cmd.Flags().GetString("tag")
+		breakdown, err := developermonths.GetMonthlyBreakdown(ctx, tag)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if breakdown == nil {
+				breakdown = []developermonths.MonthlyBreakdown{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(breakdown)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "MONTH\tFULL-TIME\tPART-TIME\tONE-TIME\tTOTAL")
+		for _, b := range breakdown {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", b.Month.Format("2006-01"), b.FullTime, b.PartTime, b.OneTime, b.Total)
+		}
+		err = w.Flush()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func init() {
+	reportDevelopersCmd.Flags().Bool("refresh", false, "Rebuild the developer_months table from the commits table before printing")
+	reportDevelopersCmd.Flags().Bool("json", false, "Print the breakdown as JSON")
+	reportDevelopersCmd.Flags().String("tag", "", "Only count developers who committed to a repo with this tag in each month, for the tag's monthly active developers")
+	reportCmd.AddCommand(reportDevelopersCmd)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	repohealth "github.com/chia-network/ecosystem-activity/internal/db/repo_health"
+	"github.com/chia-network/ecosystem-activity/internal/sorter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportHealthCmd represents the report health command
+var reportHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Prints the latest health score and bus factor of every repo",
+	Long: `Print the latest health snapshot of every repo from the repo_health table, from the lowest score to the highest.
+
+Each snapshot counts the repo's contributors in the trailing 90 and 365 days, its bus factor (the smallest number of authors
+covering half of the trailing year of commits), the days since its last commit, and the percent change in commits from the prior
+90 days to the trailing 90 days. These are combined into a score out of 100 with the weights under repo_health in the config file.
+Repos without a commit in abandoned_days (365 by default) are flagged as abandoned.
+
+The repo_health table gets a new snapshot on the sorter schedule, or before printing with --refresh.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "csv" && format != "json" {
+			log.Fatalf("unsupported format \"%s\", expected csv or json", format)
+		}
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+			sorter.RunRepoHealth(ctx, cfg.RepoHealth)
+		}
+
+		abandonedOnly, _ := cmd.Flags().GetBool("abandoned")
+		tag, _ := cmd.Flags().GetString("tag")
+		rows, err := repohealth.GetLatest(ctx, abandonedOnly, tag)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if rows == nil {
+			rows = []repohealth.RepoHealth{}
+		}
+		records := [][]string{{"repo", "snapshot_date", "score", "contributors_90d", "contributors_365d", "bus_factor", "days_since_last_commit", "commits_90d", "commit_trend", "abandoned"}}
+		for _, h := range rows {
+			records = append(records, []string{
+				fmt.Sprintf("%s/%s", h.Owner, h.Repo),
+				h.SnapshotDate.Format("2006-01-02"),
+				fmt.Sprintf("%.1f", h.Score),
+				strconv.Itoa(h.Contributors90d),
+				strconv.Itoa(h.Contributors365d),
+				strconv.Itoa(h.BusFactor),
+				strconv.Itoa(h.DaysSinceLastCommit),
+				strconv.Itoa(h.Commits90d),
+				fmt.Sprintf("%.1f", h.CommitTrend),
+				strconv.FormatBool(h.Abandoned),
+			})
+		}
+		err = writeReport(format, rows, records)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func init() {
+	reportHealthCmd.Flags().String("format", "csv", "Output format, one of csv or json")
+	reportHealthCmd.Flags().Bool("abandoned", false, "Only print repos flagged as abandoned")
+	reportHealthCmd.Flags().String("tag", "", "Only print repos with this tag")
+	reportHealthCmd.Flags().Bool("refresh", false, "Take today's repo_health snapshot from the commits table before printing")
+	reportCmd.AddCommand(reportHealthCmd)
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// reposCmd represents the repos command, a parent for commands that inspect and manage the repos table
+var reposCmd = &cobra.Command{
+	Use:   "repos",
+	Short: "Inspect and manage the repos being collected",
+}
+
+func init() {
+	rootCmd.AddCommand(reposCmd)
+}
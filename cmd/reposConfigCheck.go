@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/chia-network/ecosystem-activity/internal/collector"
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reposConfigCheckCmd represents the repos config-check command
+var reposConfigCheckCmd = &cobra.Command{
+	Use:   "config-check",
+	Short: "Lists repos in the config file that need updating",
+	Long: `List the individual repositories in the config file that need updating.
+
+An entry needs updating when the repo has been renamed or transferred on GitHub (the collector follows the new name, and
+keeps the old one in the repo_aliases table), when the repo has returned enough 404s in a row to be marked as gone,
+or when the repo has never been collected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		issues, err := collector.CheckConfig(cmd.Context(), cfg)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if len(issues) == 0 {
+			fmt.Println("No config entries need updating")
+			return
+		}
+		for _, i := range issues {
+			fmt.Printf("%s: %s\n", i.Entry, i.Reason)
+		}
+	},
+}
+
+func init() {
+	reposCmd.AddCommand(reposConfigCheckCmd)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/db/tags"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reposTagCmd represents the repos tag command
+var reposTagCmd = &cobra.Command{
+	Use:   "tag [owner/repo [tag...]]",
+	Short: "Lists, adds, and removes repo tags",
+	Long: `Tag repos with categories, ie. wallets, farming-tools, or explorers, for slicing activity by category with --tag on
+"report developers" and "report health", and the tag query parameter of the API.
+
+With no arguments every tag is listed with the number of repos it's on. With only a repo, the repo's tags are listed.
+With a repo and tags, the tags are added to the repo, or removed from it with --remove. Tag names are case-insensitive.
+
+Tags under tags in the config file are also added by the collector on each pass. Tags added here are kept when they're
+taken out of the config file, while tags from the config file that are removed here come back on the collector's next pass.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer func() {
+			err := w.Flush()
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		}()
+
+		if len(args) == 0 {
+			all, err := tags.GetAll(ctx)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			_, _ = fmt.Fprintln(w, "TAG\tREPOS")
+			for _, t := range all {
+				_, _ = fmt.Fprintf(w, "%s\t%d\n", t.Name, t.Repos)
+			}
+			return
+		}
+
+		repoRow, err := getTagRepoRow(ctx, args[0])
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if remove, _ := cmd.Flags().GetBool("remove"); remove {
+			removed, err := tags.RemoveRepoTags(ctx, repoRow.ID, args[1:])
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			log.Infof("Removed %d tags from %s/%s", removed, repoRow.Owner, repoRow.Repo)
+		} else {
+			err = tags.AddRepoTags(ctx, repoRow.ID, args[1:], tags.SourceManual)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+
+		repoTags, err := tags.GetByRepoID(ctx, repoRow.ID)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		_, _ = fmt.Fprintln(w, "TAG\tSOURCE")
+		for _, t := range repoTags {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", t.Tag, t.Source)
+		}
+	},
+}
+
+// getTagRepoRow finds the repos table row for a repo named in owner/repo format, by its current or a former name
+func getTagRepoRow(ctx context.Context, fullName string) (repos.Repo, error) {
+	owner, repo, ok := strings.Cut(fullName, "/")
+	if !ok {
+		return repos.Repo{}, fmt.Errorf("expected a repo in owner/repo format, got \"%s\"", fullName)
+	}
+	rows, err := repos.GetRowsByOwnerAndRepo(ctx, owner, repo)
+	if err != nil {
+		return repos.Repo{}, err
+	}
+	if len(rows) == 0 {
+		rows, err = repos.GetRowsByAlias(ctx, owner, repo)
+		if err != nil {
+			return repos.Repo{}, err
+		}
+	}
+	if len(rows) != 1 {
+		return repos.Repo{}, fmt.Errorf("%s is not in the repos table", fullName)
+	}
+	return rows[0], nil
+}
+
+func init() {
+	reposTagCmd.Flags().Bool("remove", false, "Remove the tags from the repo instead of adding them")
+	reposCmd.AddCommand(reposTagCmd)
+}
@@ -1,16 +1,31 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+	"unicode"
 
+	"github.com/chia-network/ecosystem-activity/internal/api"
 	"github.com/chia-network/ecosystem-activity/internal/collector"
 	"github.com/chia-network/ecosystem-activity/internal/config"
 	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/delivery"
+	"github.com/chia-network/ecosystem-activity/internal/discovery"
+	"github.com/chia-network/ecosystem-activity/internal/enrich"
 	gh "github.com/chia-network/ecosystem-activity/internal/github"
+	"github.com/chia-network/ecosystem-activity/internal/leader"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
 	"github.com/chia-network/ecosystem-activity/internal/sorter"
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,8 +38,11 @@ var rootCmd = &cobra.Command{
 	Use:   "ecosystem-activity",
 	Short: "View stats on user commit activity for a set of repos over the lifespan of those repositories.",
 	Run: func(cmd *cobra.Command, args []string) {
-		// Init github package with auth token
-		gh.Init(viper.GetString("github-token"))
+		// Export spans for collector passes, GitHub requests, and db statements when an OTLP endpoint is set
+		stopTracing := initTracing(cmd.Context())
+
+		// Init github package with auth tokens from flags
+		initGitHub()
 
 		// Init db package
 		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
@@ -32,11 +50,26 @@ var rootCmd = &cobra.Command{
 			log.Error(err)
 		}
 
-		// Run collector, the main logic loop for this data collector tool
-		go collector.Run(cfg, viper.GetInt("interval"))
-
-		// Schedule sorter for sorted_commits table
-		sorter.Schedule(viper.GetString("sorter-schedule"))
+		// Run the collector and scheduled jobs in whichever replica holds the leader lease, until the process is asked to shut down.
+		// Every replica serves the HTTP endpoints below
+		ctx := cmd.Context()
+		leaderDone := make(chan struct{})
+		if viper.GetBool("leader-election") {
+			elector, err := leader.New(leader.Options{ID: viper.GetString("leader-id"), TTL: viper.GetDuration("leader-lease-ttl")})
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("Competing for the leader lease as %s", elector.ID())
+			go func() {
+				defer close(leaderDone)
+				elector.Run(ctx, runLeader)
+			}()
+		} else {
+			go func() {
+				defer close(leaderDone)
+				runLeader(ctx)
+			}()
+		}
 
 		// Healthcheck handler
 		http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -50,19 +83,121 @@ var rootCmd = &cobra.Command{
 			}
 		})
 
-		err = http.ListenAndServe(":8080", nil)
-		if err != nil {
+		// Prometheus metrics handler
+		http.Handle("/metrics", promhttp.Handler())
+
+		// Reporting API handlers
+		api.Register(http.DefaultServeMux)
+
+		// GitHub webhook handler for commits pushed to repos already in the repos table, with the collector as a fallback. Every replica writes the pushes it receives
+		if secret := viper.GetString("github-webhook-secret"); secret != "" {
+			http.Handle("/webhooks/github", collector.WebhookHandler(secret, cfg))
+		} else {
+			log.Info("no GitHub webhook secret is set, skipping the /webhooks/github handler")
+		}
+
+		server := &http.Server{Addr: ":8080"}
+		serverErr := make(chan error, 1)
+		go func() {
+			serverErr <- server.ListenAndServe()
+		}()
+
+		select {
+		case err = <-serverErr:
 			log.Errorf("error returned from http ListenAndServe: %v", err)
+		case <-ctx.Done():
+			shutdown(viper.GetDuration("shutdown-grace-period"), server, leaderDone, stopTracing)
 		}
 	},
 }
 
+// runLeader runs the collector, the main logic loop for this data collector tool, and the scheduled jobs until ctx is cancelled,
+// then stops the crons and waits up to the shutdown grace period for the collector and any running jobs to return
+func runLeader(ctx context.Context) {
+	collectorDone := make(chan struct{})
+	go func() {
+		defer close(collectorDone)
+		collector.Run(ctx, cfg, viper.GetInt("interval"), viper.GetInt("gone-after-404s"))
+	}()
+
+	// Schedule sorter for sorted_commits table, and the developer_months, cohort_retention, churn_events, and repo_health tables computed from commits
+	crons := []*cron.Cron{sorter.Schedule(ctx, viper.GetString("sorter-schedule"), cfg)}
+
+	// Schedule repo metadata enrichment for repo_metadata and repo_snapshots tables
+	crons = append(crons, enrich.Schedule(ctx, viper.GetString("repo-metadata-schedule")))
+
+	// Schedule user profile enrichment for user_profiles and user_orgs tables
+	crons = append(crons, enrich.ScheduleUserProfiles(ctx, viper.GetString("user-profile-schedule"), cfg.Affiliations))
+
+	// Schedule monthly report delivery by email and webhook, recorded in the report_deliveries table
+	crons = append(crons, delivery.Schedule(ctx, viper.GetString("report-delivery-schedule"), deliveryOptions()))
+
+	// Schedule repo discovery for the discovery_candidates table
+	if schedule := viper.GetString("discovery-schedule"); schedule != "" {
+		crons = append(crons, discovery.Schedule(ctx, schedule, cfg))
+	}
+
+	<-ctx.Done()
+
+	// Stop returns a context that's done once any running jobs have returned, so every cron is stopped before waiting on any of them.
+	// The collector and jobs were told to stop when ctx was cancelled, and the jobs' replacing transactions are fenced on the lease,
+	// so once the grace period is over they're left to stop on their own rather than holding up the next term or the shutdown
+	var done []<-chan struct{}
+	for _, c := range crons {
+		if c != nil {
+			done = append(done, c.Stop().Done())
+		}
+	}
+	done = append(done, collectorDone)
+
+	grace := viper.GetDuration("shutdown-grace-period")
+	timeout := time.NewTimer(grace)
+	defer timeout.Stop()
+	for _, d := range done {
+		select {
+		case <-d:
+		case <-timeout.C:
+			log.Warnf("the collector and scheduled jobs didn't stop within %s, leaving them to stop on their own", grace)
+			return
+		}
+	}
+}
+
+// shutdown stops the HTTP server and waits for the leader's work to stop, giving up on whatever is still running once the grace period is over.
+// Spans that haven't been exported yet are flushed with stopTracing in whatever is left of the grace period.
+// Work in progress was already told to stop when the root command's context was cancelled
+func shutdown(grace time.Duration, server *http.Server, leaderDone <-chan struct{}, stopTracing func(context.Context) error) {
+	log.Infof("Shutting down, waiting up to %s for work in progress to stop", grace)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), grace)
+	defer cancelFunc()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		log.Errorf("error shutting down http server: %v", err)
+	}
+
+	select {
+	case <-leaderDone:
+		log.Info("Shut down cleanly")
+	case <-ctx.Done():
+		log.Warn("grace period ended before the collector and scheduled jobs stopped")
+	}
+
+	err = stopTracing(ctx)
+	if err != nil {
+		log.Errorf("error flushing spans to the OTLP endpoint: %v", err)
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands are run with a context that's cancelled on SIGINT or SIGTERM, so they can stop what they're doing and shut down gracefully.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
-		cobra.CheckErr(rootCmd.Execute())
+		cobra.CheckErr(rootCmd.ExecuteContext(ctx))
 	}
 }
 
@@ -70,9 +205,29 @@ func init() {
 	var cfgFile string
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "./config.yaml", "config file (default: ./config.yaml)")
 	rootCmd.PersistentFlags().String("log-level", "info", "How verbose the logs should be. panic, fatal, error, warn, info, debug, trace (default: info)")
-	rootCmd.PersistentFlags().String("github-token", "", "A GitHub API token")
+	rootCmd.PersistentFlags().String("log-format", "text", "The format of log lines, one of text or json. json lines carry the run_id, owner, repo, and other fields as keys")
+	rootCmd.PersistentFlags().String("otlp-endpoint", "", "An OTLP/HTTP endpoint to export traces of collector passes, GitHub requests, and db statements to, ie. http://otel-collector:4318 (default: disabled)")
+	rootCmd.PersistentFlags().String("otlp-service-name", "ecosystem-activity", "The service name traces are exported under, see the `--otlp-endpoint` flag")
+	rootCmd.PersistentFlags().StringSlice("github-token", []string{}, "A GitHub API token. May be repeated or comma separated to rotate requests between several tokens, which are named token-1, token-2, and so on in logs and metrics")
+	rootCmd.PersistentFlags().String("github-token-file", "", "A file of GitHub API tokens to add to `--github-token`, one per line")
+	rootCmd.PersistentFlags().Int64("github-app-id", 0, "The ID of a GitHub App to authenticate as, requires a private key, see the `--github-app-private-key-file` flag. `--github-token` is used for owners the app isn't installed on")
+	rootCmd.PersistentFlags().String("github-app-private-key-file", "", "The path to a PEM encoded private key for the GitHub App, see the `--github-app-id` flag")
+	rootCmd.PersistentFlags().String("github-api", "rest", "The GitHub API to collect data with, one of rest or graphql")
+	rootCmd.PersistentFlags().String("github-cache-dir", "", "A directory to cache GitHub REST API responses in, to make conditional requests that don't count against the rate limit (default: disabled)")
+	rootCmd.PersistentFlags().String("github-webhook-secret", "", "The secret GitHub webhooks are signed with. Push events are received on /webhooks/github when set (default: disabled)")
 	rootCmd.PersistentFlags().Int("interval", 60, "An integer interval duration, specified in minutes, between collector runs")
+	rootCmd.PersistentFlags().Duration("shutdown-grace-period", 30*time.Second, "How long to wait for the collector, scheduled jobs, and HTTP requests to stop after SIGINT or SIGTERM, or after losing the leader lease, before moving on anyway")
+	rootCmd.PersistentFlags().Bool("leader-election", true, "Compete with other replicas for a lease in the mysql db, so only one replica runs the collector and scheduled jobs. Disable when there's only ever one replica")
+	rootCmd.PersistentFlags().String("leader-id", "", "Identifies this replica in the leader lease, unique among replicas (default: the hostname with a random suffix)")
+	rootCmd.PersistentFlags().Duration("leader-lease-ttl", 30*time.Second, "How long the leader lease lasts without being renewed, and so how long a replica that died holding it keeps the others waiting")
+	rootCmd.PersistentFlags().Int("gone-after-404s", 3, "The number of collector runs in a row a repo must return a 404 before it's marked as gone in the repos table")
 	rootCmd.PersistentFlags().String("sorter-schedule", "0 10 * * *", "A cron schedule following the syntax of standard crons with some helpers defined by github.com/robfig/cron")
+	rootCmd.PersistentFlags().String("repo-metadata-schedule", "0 6 * * *", "A cron schedule for refreshing repo metadata (stars, forks, topics, etc.) from GitHub, following the same syntax as `--sorter-schedule`")
+	rootCmd.PersistentFlags().String("user-profile-schedule", "0 7 * * *", "A cron schedule for refreshing user profiles and organizations from GitHub and classifying user affiliations, following the same syntax as `--sorter-schedule`")
+	rootCmd.PersistentFlags().String("report-delivery-schedule", "0 9 1 * *", "A cron schedule for delivering the last full month's report to the recipients in the config file, following the same syntax as `--sorter-schedule`")
+	rootCmd.PersistentFlags().String("discovery-schedule", "", "A cron schedule for searching GitHub for repos with the discovery queries in the config file, following the same syntax as `--sorter-schedule` (default: disabled)")
+	rootCmd.PersistentFlags().String("smtp-password", "", "A password for the SMTP username in the config file, used to email reports")
+	rootCmd.PersistentFlags().String("report-webhook-url", "", "A Slack-compatible incoming webhook URL to post reports to (default: disabled)")
 	rootCmd.PersistentFlags().String("mysql-host", "", "The hostname to connect to for the mysql db")
 	rootCmd.PersistentFlags().String("mysql-database", "", "The mysql database to use")
 	rootCmd.PersistentFlags().String("mysql-user", "", "A mysql username to authenticate as, requires a password, see the `--mysql-password` flag")
@@ -84,21 +239,121 @@ func init() {
 		log.Fatalln(err.Error())
 	}
 
+	err = viper.BindPFlag("github-token-file", rootCmd.PersistentFlags().Lookup("github-token-file"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("github-app-id", rootCmd.PersistentFlags().Lookup("github-app-id"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("github-app-private-key-file", rootCmd.PersistentFlags().Lookup("github-app-private-key-file"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("github-api", rootCmd.PersistentFlags().Lookup("github-api"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("github-cache-dir", rootCmd.PersistentFlags().Lookup("github-cache-dir"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("github-webhook-secret", rootCmd.PersistentFlags().Lookup("github-webhook-secret"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
 	err = viper.BindPFlag("interval", rootCmd.PersistentFlags().Lookup("interval"))
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
 
+	err = viper.BindPFlag("shutdown-grace-period", rootCmd.PersistentFlags().Lookup("shutdown-grace-period"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("leader-election", rootCmd.PersistentFlags().Lookup("leader-election"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("leader-id", rootCmd.PersistentFlags().Lookup("leader-id"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("leader-lease-ttl", rootCmd.PersistentFlags().Lookup("leader-lease-ttl"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("gone-after-404s", rootCmd.PersistentFlags().Lookup("gone-after-404s"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
 	err = viper.BindPFlag("sorter-schedule", rootCmd.PersistentFlags().Lookup("sorter-schedule"))
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
 
+	err = viper.BindPFlag("repo-metadata-schedule", rootCmd.PersistentFlags().Lookup("repo-metadata-schedule"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("user-profile-schedule", rootCmd.PersistentFlags().Lookup("user-profile-schedule"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("report-delivery-schedule", rootCmd.PersistentFlags().Lookup("report-delivery-schedule"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("discovery-schedule", rootCmd.PersistentFlags().Lookup("discovery-schedule"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("smtp-password", rootCmd.PersistentFlags().Lookup("smtp-password"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("report-webhook-url", rootCmd.PersistentFlags().Lookup("report-webhook-url"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
 	err = viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level"))
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
 
+	err = viper.BindPFlag("log-format", rootCmd.PersistentFlags().Lookup("log-format"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("otlp-endpoint", rootCmd.PersistentFlags().Lookup("otlp-endpoint"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	err = viper.BindPFlag("otlp-service-name", rootCmd.PersistentFlags().Lookup("otlp-service-name"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
 	err = viper.BindPFlag("mysql-host", rootCmd.PersistentFlags().Lookup("mysql-host"))
 	if err != nil {
 		log.Fatalln(err.Error())
@@ -136,15 +391,26 @@ func initConfig(cfgFile string) {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
+	readErr := viper.ReadInConfig()
+
+	// Set log format for logrus before anything is logged, so every line is in the same format
+	err := logging.SetFormat(viper.GetString("log-format"))
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if readErr == nil {
 		log.Printf("Using config file: %s", viper.ConfigFileUsed())
 	}
 
 	// Unmarshal config to struct
-	err := viper.Unmarshal(&cfg)
+	err = viper.Unmarshal(&cfg, viper.DecodeHook(config.DecodeHook()))
 	if err != nil {
 		log.Fatalf("unmarshalling config file: %v", err)
 	}
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatalf("invalid config file: %v", err)
+	}
 
 	// Set log level for logrus
 	level, err := log.ParseLevel(viper.GetString("log-level"))
@@ -154,3 +420,70 @@ func initConfig(cfgFile string) {
 	log.Infof("Setting log level to: %s", viper.GetString("log-level"))
 	log.SetLevel(level)
 }
+
+// initTracing starts exporting spans to the OTLP endpoint from flags, if one is set, returning a function that flushes and stops the exporter
+func initTracing(ctx context.Context) func(context.Context) error {
+	stop, err := tracing.Init(ctx, tracing.Options{
+		Endpoint:    viper.GetString("otlp-endpoint"),
+		ServiceName: viper.GetString("otlp-service-name"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if endpoint := viper.GetString("otlp-endpoint"); endpoint != "" {
+		log.Infof("Exporting traces to %s", endpoint)
+	}
+	return stop
+}
+
+// initGitHub initializes the github package from flags, exiting if the client can't be constructed
+func initGitHub() {
+	tokens, err := githubTokens()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = gh.Init(gh.Options{
+		Tokens:            tokens,
+		API:               viper.GetString("github-api"),
+		CacheDir:          viper.GetString("github-cache-dir"),
+		AppID:             viper.GetInt64("github-app-id"),
+		AppPrivateKeyFile: viper.GetString("github-app-private-key-file"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// githubTokens gathers GitHub API tokens from the github-token flag (or env var, comma or space separated) and the github-token-file
+func githubTokens() ([]string, error) {
+	var tokens []string
+	for _, t := range viper.GetStringSlice("github-token") {
+		tokens = append(tokens, strings.FieldsFunc(t, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })...)
+	}
+
+	if file := viper.GetString("github-token-file"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading GitHub token file: %v", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			tokens = append(tokens, line)
+		}
+	}
+
+	return tokens, nil
+}
+
+// deliveryOptions assembles the report delivery settings from the config file and flags
+func deliveryOptions() delivery.Options {
+	return delivery.Options{
+		Config:       cfg.ReportDelivery,
+		SMTPPassword: viper.GetString("smtp-password"),
+		WebhookURL:   viper.GetString("report-webhook-url"),
+		ChurnDays:    cfg.Retention.WithDefaults().ChurnDays,
+	}
+}
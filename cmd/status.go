@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	collectorruns "github.com/chia-network/ecosystem-activity/internal/db/collector_runs"
+	repocollectionerrors "github.com/chia-network/ecosystem-activity/internal/db/repo_collection_errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Summarizes recent collector runs and the repos failing repeatedly",
+	Long: `Print the most recent passes of the collector from the collector_runs table, with how many repos each attempted, how many
+succeeded and failed, and how many new commits it wrote. Runs stopped part way through by a shutdown, a lost leader lease, or a crash
+are listed as interrupted.
+
+Below the runs, print the repos that failed in every one of the last --failing-runs finished runs, from the repo that has been failing
+longest, with the kind, status code, and message of its latest error from the repo_collection_errors table.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		limit, _ := cmd.Flags().GetInt("runs")
+		failingRuns, _ := cmd.Flags().GetInt("failing-runs")
+		if limit < 1 || failingRuns < 1 {
+			log.Fatalln("--runs and --failing-runs must be at least 1")
+		}
+
+		// Init db package
+		err := db.Init(viper.GetString("mysql-host"), viper.GetString("mysql-database"), viper.GetString("mysql-user"), viper.GetString("mysql-password"))
+		if err != nil {
+			log.Error(err)
+		}
+
+		runs, err := collectorruns.GetRecent(ctx, limit)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		failures, err := repocollectionerrors.GetRepeatedFailures(ctx, failingRuns)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		err = printRuns(os.Stdout, runs)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		fmt.Printf("\nRepos failing in each of the last %d finished runs:\n", failingRuns)
+		err = printRepeatedFailures(os.Stdout, failures)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func printRuns(out io.Writer, runs []collectorruns.Run) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RUN\tSTARTED\tDURATION\tSTATUS\tATTEMPTED\tSUCCEEDED\tFAILED\tCOMMITS")
+	for _, r := range runs {
+		duration := "-"
+		if !r.FinishedAt.IsZero() {
+			duration = r.FinishedAt.Sub(r.StartedAt).Round(time.Second).String()
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\n", r.ID, r.StartedAt.Format("2006-01-02 15:04:05"), duration, r.Status,
+			r.ReposAttempted, r.ReposSucceeded, r.ReposFailed, r.CommitsInserted)
+	}
+	return w.Flush()
+}
+
+func printRepeatedFailures(out io.Writer, failures []repocollectionerrors.RepeatedFailure) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REPO\tRUNS\tSINCE\tKIND\tSTATUS\tERROR")
+	for _, f := range failures {
+		status := "-"
+		if f.Last.StatusCode != 0 {
+			status = fmt.Sprint(f.Last.StatusCode)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", f.Repo, f.Runs, f.FirstFailed.Format("2006-01-02 15:04:05"), f.Last.Kind, status, truncate(f.Last.Message, 80))
+	}
+	return w.Flush()
+}
+
+// truncate shortens s to at most n runes on one line, for table cells
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+func init() {
+	statusCmd.Flags().Int("runs", 10, "The number of recent collector runs to list")
+	statusCmd.Flags().Int("failing-runs", 3, "List the repos that failed in each of this many of the most recent finished runs")
+	rootCmd.AddCommand(statusCmd)
+}
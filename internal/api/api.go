@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	developermonths "github.com/chia-network/ecosystem-activity/internal/db/developer_months"
+	repohealth "github.com/chia-network/ecosystem-activity/internal/db/repo_health"
+	"github.com/chia-network/ecosystem-activity/internal/db/tags"
+)
+
+// Register adds the HTTP API endpoints to a mux
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/developers/monthly", developersMonthly)
+	mux.HandleFunc("/api/v1/repos/health", reposHealth)
+	mux.HandleFunc("/api/v1/tags", tagList)
+}
+
+// developersMonthly responds with the number of full-time, part-time, and one-time developers per month.
+// The tag query parameter gives the breakdown of developers classified by their commits to repos with the tag alone
+func developersMonthly(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	breakdown, err := developermonths.GetMonthlyBreakdown(r.Context(), r.URL.Query().Get("tag"))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "error reading developer classifications", http.StatusInternalServerError)
+		return
+	}
+	if breakdown == nil {
+		breakdown = []developermonths.MonthlyBreakdown{}
+	}
+	writeJSON(w, breakdown)
+}
+
+// reposHealth responds with the latest health snapshot of every repo, from the lowest score to the highest.
+// The abandoned query parameter limits the response to abandoned repos, and the tag query parameter to repos with the tag
+func reposHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	abandonedOnly := r.URL.Query().Get("abandoned") == "true"
+	health, err := repohealth.GetLatest(r.Context(), abandonedOnly, r.URL.Query().Get("tag"))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "error reading repo health", http.StatusInternalServerError)
+		return
+	}
+	if health == nil {
+		health = []repohealth.RepoHealth{}
+	}
+	writeJSON(w, health)
+}
+
+// tagList responds with every tag on at least one repo, with the number of repos it's on
+func tagList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	all, err := tags.GetAll(r.Context())
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "error reading tags", http.StatusInternalServerError)
+		return
+	}
+	if all == nil {
+		all = []tags.Tag{}
+	}
+	writeJSON(w, all)
+}
+
+// writeJSON writes a value to a response as JSON
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Errorf("error writing API response: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+)
+
+// ConfigIssue is an individual repository in config that needs updating, because the repo was renamed, transferred, or deleted
+type ConfigIssue struct {
+	Entry  string // The repo URL as written in config
+	Reason string
+}
+
+// CheckConfig compares the individual repositories in config against the repos and repo_aliases tables, returning the entries that need updating
+func CheckConfig(ctx context.Context, cfg config.Config) ([]ConfigIssue, error) {
+	rows, err := repos.GetAllRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := repos.GetAllAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]string, 0, len(cfg.IndividualRepositories))
+	for _, r := range cfg.IndividualRepositories {
+		entries = append(entries, r.URL)
+	}
+	return checkConfigEntries(entries, rows, aliases), nil
+}
+
+// checkConfigEntries finds the config entries that refer to a repo by an old name, refer to a repo that's gone, or have never been collected
+func checkConfigEntries(entries []string, rows []repos.Repo, aliases []repos.Alias) []ConfigIssue {
+	byName := make(map[string]repos.Repo)
+	for _, r := range rows {
+		byName[strings.ToLower(r.Owner+"/"+r.Repo)] = r
+	}
+	byAlias := make(map[string]repos.Alias)
+	for _, a := range aliases {
+		byAlias[strings.ToLower(a.Owner+"/"+a.Repo)] = a
+	}
+
+	var issues []ConfigIssue
+	for _, entry := range entries {
+		parsedURL, err := url.Parse(entry)
+		if err != nil || parsedURL.Host != "github.com" {
+			continue
+		}
+		split := strings.Split(strings.TrimPrefix(parsedURL.Path, "/"), "/")
+		if len(split) < 2 {
+			issues = append(issues, ConfigIssue{Entry: entry, Reason: "not a repository URL"})
+			continue
+		}
+		key := strings.ToLower(split[0] + "/" + split[1])
+
+		if a, ok := byAlias[key]; ok {
+			issues = append(issues, ConfigIssue{Entry: entry, Reason: fmt.Sprintf("renamed or transferred to https://github.com/%s/%s", a.CurrentOwner, a.CurrentRepo)})
+			continue
+		}
+		r, ok := byName[key]
+		if !ok {
+			issues = append(issues, ConfigIssue{Entry: entry, Reason: "not collected yet, or returned a 404 before it was ever collected"})
+			continue
+		}
+		if !r.GoneAt.IsZero() {
+			issues = append(issues, ConfigIssue{Entry: entry, Reason: fmt.Sprintf("gone since %s after %d 404s in a row", r.GoneAt.Format(time.DateOnly), r.Consecutive404s)})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+)
+
+func TestCheckConfigEntries(t *testing.T) {
+	rows := []repos.Repo{
+		{ID: 1, Owner: "Chia-Network", Repo: "chia-blockchain"},
+		{ID: 2, Owner: "Chia-Network", Repo: "go-chia-libs"},
+		{ID: 3, Owner: "Chia-Network", Repo: "deleted", GoneAt: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), Consecutive404s: 3},
+	}
+	aliases := []repos.Alias{
+		{RepoID: 2, Owner: "cmmarslender", Repo: "go-chia-libs", CurrentOwner: "Chia-Network", CurrentRepo: "go-chia-libs"},
+	}
+	entries := []string{
+		"https://github.com/Chia-Network/chia-blockchain",
+		"https://github.com/chia-network/chia-blockchain",
+		"https://github.com/cmmarslender/go-chia-libs",
+		"https://github.com/Chia-Network/deleted",
+		"https://github.com/Chia-Network/new-repo",
+		"https://gitlab.com/someone/else",
+	}
+
+	result := checkConfigEntries(entries, rows, aliases)
+	expect := []ConfigIssue{
+		{Entry: "https://github.com/cmmarslender/go-chia-libs", Reason: "renamed or transferred to https://github.com/Chia-Network/go-chia-libs"},
+		{Entry: "https://github.com/Chia-Network/deleted", Reason: "gone since 2024-03-01 after 3 404s in a row"},
+		{Entry: "https://github.com/Chia-Network/new-repo", Reason: "not collected yet, or returned a 404 before it was ever collected"},
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf("Result fail. Received %+v, Expected %+v", result, expect)
+	}
+}
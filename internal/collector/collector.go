@@ -1,36 +1,84 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chia-network/ecosystem-activity/internal/config"
+	collectorruns "github.com/chia-network/ecosystem-activity/internal/db/collector_runs"
+	discoverycandidates "github.com/chia-network/ecosystem-activity/internal/db/discovery_candidates"
+	repocollectionerrors "github.com/chia-network/ecosystem-activity/internal/db/repo_collection_errors"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
 	gh "github.com/chia-network/ecosystem-activity/internal/github"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
 
+	"github.com/google/go-github/v52/github"
 	log "github.com/sirupsen/logrus"
 )
 
-// List of repos subject to commit activity reporting
-var repoList map[string]bool
+// List of repos subject to commit activity reporting, keyed by repoKey, with the collection settings for each.
+// The collector is the only writer, and holds repoListMu to write so the webhook handler can read alongside it
+var (
+	repoList   map[string]config.Repository
+	repoListMu sync.RWMutex
+)
+
+// goneAfter404s is the number of passes in a row a repo must return a 404 before it's marked as gone
+var goneAfter404s int
+
+// goneRecheckPasses is how often repos marked as gone are collected, in passes. They're skipped on the passes in between rather than spending
+// a request on a 404 every pass, and the first pass after the collector starts always checks them, so a repo that comes back is picked up again
+const goneRecheckPasses = 24
+
+// Run is the main logic loop for the collector service and accepts a config object, a resting interval duration in minutes for the collector service loop,
+// and the number of passes in a row a repo must 404 before it's marked as gone. It returns once ctx is cancelled, after the page of commits being written
+func Run(ctx context.Context, cfg config.Config, interval int, goneAfter int) {
+	goneAfter404s = goneAfter
 
-// Run is the main logic loop for the collector service and accepts a config object and a resting interval duration in minutes for the collector service loop
-func Run(cfg config.Config, interval int) {
 	// Assemble full repo list from config, querying git remote site's specified orgs for additional repositories
-	repoList = make(map[string]bool)
-	err := createRepoList(cfg)
+	repoListMu.Lock()
+	repoList = make(map[string]config.Repository)
+	repoListMu.Unlock()
+	err := createRepoList(ctx, cfg)
+	if ctx.Err() != nil {
+		log.Info("Collector stopped before the repo list was put together")
+		return
+	}
 	if err != nil {
 		log.Fatalf("couldn't put together a repo list from config: %v", err)
 	}
 
-	// This loop should continue indefinitely, being ran in its own goroutine, called from the cmd package
-	for {
-		// Loop through all repos in map, retrieving commit history
-		for repo := range repoList {
+	// This loop continues until ctx is cancelled, being ran in its own goroutine, called from the cmd package
+	for pass := 1; ; pass++ {
+		// Pick up discovered repos approved since the last pass
+		addApprovedCandidates(ctx)
+
+		// Loop through all repos in map, retrieving commit history, and record the pass in the run history.
+		// Everything logged for the pass carries its run ID, and each repo is collected in a span under the pass's span
+		passCtx, run := startRun(ctx)
+		logger := logging.FromContext(passCtx)
+		collected := make(map[int]bool)
+		gone := getGoneRepos(passCtx)
+		for _, settings := range repoList {
+			if ctx.Err() != nil {
+				run.finish(passCtx, collectorruns.StatusInterrupted)
+				logger.Info("Collector stopped part way through a pass, the remaining repos are collected on the next run")
+				return
+			}
+			repo := settings.URL
+			if !settings.IsEnabled() {
+				logger.Debugf("Skipping repo %s, which is disabled in config", repo)
+				continue
+			}
+			invalidLogger := logger.WithField(logging.FieldErrorKind, repocollectionerrors.KindInvalidURL)
 			parsedURL, err := url.Parse(repo)
 			if err != nil {
-				log.Errorf("Skipping repo \"%s\" error parsing URL: %v\n", repo, err)
+				invalidLogger.Errorf("Skipping repo \"%s\" error parsing URL: %v", repo, err)
+				run.add(passCtx, repo, repoResult{kind: repocollectionerrors.KindInvalidURL, err: err})
 				continue
 			}
 
@@ -40,42 +88,182 @@ func Run(cfg config.Config, interval int) {
 				// Extract github owner and repo from the parsed URL
 				path := parsedURL.Path
 				split := strings.Split(strings.TrimPrefix(path, "/"), "/")
-				githubRepo(split[0], split[1])
+				if len(split) < 2 || split[0] == "" || split[1] == "" {
+					invalidLogger.Errorf("Skipping repo \"%s\" which doesn't name an owner and repo", repo)
+					run.add(passCtx, repo, repoResult{kind: repocollectionerrors.KindInvalidURL, err: fmt.Errorf("%s doesn't name an owner and repo", repo)})
+					continue
+				}
+				if skipGone(gone, split[0], split[1], pass) {
+					logger.Debugf("Skipping repo %s, which is marked as gone", repo)
+					continue
+				}
+				repoCtx, span := startRepo(passCtx, split[0], split[1])
+				result := githubRepo(repoCtx, split[0], split[1], settings, collected)
+				endRepo(span, result)
+				run.add(repoCtx, fmt.Sprintf("%s/%s", split[0], split[1]), result)
 			default:
-				log.Errorf("Currently unsupported repository declared: %s", repo)
+				invalidLogger.Errorf("Currently unsupported repository declared: %s", repo)
+				run.add(passCtx, repo, repoResult{kind: repocollectionerrors.KindInvalidURL, err: fmt.Errorf("unsupported repository host %s", host)})
 				continue
 			}
 		}
+		// A shutdown during the last repo leaves it uncollected, so the pass didn't finish
+		if ctx.Err() != nil {
+			run.finish(passCtx, collectorruns.StatusInterrupted)
+			logger.Info("Collector stopped part way through a pass, the remaining repos are collected on the next run")
+			return
+		}
+		run.finish(passCtx, collectorruns.StatusFinished)
+
+		// Tag repos from config now that any new repos have rows
+		syncConfigTags(passCtx)
+
+		stats := gh.TakeCacheStats()
+		logger.Infof("GitHub response cache for this pass: %d hits, %d misses (%.1f%% hit rate)", stats.Hits, stats.Misses, stats.HitRate())
+		for _, u := range gh.TakeTokenUsage() {
+			logger.Infof("GitHub token %s made %d requests this pass, %d core requests remaining until %s", u.Name, u.Requests, u.Remaining, u.Reset.Format(time.RFC3339))
+		}
 
 		// This interval wait is 60 minutes by default and specified with the interval flag.
 		// We could tighten these intervals, though this tool makes a lot of API calls and we may run into rate limits from git remotes
 		log.Debugf("waiting %d minutes before starting the next collector interval", interval)
-		time.Sleep(time.Duration(interval) * time.Minute)
+		if !sleep(ctx, time.Duration(interval)*time.Minute) {
+			log.Info("Collector stopped between passes")
+			return
+		}
 	}
 }
 
-func createRepoList(cfg config.Config) error {
-	// Move individual repo list to a map
-	for _, repo := range cfg.IndividualRepositories {
-		repoList[repo] = true
+// sleep waits for d, returning early with false if ctx is cancelled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
 	}
+}
 
-	// Add organization repos to map
+// getGoneRepos returns the repos marked as gone, keyed by lowercased owner/repo under their current name and every alias.
+// When they can't be read, none are skipped this pass
+func getGoneRepos(ctx context.Context) map[string]bool {
+	gone := make(map[string]bool)
+	rows, err := repos.GetAllRows(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error getting repos marked as gone, checking all of them this pass: %v", err)
+		return gone
+	}
+	ids := make(map[int]bool)
+	for _, r := range rows {
+		if !r.GoneAt.IsZero() {
+			ids[r.ID] = true
+			gone[strings.ToLower(r.Owner+"/"+r.Repo)] = true
+		}
+	}
+	if len(ids) == 0 {
+		return gone
+	}
+	aliases, err := repos.GetAllAliases(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error getting repo aliases, repos marked as gone are only skipped under their current name this pass: %v", err)
+		return gone
+	}
+	for _, a := range aliases {
+		if ids[a.RepoID] {
+			gone[strings.ToLower(a.Owner+"/"+a.Repo)] = true
+		}
+	}
+	return gone
+}
+
+// skipGone reports whether a repo marked as gone is skipped on a pass, counting passes from 1, following goneRecheckPasses
+func skipGone(gone map[string]bool, owner string, repo string, pass int) bool {
+	return gone[strings.ToLower(owner+"/"+repo)] && (pass-1)%goneRecheckPasses != 0
+}
+
+// addApprovedCandidates adds the repos approved in the discovery_candidates table to the repo list. Approvals are never taken back out of the list,
+// since rejecting a candidate that was already collected leaves its commits in place
+func addApprovedCandidates(ctx context.Context) {
+	candidates, err := discoverycandidates.GetRows(ctx, discoverycandidates.StatusApproved)
+	if err != nil {
+		log.Errorf("error getting approved discovery candidates, collecting without them this pass: %v", err)
+		return
+	}
+	repoListMu.Lock()
+	defer repoListMu.Unlock()
+	for _, c := range candidates {
+		key := repoKey(c.URL)
+		if _, ok := repoList[key]; !ok {
+			log.Debugf("adding approved discovery candidate %s to repo list", c.URL)
+			repoList[key] = config.Repository{URL: c.URL}
+		}
+	}
+}
+
+func createRepoList(ctx context.Context, cfg config.Config) error {
+	orgRepos := make(map[string][]*github.Repository)
 	for _, org := range cfg.GithubOrganizations {
 		log.Debugf("adding repos from GitHub Organization %s with visibility %s to repo list", org.Name, org.Visibility)
-		repos, err := gh.ListRepositoriesByOrg(org.Name, org.Visibility)
+		repos, err := gh.ListRepositoriesByOrg(ctx, org.Name, org.Visibility)
 		if err != nil {
 			return fmt.Errorf("error getting repository list by org for %s", org.Name)
 		}
+		orgRepos[org.Name] = repos
+	}
 
-		for _, r := range repos {
-			if org.ExcludeForks && *r.Fork {
-				log.Debugf("skipping %s (FORK)", *r.HTMLURL)
-				continue
+	repoListMu.Lock()
+	defer repoListMu.Unlock()
+	for key, settings := range buildRepoList(cfg, orgRepos) {
+		repoList[key] = settings
+	}
+
+	return nil
+}
+
+// buildRepoList assembles the repo list from config and the repos listed for each configured org. Individual repository entries take precedence over
+// org repos, so an entry can override the settings of (or disable) a repo that's also in an org. Tags are the exception, an entry's tags are added to the org's tags
+func buildRepoList(cfg config.Config, orgRepos map[string][]*github.Repository) map[string]config.Repository {
+	list := make(map[string]config.Repository)
+
+	// Add organization repos to map
+	for _, org := range cfg.GithubOrganizations {
+		for _, r := range orgRepos[org.Name] {
+			switch {
+			case org.ExcludeForks && r.GetFork():
+				log.Debugf("skipping %s (FORK)", r.GetHTMLURL())
+			case org.ExcludeArchived && r.GetArchived():
+				log.Debugf("skipping %s (ARCHIVED)", r.GetHTMLURL())
+			case !org.Includes(r.GetName()):
+				log.Debugf("skipping %s (EXCLUDED)", r.GetHTMLURL())
+			default:
+				list[repoKey(r.GetHTMLURL())] = config.Repository{URL: r.GetHTMLURL(), Tags: org.Tags}
 			}
-			repoList[*r.HTMLURL] = true
 		}
 	}
 
-	return nil
+	// Move individual repo list to the map
+	for _, repo := range cfg.IndividualRepositories {
+		key := repoKey(repo.URL)
+		if orgRepo, ok := list[key]; ok && len(orgRepo.Tags) > 0 {
+			repo.Tags = append(append([]string{}, orgRepo.Tags...), repo.Tags...)
+		}
+		list[key] = repo
+	}
+
+	return list
+}
+
+// repoKey normalizes a repo URL for use as a repo list key, since the same repo can be written with different casing in config and by GitHub
+func repoKey(repoURL string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git"))
+}
+
+// lookupRepoSettings returns the settings for a repo in the repo list, and whether it's in the list
+func lookupRepoSettings(repoURL string) (config.Repository, bool) {
+	repoListMu.RLock()
+	defer repoListMu.RUnlock()
+	settings, ok := repoList[repoKey(repoURL)]
+	return settings, ok
 }
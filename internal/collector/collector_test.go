@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+)
+
+func TestBuildRepoList(t *testing.T) {
+	disabled := false
+	cfg := config.Config{
+		GithubOrganizations: []config.GithubOrganizations{{
+			Name:            "Chia-Network",
+			ExcludeForks:    true,
+			ExcludeArchived: true,
+			Exclude:         []string{"*-archive"},
+			Tags:            []string{"core"},
+		}},
+		IndividualRepositories: []config.Repository{
+			{URL: "https://github.com/chia-network/chia-blockchain", Branch: "main", Tags: []string{"node"}},
+			{URL: "https://github.com/Chia-Network/chia-dev-tools/", Enabled: &disabled},
+			{URL: "https://github.com/someone/chialisp-toolkit"},
+		},
+	}
+	orgRepo := func(name string, fork, archived bool) *github.Repository {
+		return &github.Repository{Name: github.String(name), HTMLURL: github.String("https://github.com/Chia-Network/" + name), Fork: github.Bool(fork), Archived: github.Bool(archived)}
+	}
+	orgRepos := map[string][]*github.Repository{"Chia-Network": {
+		orgRepo("chia-blockchain", false, false),
+		orgRepo("chia-dev-tools", false, false),
+		orgRepo("go-chia-libs", false, false),
+		orgRepo("forked", true, false),
+		orgRepo("old", false, true),
+		orgRepo("explorer-archive", false, false),
+	}}
+
+	list := buildRepoList(cfg, orgRepos)
+	if len(list) != 4 {
+		t.Fatalf("Result fail. Received %d repos %v, Expected 4", len(list), list)
+	}
+	// The individual entry overrides the org's settings for the same repo, except its tags are added to the org's tags
+	if r := list["https://github.com/chia-network/chia-blockchain"]; r.Branch != "main" || !slices.Equal(r.Tags, []string{"core", "node"}) {
+		t.Errorf("Result fail. Received %+v for chia-blockchain, Expected branch main with tags [core node]", r)
+	}
+	// Entries without tags of their own keep the org's tags
+	if r := list["https://github.com/chia-network/chia-dev-tools"]; !slices.Equal(r.Tags, []string{"core"}) {
+		t.Errorf("Result fail. Received %+v for chia-dev-tools, Expected the org's tags", r)
+	}
+	if !slices.Equal(cfg.GithubOrganizations[0].Tags, []string{"core"}) {
+		t.Errorf("Result fail. Received org tags %v, Expected them to be left as they were", cfg.GithubOrganizations[0].Tags)
+	}
+	if r := list["https://github.com/chia-network/chia-dev-tools"]; r.IsEnabled() {
+		t.Errorf("Result fail. Received %+v for chia-dev-tools, Expected it to be disabled", r)
+	}
+	if r := list["https://github.com/chia-network/go-chia-libs"]; r.URL != "https://github.com/Chia-Network/go-chia-libs" || len(r.Tags) != 1 || r.Tags[0] != "core" {
+		t.Errorf("Result fail. Received %+v for go-chia-libs, Expected the org's tags", r)
+	}
+	if _, ok := list["https://github.com/someone/chialisp-toolkit"]; !ok {
+		t.Errorf("Result fail. Expected the individual repo in the list")
+	}
+}
+
+func TestSkipGone(t *testing.T) {
+	gone := map[string]bool{"chia-network/deleted": true}
+	var tests = []struct {
+		repo string
+		pass int
+		skip bool
+	}{
+		// Gone repos are checked on the first pass after starting, then once every goneRecheckPasses passes
+		{"deleted", 1, false},
+		{"deleted", 2, true},
+		{"deleted", goneRecheckPasses, true},
+		{"deleted", goneRecheckPasses + 1, false},
+		{"Deleted", 3, true},
+		{"chia-blockchain", 2, false},
+	}
+	for _, tt := range tests {
+		if skip := skipGone(gone, "Chia-Network", tt.repo, tt.pass); skip != tt.skip {
+			t.Errorf("Result fail for %s on pass %d. Received %v, Expected %v", tt.repo, tt.pass, skip, tt.skip)
+		}
+	}
+}
+
+func TestSplitRepoURL(t *testing.T) {
+	var tests = []struct {
+		url   string
+		owner string
+		repo  string
+		ok    bool
+	}{
+		{"https://github.com/Chia-Network/chia-blockchain", "Chia-Network", "chia-blockchain", true},
+		{"https://github.com/Chia-Network/chia-blockchain.git/", "Chia-Network", "chia-blockchain", true},
+		{"https://github.com/Chia-Network", "", "", false},
+		{"https://gitlab.com/someone/repo", "", "", false},
+	}
+	for _, tt := range tests {
+		owner, repo, ok := splitRepoURL(tt.url)
+		if owner != tt.owner || repo != tt.repo || ok != tt.ok {
+			t.Errorf("Result fail for %s. Received %s %s %v, Expected %s %s %v", tt.url, owner, repo, ok, tt.owner, tt.repo, tt.ok)
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if !sleep(context.Background(), time.Millisecond) {
+		t.Errorf("Result fail. Received false, Expected true once the duration passed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if sleep(ctx, time.Hour) {
+		t.Errorf("Result fail. Received true, Expected false for a cancelled context")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Result fail. Received a %s sleep, Expected it to return right away", time.Since(start))
+	}
+}
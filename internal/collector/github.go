@@ -1,88 +1,328 @@
 package collector
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db"
 	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	repocollectionerrors "github.com/chia-network/ecosystem-activity/internal/db/repo_collection_errors"
 	"github.com/chia-network/ecosystem-activity/internal/db/repos"
 	"github.com/chia-network/ecosystem-activity/internal/db/users"
 	gh "github.com/chia-network/ecosystem-activity/internal/github"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
 	"github.com/chia-network/ecosystem-activity/internal/utils"
 
 	"github.com/google/go-github/v52/github"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// A github repo was identified, will query commit data using the github API
-func githubRepo(owner string, repo string) {
+// genesis is the Chia Network incorporation date, where collection starts for repos without a since date in config
+var genesis = time.Date(2017, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+// repoResult is the outcome of collecting one repo, for the collector_runs and repo_collection_errors tables
+type repoResult struct {
+	skipped    bool // Not attempted, such as a repo already collected this pass under another name, or one cut off by a shutdown
+	repoID     int  // The repos table row, zero when the repo isn't in the table
+	inserted   int  // New commits written to the commits table
+	kind       string
+	statusCode int // The status code of the GitHub response the repo failed on, if any
+	err        error
+}
+
+// failed reports whether collecting the repo failed
+func (r repoResult) failed() bool {
+	return r.err != nil
+}
+
+// collectionError tags an error collecting a repo with its kind, for errors that can't be told apart by the GitHub status code alone
+type collectionError struct {
+	kind string
+	err  error
+}
+
+func (e collectionError) Error() string {
+	return e.err.Error()
+}
+
+// dbFailure tags an error reading or writing the db while collecting a repo
+func dbFailure(err error) error {
+	if err == nil {
+		return nil
+	}
+	return collectionError{kind: repocollectionerrors.KindDatabase, err: err}
+}
+
+// errorKind classifies an error collecting a repo for the repo_collection_errors table, by its tag if it has one and otherwise by the GitHub status code
+func errorKind(err error, statusCode int) string {
+	var ce collectionError
+	if errors.As(err, &ce) {
+		return ce.kind
+	}
+	switch statusCode {
+	case 404:
+		return repocollectionerrors.KindNotFound
+	case 403, 429:
+		return repocollectionerrors.KindRateLimited
+	}
+	return repocollectionerrors.KindGitHub
+}
+
+// A github repo was identified, will query commit data using the github API, following the repo's settings from config
+// collected holds the repos table IDs already collected this pass, so a repo listed under more than one name is only collected once
+// ctx is expected to carry the owner and repo log fields
+func githubRepo(ctx context.Context, owner string, repo string, settings config.Repository, collected map[int]bool) repoResult {
 	ownerRepoString := fmt.Sprintf("%s/%s", owner, repo)
+	logger := logging.FromContext(ctx)
+	start := time.Now()
 
-	// Get the row data for this repo in the repos table (makes a new row if one does not exist)
-	repoRow, repoInTable, err := getRepoRow(owner, repo)
+	// Get the row data for this repo in the repos table, following renames and transfers (makes a new row if one does not exist)
+	repoRow, repoInTable, statusCode, err := resolveRepoRow(ctx, owner, repo)
+	if statusCode == 404 {
+		notFound(ctx, ownerRepoString, repoRow, repoInTable)
+		return repoResult{repoID: repoRow.ID, kind: repocollectionerrors.KindNotFound, statusCode: statusCode, err: fmt.Errorf("repo %s returned a 404", ownerRepoString)}
+	}
 	if err != nil {
-		log.Error(err)
-		return
+		logger.WithField(logging.FieldErrorKind, errorKind(err, statusCode)).Error(err)
+		return repoResult{repoID: repoRow.ID, kind: errorKind(err, statusCode), statusCode: statusCode, err: err}
+	}
+	if collected[repoRow.ID] {
+		logger.Debugf("Repo is %s/%s, which was already collected this pass", repoRow.Owner, repoRow.Repo)
+		return repoResult{skipped: true, repoID: repoRow.ID}
 	}
+	collected[repoRow.ID] = true
 
 	// Get search start time by checking if the repo was already searched and using the last search time/datestamp if it was, use Chia Network incorporation date as genesis if not
+	// A since date in config moves the start forward for repos whose early history shouldn't count
 	var searchStart time.Time
-	if !repoInTable {
-		searchStart = time.Date(2017, time.August, 1, 0, 0, 0, 0, time.UTC)
+	if repoRow.ImportedThrough.IsZero() {
+		searchStart = genesis
 	} else {
 		searchStart = repoRow.ImportedThrough
 	}
+	if settings.Since.After(searchStart) {
+		searchStart = settings.Since
+	}
 
 	// Search end time is always just now in UTC, but saving the timestamp here to ensure accurate timestamps in the `repos` table's `imported_through` column
 	searchEnd := time.Now().UTC()
 
-	// Query repository commits between a start and end date
-	cmts, statusCode, err := gh.ListRepositoryCommits(owner, repo, searchStart, searchEnd)
+	// Query repository commits between a start and end date, writing each page of commits to the db in its own transaction
+	var total, inserted, pages int
+	statusCode, err = gh.ListBranchCommitsByPage(ctx, repoRow.Owner, repoRow.Repo, settings.Branch, searchStart, searchEnd, func(page []*github.RepositoryCommit, last bool) error {
+		pages++
+		pageCtx := logging.WithFields(ctx, log.Fields{logging.FieldPage: pages})
+
+		// Only move `imported_through` forward with the final page, so a crash part way through a repo gets the remaining pages on the next pass
+		var importedThrough time.Time
+		if last {
+			importedThrough = searchEnd
+		}
+
+		total += len(page)
+		n, err := writeCommitPage(pageCtx, repoRow, excludeAuthors(page, settings), importedThrough)
+		inserted += n
+		if err != nil {
+			return dbFailure(err)
+		}
+		// Stop at this page when shutting down, the pages written so far are kept and the rest are collected on the next run
+		if !last {
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() != nil {
+		logger.Infof("Stopped collecting commits after %d commits to shut down", total)
+		return repoResult{skipped: true, repoID: repoRow.ID, inserted: inserted}
+	}
 	if statusCode == 404 {
-		log.Warnf("Repo %s returned a 404", ownerRepoString)
+		notFound(ctx, ownerRepoString, repoRow, true)
+		return repoResult{repoID: repoRow.ID, inserted: inserted, kind: repocollectionerrors.KindNotFound, statusCode: statusCode, err: fmt.Errorf("repo %s returned a 404", ownerRepoString)}
+	}
+	if (err == nil || errors.Is(err, gh.ErrNotModified)) && (repoRow.Consecutive404s > 0 || !repoRow.GoneAt.IsZero()) {
+		// The repo is back (or its 404s were a blip), so start counting again from zero
+		err := repos.ResetNotFoundByID(ctx, repoRow.ID)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+	if errors.Is(err, gh.ErrNotModified) {
+		// Leave `imported_through` alone so the next pass makes the same conditional request
+		logger.WithFields(logging.Since(start)).Debug("Repo is unchanged since the last pass")
+		return repoResult{repoID: repoRow.ID}
+	}
+	if err != nil {
+		logger.WithFields(logging.Since(start)).WithField(logging.FieldErrorKind, errorKind(err, statusCode)).WithError(err).Error("Failed to collect commits")
+		return repoResult{repoID: repoRow.ID, inserted: inserted, kind: errorKind(err, statusCode), statusCode: statusCode, err: err}
+	}
+
+	logger.WithFields(logging.Since(start)).Debugf("Successfully queried commits, found %d commits in %d pages", total, pages)
+	return repoResult{repoID: repoRow.ID, inserted: inserted}
+}
+
+// notFound handles a 404 from GitHub for a repo, counting it against the repo's row (if it has one) and marking the repo as gone after goneAfter404s in a row
+func notFound(ctx context.Context, ownerRepoString string, repoRow repos.Repo, repoInTable bool) {
+	logger := logging.FromContext(ctx).WithField(logging.FieldErrorKind, repocollectionerrors.KindNotFound)
+	if !repoInTable {
+		logger.Warnf("Repo %s returned a 404", ownerRepoString)
+		return
+	}
+	if !repoRow.GoneAt.IsZero() {
+		logger.Debugf("Repo %s returned a 404, it has been gone since %s", ownerRepoString, repoRow.GoneAt.Format(time.RFC3339))
 		return
 	}
+
+	updated, err := repos.IncrementNotFoundByID(ctx, repoRow.ID, goneAfter404s)
 	if err != nil {
-		log.Errorf("Failed to get commit list for %s/%s with error: %v", owner, repo, err)
+		logger.Error(err)
 		return
 	}
+	if !updated.GoneAt.IsZero() {
+		logger.Warnf("Repo %s returned a 404 for %d passes in a row, marking it as gone", ownerRepoString, updated.Consecutive404s)
+		return
+	}
+	logger.Warnf("Repo %s returned a 404 (%d in a row)", ownerRepoString, updated.Consecutive404s)
+}
 
-	// Set repo in table because it did not 404
+// resolveRepoRow finds the repos table row for a repo named in config, using GitHub's stable repo ID so a renamed or transferred repo keeps its row.
+// Rows that already have a GitHub ID are matched by name or alias without asking GitHub; otherwise the repo is looked up, its row found by ID,
+// and the row is renamed to match GitHub. A new row is made when the repo isn't in the table under any name.
+// The bool reports whether a row was found or made, and the int is the status code of the GitHub lookup (if one was made)
+func resolveRepoRow(ctx context.Context, owner string, repo string) (repos.Repo, bool, int, error) {
+	repoRow, repoInTable, err := getRepoRow(ctx, owner, repo)
+	if err != nil {
+		return repoRow, false, 0, dbFailure(err)
+	}
+	if repoInTable && repoRow.GitHubID != 0 {
+		return repoRow, true, 0, nil
+	}
 	if !repoInTable {
-		repoRow, err = setRepoRow(repos.Repo{
-			Owner: owner,
-			Repo:  repo,
+		aliased, err := repos.GetRowsByAlias(ctx, owner, repo)
+		if err != nil {
+			return repoRow, false, 0, dbFailure(err)
+		}
+		if len(aliased) == 1 {
+			return aliased[0], true, 0, nil
+		}
+	}
+
+	// GitHub redirects old names, so this finds the repo even if it has been renamed or transferred since the config was written
+	ghRepo, statusCode, err := gh.GetRepository(ctx, owner, repo)
+	if statusCode == 404 {
+		return repoRow, repoInTable, statusCode, nil
+	}
+	if err != nil {
+		return repoRow, repoInTable, statusCode, fmt.Errorf("error looking up %s/%s on GitHub: %v", owner, repo, err)
+	}
+	canonicalOwner, canonicalRepo := ghRepo.GetOwner().GetLogin(), ghRepo.GetName()
+
+	byID, err := repos.GetRowsByGitHubID(ctx, ghRepo.GetID())
+	if err != nil {
+		return repoRow, repoInTable, statusCode, dbFailure(err)
+	}
+	switch {
+	case len(byID) == 1:
+		// Already in the table under another name
+		if repoInTable && byID[0].ID != repoRow.ID {
+			logging.FromContext(ctx).Warnf("repos table rows %d (%s/%s) and %d (%s/%s) are the same GitHub repo, collecting into row %d", repoRow.ID, repoRow.Owner, repoRow.Repo, byID[0].ID, byID[0].Owner, byID[0].Repo, byID[0].ID)
+		}
+		repoRow = byID[0]
+	case repoInTable:
+		// A row from before GitHub IDs were tracked
+		err = repos.UpdateGitHubIDByID(ctx, repoRow.ID, ghRepo.GetID(), ghRepo.GetNodeID())
+		if err != nil {
+			return repoRow, true, statusCode, dbFailure(err)
+		}
+		repoRow.GitHubID, repoRow.NodeID = ghRepo.GetID(), ghRepo.GetNodeID()
+	default:
+		repoRow, err = setRepoRow(ctx, repos.Repo{
+			Owner:    canonicalOwner,
+			Repo:     canonicalRepo,
+			GitHubID: ghRepo.GetID(),
+			NodeID:   ghRepo.GetNodeID(),
 		})
 		if err != nil {
-			log.Error(err)
-			return
+			return repoRow, false, statusCode, dbFailure(err)
 		}
 	}
 
-	log.Debugf("Successfully queried commits for repo %s/%s, found %d commits", owner, repo, len(cmts))
+	if !strings.EqualFold(repoRow.Owner, canonicalOwner) || !strings.EqualFold(repoRow.Repo, canonicalRepo) {
+		logging.FromContext(ctx).Infof("Repo %s/%s is now %s/%s on GitHub, renaming its row and keeping the old name as an alias", repoRow.Owner, repoRow.Repo, canonicalOwner, canonicalRepo)
+		err = repos.Rename(ctx, repoRow.ID, canonicalOwner, canonicalRepo)
+		if err != nil {
+			return repoRow, true, statusCode, dbFailure(err)
+		}
+		repoRow.Owner, repoRow.Repo = canonicalOwner, canonicalRepo
+	}
+	// Remember an outdated name used in config, so it finds this row without a lookup next pass
+	if !strings.EqualFold(owner, canonicalOwner) || !strings.EqualFold(repo, canonicalRepo) {
+		err = repos.AddAlias(ctx, repoRow.ID, owner, repo)
+		if err != nil {
+			return repoRow, true, statusCode, dbFailure(err)
+		}
+	}
 
-	// For each commit we need to identify important data from the API response and submit it to the db
-	var latestCommit, earliestCommit time.Time
-	for _, commit := range cmts {
+	return repoRow, true, statusCode, nil
+}
+
+// writeCommitPage writes one page of commits from the API to the db in a single transaction, in a span so the time spent writing shows alongside the GitHub requests.
+// It returns the number of new commits written
+func writeCommitPage(ctx context.Context, repoRow repos.Repo, page []*github.RepositoryCommit, importedThrough time.Time) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "collector.write_page", trace.WithAttributes(attribute.Int("collector.page_size", len(page))))
+	defer span.End()
+	inserted, err := insertCommitPage(ctx, repoRow, page, importedThrough)
+	span.SetAttributes(attribute.Int("collector.commits_inserted", inserted))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return inserted, err
+}
+
+// insertCommitPage writes one page of commits for writeCommitPage.
+// Users for the page are resolved in bulk, new commits are inserted with a multi-row INSERT, and the repo's
+// first_commit, last_commit, and imported_through (if not zero) are updated alongside them.
+func insertCommitPage(ctx context.Context, repoRow repos.Repo, page []*github.RepositoryCommit, importedThrough time.Time) (int, error) {
+	ownerRepoString := fmt.Sprintf("%s/%s", repoRow.Owner, repoRow.Repo)
+	logger := logging.FromContext(ctx)
+	start := time.Now()
+
+	// For each commit we need to identify important data from the API response
+	var (
+		cmts   []pageCommit
+		shas   []string
+		inPage = make(map[string]bool)
+	)
+	for _, commit := range page {
 		commitSHA, err := getCommitSHA(commit)
 		if err != nil {
-			log.Errorf("failed to read commit sha data for %s: %v", ownerRepoString, err)
+			logger.WithError(err).Error("failed to read commit sha data")
 			continue
 		}
 		if commitSHA == "" {
-			log.Errorf("commit data was not nil but no SHA returned from API for repo %s: %v", ownerRepoString, err)
+			logger.Error("commit data was not nil but no SHA returned from API")
 			continue
 		}
 
 		// Since this tool doesn't filter out bot users itself, bot users will need to be filtered out from queries in Grafana
 		// Unfortunately filtering bot users from results in this tool would probably be prone to false positives and/or negatives
+		commitLogger := logger.WithField(logging.FieldSHA, commitSHA)
 		commitAuthorLogin, err := getCommitAuthorLogin(commit)
 		if err != nil {
-			log.Errorf("failed to read commit author login for %s, sha %s: %v", ownerRepoString, commitSHA, err)
+			commitLogger.WithError(err).Error("failed to read commit author login")
 			continue
 		}
 		if commitAuthorLogin == "" {
-			log.Errorf("commit data was not nil but no author login returned from API for repo %s, sha %s: %v", ownerRepoString, commitSHA, err)
+			commitLogger.Error("commit data was not nil but no author login returned from API")
 			continue
 		}
 		if isPossibleBot := utils.MatchesBot(commitAuthorLogin); isPossibleBot {
@@ -92,135 +332,172 @@ func githubRepo(owner string, repo string) {
 
 		commitTimestamp, err := getCommitDate(commit)
 		if err != nil {
-			log.Errorf("failed to read commit date for %s, sha %s: %v", ownerRepoString, commitSHA, err)
+			commitLogger.WithError(err).Error("failed to read commit date")
 			continue
 		}
 		if commitTimestamp.IsZero() {
-			log.Errorf("commit data was not nil but no commit timestamp returned from API for repo %s, sha %s: %v", ownerRepoString, commitSHA, err)
+			commitLogger.Error("commit data was not nil but no commit timestamp returned from API")
 			continue
 		}
 
-		// Check if commit author already exists in users table
-		userRow, ok, err := getUserRow(commitAuthorLogin)
-		if err != nil {
-			log.Error(err)
+		if inPage[commitSHA] {
 			continue
 		}
-		// If user did exist, check if commit timestamp is later than `last_commit` or earlier than `first_commit`, if so, update the row
-		if ok {
-			if userRow.FirstCommit.After(commitTimestamp) || userRow.FirstCommit.IsZero() {
-				err = users.UpdateFirstCommitByUsername(commitAuthorLogin, commitTimestamp)
-				if err != nil {
-					log.Error(err)
-				}
-			}
-			if userRow.LastCommit.Before(commitTimestamp) || userRow.LastCommit.IsZero() {
-				err = users.UpdateLastCommitByUsername(commitAuthorLogin, commitTimestamp)
-				if err != nil {
-					log.Error(err)
-				}
-			}
-		}
-		// If user did not exist, add them to users table, this commit can be the first and last
-		if !ok {
-			userRow, err = setUserRow(users.User{
-				Username:    commitAuthorLogin,
-				FirstCommit: commitTimestamp,
-				LastCommit:  commitTimestamp,
-			})
-			if err != nil {
-				log.Error(err)
-				continue
-			}
-		}
-
-		// Add commit to commits table
-		err = commits.SetNewRecord(commits.Commit{
-			RepoID: repoRow.ID,
-			UserID: userRow.ID,
-			Date:   commitTimestamp,
-			SHA:    commitSHA,
+		inPage[commitSHA] = true
+		shas = append(shas, commitSHA)
+		cmts = append(cmts, pageCommit{
+			login: commitAuthorLogin,
+			commit: commits.Commit{
+				RepoID: repoRow.ID,
+				Date:   commitTimestamp,
+				SHA:    commitSHA,
+			},
 		})
-		if err != nil {
-			log.Errorf("error encountered submitting commit record to commits table: %v", err)
-			continue
-		}
+	}
 
-		// Check if earliest commit or latest commit from this batch of commits
-		if earliestCommit.IsZero() || earliestCommit.After(commitTimestamp) {
-			earliestCommit = commitTimestamp
+	// A page that's been started is written even when ctx is cancelled, so a shutdown leaves the repo on a page boundary
+	tx, err := db.BeginTx(context.WithoutCancel(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction for %s: %v", ownerRepoString, err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			logger.Errorf("error rolling back transaction for %s: %v", ownerRepoString, err)
 		}
-		if latestCommit.IsZero() || latestCommit.Before(commitTimestamp) {
-			latestCommit = commitTimestamp
+	}(tx)
+
+	// Skip commits that are already in the commits table, which happens when a previous pass stopped part way through this repo.
+	// Webhooks on any replica, the importer, and the collector can all be writing the same commits at once, so a commit written after this check
+	// is left out of the insert by the commits table's unique index on repo and SHA instead
+	existing, err := commits.GetExistingSHAsByRepoID(ctx, tx, repoRow.ID, shas)
+	if err != nil {
+		return 0, err
+	}
+	var newCmts []pageCommit
+	var newSHAs []string
+	for _, c := range cmts {
+		if !existing[c.commit.SHA] {
+			newCmts = append(newCmts, c)
+			newSHAs = append(newSHAs, c.commit.SHA)
 		}
 	}
 
-	// Update repos row. If earliest commit is earlier than `first_commit` or `first_commit` is empty, set to this commit's timestamp.
-	// If latest commit is later than `last_commit` or `last_commit` is empty, set to this commit's timestamp
-	if !earliestCommit.IsZero() {
-		if repoRow.FirstCommit.After(earliestCommit) || repoRow.FirstCommit.IsZero() {
-			log.Debugf("setting first commit for repo %s/%s. current first commit %v. new first commit %v.", repoRow.Owner, repoRow.Repo, repoRow.FirstCommit, earliestCommit)
-			err = repos.UpdateFirstCommitByID(repoRow.ID, earliestCommit)
-			if err != nil {
-				log.Error(err)
-			}
-		}
+	// Flag commits that are already in another tracked repo as inherited, such as the history a fork carries from upstream.
+	// The sorter settles which copy is the original once both repos' lineage is known
+	inherited, err := commits.GetSHAsInOtherRepos(ctx, tx, repoRow.ID, newSHAs)
+	if err != nil {
+		return 0, err
 	}
-	if !latestCommit.IsZero() {
-		if latestCommit.After(repoRow.LastCommit) || repoRow.LastCommit.IsZero() {
-			log.Debugf("setting last commit for repo %s/%s. current last commit %v. new last commit %v.", repoRow.Owner, repoRow.Repo, repoRow.LastCommit, latestCommit)
-			err = repos.UpdateLastCommitByID(repoRow.ID, latestCommit)
-			if err != nil {
-				log.Error(err)
-			}
-		}
+	for i := range newCmts {
+		newCmts[i].commit.Inherited = inherited[newCmts[i].commit.SHA]
 	}
 
-	// Update repo's `imported_through` column as we finished importing these commits
-	err = repos.UpdateImportedThroughByID(repoRow.ID, searchEnd)
+	// Add or widen the commit range of every author in this page, then look up all of their IDs at once
+	userRanges := getUserCommitRanges(newCmts)
+	err = users.UpsertCommitRanges(ctx, tx, userRanges)
 	if err != nil {
-		log.Error(err)
-		return
+		return 0, err
 	}
-}
+	usernames := make([]string, 0, len(userRanges))
+	for _, u := range userRanges {
+		usernames = append(usernames, u.Username)
+	}
+	userIDs, err := users.GetIDsByUsernames(ctx, tx, usernames)
+	if err != nil {
+		return 0, err
+	}
+
+	// Add commits to commits table, tracking the earliest and latest commit from this batch of commits
+	var latestCommit, earliestCommit time.Time
+	rows := make([]commits.Commit, 0, len(newCmts))
+	for _, c := range newCmts {
+		userID, ok := userIDs[strings.ToLower(c.login)]
+		if !ok {
+			return 0, fmt.Errorf("user %s was not found in users table after upserting for repo %s", c.login, ownerRepoString)
+		}
+		c.commit.UserID = userID
+		rows = append(rows, c.commit)
 
-// getUserRow looks up a user by username in the users table.
-// Returns the user row object, a boolean value to signal if a single user was found in the table, and an optional error
-func getUserRow(u string) (users.User, bool, error) {
-	var userRow users.User
-	rows, err := users.GetRowsByUsername(u)
+		if earliestCommit.IsZero() || earliestCommit.After(c.commit.Date) {
+			earliestCommit = c.commit.Date
+		}
+		if latestCommit.IsZero() || latestCommit.Before(c.commit.Date) {
+			latestCommit = c.commit.Date
+		}
+	}
+	inserted, err := commits.SetNewRecords(ctx, tx, rows)
 	if err != nil {
-		return userRow, false, err
+		return 0, err
 	}
 
-	if len(rows) > 1 {
-		return userRow, false, fmt.Errorf("multiple rows found for user %s -- this would signify an unexpected condition, please check users table", u)
+	// Widen the repo's `first_commit` and `last_commit` to this batch, and move `imported_through` if this is the final page
+	err = repos.UpdateCommitRangeByID(ctx, tx, repoRow.ID, earliestCommit, latestCommit, importedThrough)
+	if err != nil {
+		return 0, err
 	}
 
-	if len(rows) == 0 {
-		return userRow, false, nil
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error committing transaction for %s: %v", ownerRepoString, err)
 	}
 
-	return rows[0], true, nil
+	logger.WithFields(logging.Since(start)).Debugf("wrote %d new commits from a page of %d", inserted, len(page))
+	return inserted, nil
 }
 
-func setUserRow(u users.User) (users.User, error) {
-	err := users.SetNewRecord(u)
-	if err != nil {
-		return users.User{}, err
+// excludeAuthors drops the commits by authors excluded from a repo in config
+func excludeAuthors(page []*github.RepositoryCommit, settings config.Repository) []*github.RepositoryCommit {
+	if len(settings.ExcludeAuthors) == 0 {
+		return page
 	}
-
-	userRow, _, err := getUserRow(u.Username)
-	if err != nil {
-		return users.User{}, err
+	kept := make([]*github.RepositoryCommit, 0, len(page))
+	for _, commit := range page {
+		login, err := getCommitAuthorLogin(commit)
+		if err == nil && settings.ExcludesAuthor(login) {
+			continue
+		}
+		kept = append(kept, commit)
 	}
+	return kept
+}
+
+// pageCommit pairs a commit row with the author login it belongs to, before the author's user ID is known
+type pageCommit struct {
+	login  string
+	commit commits.Commit
+}
 
-	return userRow, nil
+// getUserCommitRanges reduces a page of commits to one User per author, holding the earliest and latest commit timestamps for that author
+func getUserCommitRanges(cmts []pageCommit) []users.User {
+	var us []users.User
+	index := make(map[string]int)
+	for _, c := range cmts {
+		key := strings.ToLower(c.login)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(us)
+			us = append(us, users.User{
+				Username:    c.login,
+				FirstCommit: c.commit.Date,
+				LastCommit:  c.commit.Date,
+			})
+			continue
+		}
+		if us[i].FirstCommit.After(c.commit.Date) {
+			us[i].FirstCommit = c.commit.Date
+		}
+		if us[i].LastCommit.Before(c.commit.Date) {
+			us[i].LastCommit = c.commit.Date
+		}
+	}
+	return us
 }
 
-func getRepoRow(owner string, repo string) (repos.Repo, bool, error) {
+func getRepoRow(ctx context.Context, owner string, repo string) (repos.Repo, bool, error) {
 	var repoRow repos.Repo
-	rows, err := repos.GetRowsByOwnerAndRepo(owner, repo)
+	rows, err := repos.GetRowsByOwnerAndRepo(ctx, owner, repo)
 	if err != nil {
 		return repoRow, false, err
 	}
@@ -236,13 +513,13 @@ func getRepoRow(owner string, repo string) (repos.Repo, bool, error) {
 	return rows[0], true, nil
 }
 
-func setRepoRow(r repos.Repo) (repos.Repo, error) {
-	err := repos.SetNewRecord(r)
+func setRepoRow(ctx context.Context, r repos.Repo) (repos.Repo, error) {
+	err := repos.SetNewRecord(ctx, r)
 	if err != nil {
 		return repos.Repo{}, err
 	}
 
-	repoRow, _, err := getRepoRow(r.Owner, r.Repo)
+	repoRow, _, err := getRepoRow(ctx, r.Owner, r.Repo)
 	if err != nil {
 		return repos.Repo{}, err
 	}
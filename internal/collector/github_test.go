@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	repocollectionerrors "github.com/chia-network/ecosystem-activity/internal/db/repo_collection_errors"
+)
+
+func TestGetUserCommitRanges(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2023, time.May, d, 0, 0, 0, 0, time.UTC)
+	}
+	cmts := []pageCommit{
+		{login: "alice", commit: commits.Commit{SHA: "a1", Date: day(5)}},
+		{login: "bob", commit: commits.Commit{SHA: "b1", Date: day(3)}},
+		{login: "Alice", commit: commits.Commit{SHA: "a2", Date: day(1)}},
+		{login: "alice", commit: commits.Commit{SHA: "a3", Date: day(9)}},
+	}
+
+	result := getUserCommitRanges(cmts)
+	if len(result) != 2 {
+		t.Fatalf("Result fail. Received %d users, Expected 2", len(result))
+	}
+	if result[0].Username != "alice" || !result[0].FirstCommit.Equal(day(1)) || !result[0].LastCommit.Equal(day(9)) {
+		t.Errorf("Result fail for alice. Received %s %v-%v", result[0].Username, result[0].FirstCommit, result[0].LastCommit)
+	}
+	if result[1].Username != "bob" || !result[1].FirstCommit.Equal(day(3)) || !result[1].LastCommit.Equal(day(3)) {
+		t.Errorf("Result fail for bob. Received %s %v-%v", result[1].Username, result[1].FirstCommit, result[1].LastCommit)
+	}
+}
+
+func TestExcludeAuthors(t *testing.T) {
+	commit := func(sha, login string) *github.RepositoryCommit {
+		return &github.RepositoryCommit{SHA: github.String(sha), Author: &github.User{Login: github.String(login)}}
+	}
+	page := []*github.RepositoryCommit{commit("a1", "alice"), commit("m1", "Mirror-Bot"), commit("b1", "bob")}
+
+	result := excludeAuthors(page, config.Repository{ExcludeAuthors: []string{"mirror-bot", "carol"}})
+	if len(result) != 2 || result[0].GetSHA() != "a1" || result[1].GetSHA() != "b1" {
+		t.Errorf("Result fail. Received %v, Expected a1 and b1", result)
+	}
+	if result := excludeAuthors(page, config.Repository{}); len(result) != 3 {
+		t.Errorf("Result fail. Received %d commits, Expected all 3 without exclusions", len(result))
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		expected   string
+	}{
+		{"not found", errors.New("404 Not Found"), 404, repocollectionerrors.KindNotFound},
+		{"rate limited", errors.New("403 API rate limit exceeded"), 403, repocollectionerrors.KindRateLimited},
+		{"secondary rate limit", errors.New("429 Too Many Requests"), 429, repocollectionerrors.KindRateLimited},
+		{"server error", errors.New("502 Bad Gateway"), 502, repocollectionerrors.KindGitHub},
+		{"no response", errors.New("connection reset by peer"), 0, repocollectionerrors.KindGitHub},
+		// A failed write during a page of commits comes back from a successful GitHub request
+		{"database", dbFailure(errors.New("deadlock found")), 200, repocollectionerrors.KindDatabase},
+	}
+	for _, tt := range tests {
+		if result := errorKind(tt.err, tt.statusCode); result != tt.expected {
+			t.Errorf("Result fail for %s. Received %v, Expected %v", tt.name, result, tt.expected)
+		}
+	}
+}
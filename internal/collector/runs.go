@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	collectorruns "github.com/chia-network/ecosystem-activity/internal/db/collector_runs"
+	repocollectionerrors "github.com/chia-network/ecosystem-activity/internal/db/repo_collection_errors"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runRecorder tallies one pass of the collector into the collector_runs table and the pass's span, and writes each failed repo to the repo_collection_errors table.
+// Writes aren't cancelled with ctx, so a pass stopped by a shutdown is still recorded as interrupted
+type runRecorder struct {
+	run  collectorruns.Run
+	span trace.Span
+}
+
+// startRun records the start of a pass, returning a context for the pass that carries its span and run ID log field.
+// The pass is still collected if it can't be recorded, it's just missing from the run history
+func startRun(ctx context.Context) (context.Context, *runRecorder) {
+	r := &runRecorder{run: collectorruns.Run{StartedAt: time.Now().UTC(), Status: collectorruns.StatusRunning}}
+	ctx, r.span = tracing.Tracer().Start(ctx, "collector.pass", trace.WithTimestamp(r.run.StartedAt))
+	id, err := collectorruns.Start(context.WithoutCancel(ctx), r.run.StartedAt)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error recording the start of a collector run, this pass is left out of the run history: %v", err)
+	}
+	r.run.ID = id
+	r.span.SetAttributes(attribute.Int("collector.run_id", id))
+	return logging.WithFields(ctx, log.Fields{logging.FieldRunID: id}), r
+}
+
+// startRepo starts the span for collecting one repo in a pass
+func startRepo(ctx context.Context, owner string, repo string) (context.Context, trace.Span) {
+	ctx = logging.WithFields(ctx, log.Fields{logging.FieldOwner: owner, logging.FieldRepo: repo})
+	return tracing.Tracer().Start(ctx, "collector.repo", trace.WithAttributes(attribute.String("github.owner", owner), attribute.String("github.repo", repo)))
+}
+
+// endRepo ends the span for collecting one repo with its result
+func endRepo(span trace.Span, result repoResult) {
+	span.SetAttributes(
+		attribute.Bool("collector.skipped", result.skipped),
+		attribute.Int("collector.commits_inserted", result.inserted),
+	)
+	if result.repoID != 0 {
+		span.SetAttributes(attribute.Int("collector.repo_id", result.repoID))
+	}
+	if result.failed() {
+		span.SetAttributes(attribute.String("collector.error_kind", result.kind))
+		if result.statusCode != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", result.statusCode))
+		}
+		span.RecordError(result.err)
+		span.SetStatus(codes.Error, result.err.Error())
+	}
+	span.End()
+}
+
+// add counts the result of collecting one repo, named by its owner/repo (or its URL from config, when that couldn't be parsed)
+func (r *runRecorder) add(ctx context.Context, repo string, result repoResult) {
+	r.run.CommitsInserted += result.inserted
+	if result.skipped {
+		return
+	}
+	r.run.ReposAttempted++
+	if !result.failed() {
+		r.run.ReposSucceeded++
+		return
+	}
+	r.run.ReposFailed++
+	if r.run.ID == 0 {
+		return
+	}
+
+	err := repocollectionerrors.SetNewRecord(context.WithoutCancel(ctx), repocollectionerrors.CollectionError{
+		RunID:      r.run.ID,
+		RepoID:     result.repoID,
+		Repo:       repo,
+		Kind:       result.kind,
+		StatusCode: result.statusCode,
+		Message:    result.err.Error(),
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error(err)
+	}
+}
+
+// finish records the end of a pass with status, and ends its span
+func (r *runRecorder) finish(ctx context.Context, status string) {
+	r.run.Status = status
+	r.run.FinishedAt = time.Now().UTC()
+	r.span.SetAttributes(
+		attribute.String("collector.status", status),
+		attribute.Int("collector.repos_attempted", r.run.ReposAttempted),
+		attribute.Int("collector.repos_succeeded", r.run.ReposSucceeded),
+		attribute.Int("collector.repos_failed", r.run.ReposFailed),
+		attribute.Int("collector.commits_inserted", r.run.CommitsInserted),
+	)
+	r.span.End(trace.WithTimestamp(r.run.FinishedAt))
+
+	elapsed := r.run.FinishedAt.Sub(r.run.StartedAt)
+	logging.FromContext(ctx).WithField(logging.FieldDurationMs, elapsed.Milliseconds()).Infof("Collector run %s after %s: %d repos attempted, %d succeeded, %d failed, %d new commits",
+		status, elapsed.Round(time.Second), r.run.ReposAttempted, r.run.ReposSucceeded, r.run.ReposFailed, r.run.CommitsInserted)
+	if r.run.ID == 0 {
+		return
+	}
+
+	err := collectorruns.Finish(context.WithoutCancel(ctx), r.run)
+	if err != nil {
+		logging.FromContext(ctx).Error(err)
+	}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	collectorruns "github.com/chia-network/ecosystem-activity/internal/db/collector_runs"
+	repocollectionerrors "github.com/chia-network/ecosystem-activity/internal/db/repo_collection_errors"
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunRecorderAdd(t *testing.T) {
+	// A run without an ID wasn't recorded in the db, so only the tally is kept
+	r := &runRecorder{run: collectorruns.Run{Status: collectorruns.StatusRunning}}
+	ctx := context.Background()
+	r.add(ctx, "chia-network/chia-blockchain", repoResult{repoID: 1, inserted: 12})
+	r.add(ctx, "chia-network/chia-docs", repoResult{repoID: 2})
+	r.add(ctx, "chia-network/gone", repoResult{repoID: 3, kind: repocollectionerrors.KindNotFound, statusCode: 404, err: errors.New("repo chia-network/gone returned a 404")})
+	r.add(ctx, "Chia-Network/Chia-Blockchain", repoResult{skipped: true, repoID: 1})
+	// A repo cut off by a shutdown keeps the commits it wrote, but isn't counted as attempted
+	r.add(ctx, "chia-network/clvm", repoResult{skipped: true, repoID: 4, inserted: 3})
+
+	expected := collectorruns.Run{Status: collectorruns.StatusRunning, ReposAttempted: 3, ReposSucceeded: 2, ReposFailed: 1, CommitsInserted: 15}
+	if r.run != expected {
+		t.Errorf("Result fail. Received %+v, Expected %+v", r.run, expected)
+	}
+}
+
+func TestRepoSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	passCtx, pass := tracing.Tracer().Start(context.Background(), "collector.pass")
+	_, span := startRepo(passCtx, "chia-network", "chia-blockchain")
+	endRepo(span, repoResult{repoID: 1, inserted: 12})
+	_, span = startRepo(passCtx, "chia-network", "gone")
+	endRepo(span, repoResult{repoID: 3, kind: repocollectionerrors.KindNotFound, statusCode: 404, err: errors.New("repo chia-network/gone returned a 404")})
+	pass.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Result fail. Received %d spans, Expected %d", len(spans), 3)
+	}
+	for _, s := range spans[:2] {
+		if s.Parent().SpanID() != pass.SpanContext().SpanID() {
+			t.Errorf("Result fail. Received %s under span %s, Expected it under the pass span", s.Name(), s.Parent().SpanID())
+		}
+	}
+
+	attrs := func(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+	collected := attrs(spans[0])
+	if repo := collected["github.repo"].AsString(); repo != "chia-blockchain" {
+		t.Errorf("Result fail. Received %v, Expected %v", repo, "chia-blockchain")
+	}
+	if inserted := collected["collector.commits_inserted"].AsInt64(); inserted != 12 {
+		t.Errorf("Result fail. Received %v, Expected %v", inserted, 12)
+	}
+	if status := spans[0].Status().Code; status != codes.Unset {
+		t.Errorf("Result fail. Received %v, Expected %v", status, codes.Unset)
+	}
+
+	failed := attrs(spans[1])
+	if kind := failed["collector.error_kind"].AsString(); kind != repocollectionerrors.KindNotFound {
+		t.Errorf("Result fail. Received %v, Expected %v", kind, repocollectionerrors.KindNotFound)
+	}
+	if statusCode := failed["http.response.status_code"].AsInt64(); statusCode != 404 {
+		t.Errorf("Result fail. Received %v, Expected %v", statusCode, 404)
+	}
+	if status := spans[1].Status().Code; status != codes.Error {
+		t.Errorf("Result fail. Received %v, Expected %v", status, codes.Error)
+	}
+}
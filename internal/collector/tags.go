@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/db/tags"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// syncConfigTags replaces the config tags in the repo_tags table with the tags of each repo in the repo list. Repos that aren't in the repos table yet
+// are tagged on the pass after they're first collected
+func syncConfigTags(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	byRepo := make(map[int][]string)
+	for _, settings := range repoList {
+		if len(settings.Tags) == 0 {
+			continue
+		}
+		owner, repo, ok := splitRepoURL(settings.URL)
+		if !ok {
+			continue
+		}
+
+		repoLogger := logger.WithFields(log.Fields{logging.FieldOwner: owner, logging.FieldRepo: repo})
+		repoRow, inTable, err := getRepoRow(ctx, owner, repo)
+		if err != nil {
+			repoLogger.Error(err)
+			continue
+		}
+		if !inTable {
+			aliased, err := repos.GetRowsByAlias(ctx, owner, repo)
+			if err != nil {
+				repoLogger.Error(err)
+				continue
+			}
+			if len(aliased) != 1 {
+				continue
+			}
+			repoRow = aliased[0]
+		}
+		byRepo[repoRow.ID] = append(byRepo[repoRow.ID], settings.Tags...)
+	}
+
+	err := tags.SyncConfigTags(ctx, byRepo)
+	if err != nil {
+		logger.Errorf("error syncing repo tags from config: %v", err)
+		return
+	}
+	logger.Debugf("synced tags from config for %d repos", len(byRepo))
+}
+
+// splitRepoURL returns the owner and name of a GitHub repo from its URL
+func splitRepoURL(repoURL string) (string, string, bool) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil || parsedURL.Host != "github.com" {
+		return "", "", false
+	}
+	split := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	if len(split) < 2 || split[0] == "" || split[1] == "" {
+		return "", "", false
+	}
+	return split[0], strings.TrimSuffix(split[1], ".git"), true
+}
@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// maxWebhookPayload is the largest payload GitHub sends, 25 MB
+const maxWebhookPayload = 25 << 20
+
+// webhookHandler receives GitHub webhooks, writing the commits from push events to the db
+type webhookHandler struct {
+	secret []byte
+	push   func(ctx context.Context, event *github.PushEvent) error
+}
+
+// WebhookHandler returns a handler for GitHub webhooks that verifies each delivery's X-Hub-Signature-256 with the secret, and writes the commits pushed to
+// the collected branch of repos already in the repos table, through the same path as the collector. Polling still picks up anything a webhook misses.
+// Every replica writes the pushes delivered to it, whether or not it's the leader, since GitHub doesn't deliver them again.
+// The commits table's unique index on repo and SHA keeps a commit the collector is writing at the same time from being counted twice
+func WebhookHandler(secret string, cfg config.Config) http.Handler {
+	return &webhookHandler{secret: []byte(secret), push: func(ctx context.Context, event *github.PushEvent) error {
+		return writePushEvent(ctx, cfg, event)
+	}}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	signature := r.Header.Get(github.SHA256SignatureHeader)
+	if signature == "" {
+		http.Error(w, "missing "+github.SHA256SignatureHeader+" header", http.StatusUnauthorized)
+		return
+	}
+	payload, err := github.ValidatePayloadFromBody(r.Header.Get("Content-Type"), http.MaxBytesReader(w, r.Body, maxWebhookPayload), signature, h.secret)
+	if err != nil {
+		log.Warnf("rejected GitHub webhook delivery %s: %v", r.Header.Get(github.DeliveryIDHeader), err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := github.WebHookType(r)
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		// Events this version of go-github doesn't know about aren't an error, there's just nothing to do with them
+		log.Debugf("ignoring GitHub webhook delivery %s of event type %s: %v", r.Header.Get(github.DeliveryIDHeader), eventType, err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch e := event.(type) {
+	case *github.PingEvent:
+		log.Infof("received GitHub webhook ping for hook ID %d", e.GetHookID())
+		w.WriteHeader(http.StatusNoContent)
+	case *github.PushEvent:
+		err = h.push(r.Context(), e)
+		if err != nil {
+			log.Errorf("error writing commits from GitHub push webhook delivery %s: %v", r.Header.Get(github.DeliveryIDHeader), err)
+			http.Error(w, "error writing commits", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writePushEvent writes the commits of a push to the branch the collector collects from a repo, if the repo is in the repos table, following the repo's settings from config.
+// New repos are left for the collector to add, and `imported_through` isn't moved, so the collector's next pass still reconciles the repo
+func writePushEvent(ctx context.Context, cfg config.Config, event *github.PushEvent) error {
+	settings, listed := pushRepoSettings(cfg, event.GetRepo().GetHTMLURL())
+	if listed && !settings.IsEnabled() {
+		return nil
+	}
+	if !pushedToCollectedBranch(event, settings.Branch) {
+		return nil
+	}
+	repoRow, ok, err := getPushRepoRow(ctx, event.GetRepo())
+	if err != nil || !ok {
+		return err
+	}
+
+	ctx = logging.WithFields(ctx, log.Fields{logging.FieldOwner: repoRow.Owner, logging.FieldRepo: repoRow.Repo})
+
+	page := excludeAuthors(pushCommits(event), settings)
+	if len(page) == 0 {
+		return nil
+	}
+	logging.FromContext(ctx).Debugf("writing %d pushed commits", len(page))
+	_, err = writeCommitPage(ctx, repoRow, page, time.Time{})
+	return err
+}
+
+// pushRepoSettings returns the settings for a pushed repo, and whether it's in the repo list. Individual repository entries are read from cfg, since
+// the repo list is only put together by the collector, which only runs in the leader; settings for org repos and approved candidates are the defaults anyway
+func pushRepoSettings(cfg config.Config, repoURL string) (config.Repository, bool) {
+	for _, repo := range cfg.IndividualRepositories {
+		if repoKey(repo.URL) == repoKey(repoURL) {
+			return repo, true
+		}
+	}
+	return lookupRepoSettings(repoURL)
+}
+
+// pushedToCollectedBranch returns whether an event is a push of commits to the branch the collector collects, which is the repo's default branch
+// unless a branch is set in config
+func pushedToCollectedBranch(event *github.PushEvent, branch string) bool {
+	if event.GetDeleted() {
+		return false
+	}
+	if branch == "" {
+		branch = event.GetRepo().GetDefaultBranch()
+	}
+	return event.GetRef() == "refs/heads/"+branch
+}
+
+// getPushRepoRow finds the repos table row for a pushed repo by its GitHub ID, falling back to its name and former names for rows without one
+func getPushRepoRow(ctx context.Context, repo *github.PushEventRepository) (repos.Repo, bool, error) {
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	if owner == "" {
+		// Push payloads name the owner, where other events give its login
+		owner = repo.GetOwner().GetName()
+	}
+
+	if repo.GetID() != 0 {
+		rows, err := repos.GetRowsByGitHubID(ctx, repo.GetID())
+		if err != nil {
+			return repos.Repo{}, false, err
+		}
+		if len(rows) == 1 {
+			return rows[0], true, nil
+		}
+	}
+
+	repoRow, ok, err := getRepoRow(ctx, owner, name)
+	if err != nil || ok {
+		return repoRow, ok, err
+	}
+	aliased, err := repos.GetRowsByAlias(ctx, owner, name)
+	if err != nil {
+		return repos.Repo{}, false, err
+	}
+	if len(aliased) == 1 {
+		return aliased[0], true, nil
+	}
+
+	log.Debugf("ignoring push to %s/%s, which isn't in the repos table yet", owner, name)
+	return repos.Repo{}, false, nil
+}
+
+// pushCommits converts the commits of a push event to the shape the commits API returns them in, for writeCommitPage.
+// Commits whose author email isn't linked to a GitHub account have no username, and are skipped like they are by the commits API
+func pushCommits(event *github.PushEvent) []*github.RepositoryCommit {
+	var page []*github.RepositoryCommit
+	for _, c := range event.Commits {
+		login := c.GetAuthor().GetLogin()
+		if login == "" {
+			log.WithField(logging.FieldSHA, c.GetID()).Debug("skipping pushed commit without an author username")
+			continue
+		}
+		page = append(page, &github.RepositoryCommit{
+			SHA:    github.String(c.GetID()),
+			Author: &github.User{Login: github.String(login)},
+			Commit: &github.Commit{
+				SHA:    github.String(c.GetID()),
+				Author: &github.CommitAuthor{Date: c.Timestamp, Login: github.String(login)},
+			},
+		})
+	}
+	if len(event.Commits) < event.GetSize() {
+		log.Debugf("push to %s had %d commits but its payload only lists %d, the collector will pick up the rest", event.GetRepo().GetFullName(), event.GetSize(), len(event.Commits))
+	}
+	return page
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+)
+
+const testWebhookSecret = "It's a Secret to Everybody"
+
+// sign returns the X-Hub-Signature-256 header GitHub sends with a payload
+func sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliver(t *testing.T, h http.Handler, event, fixture, signature string) *httptest.ResponseRecorder {
+	payload, err := os.ReadFile(filepath.Join("testdata", "webhooks", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature == "" {
+		signature = sign(payload, testWebhookSecret)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(github.EventTypeHeader, event)
+	req.Header.Set(github.DeliveryIDHeader, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	req.Header.Set(github.SHA256SignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhookPush(t *testing.T) {
+	var pushed []*github.PushEvent
+	h := &webhookHandler{secret: []byte(testWebhookSecret), push: func(ctx context.Context, e *github.PushEvent) error {
+		pushed = append(pushed, e)
+		return nil
+	}}
+
+	rec := deliver(t, h, "push", "push.json", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Result fail. Received %d, Expected %d", rec.Code, http.StatusNoContent)
+	}
+	if len(pushed) != 1 || pushed[0].GetRepo().GetFullName() != "Chia-Network/chia-blockchain" || len(pushed[0].Commits) != 2 {
+		t.Fatalf("Result fail. Received pushes %v", pushed)
+	}
+	if !pushedToCollectedBranch(pushed[0], "") {
+		t.Errorf("Result fail. Received a push to %s, Expected it to be to the default branch", pushed[0].GetRef())
+	}
+
+	page := pushCommits(pushed[0])
+	if len(page) != 1 {
+		t.Fatalf("Result fail. Received %d commits, Expected 1 with an author username", len(page))
+	}
+	sha, _ := getCommitSHA(page[0])
+	login, _ := getCommitAuthorLogin(page[0])
+	date, _ := getCommitDate(page[0])
+	expectDate := time.Date(2026, time.October, 1, 21, 6, 19, 0, time.UTC)
+	if sha != "c441029cf673f84c8b7db52d0a5944ee5c52ff89" || login != "alice" || !date.Equal(expectDate) {
+		t.Errorf("Result fail. Received %s by %s at %v, Expected c441029 by alice at %v", sha, login, date, expectDate)
+	}
+}
+
+func TestPushRepoSettings(t *testing.T) {
+	disabled := false
+	cfg := config.Config{IndividualRepositories: []config.Repository{
+		{URL: "https://github.com/Chia-Network/chia-blockchain", Branch: "main"},
+		{URL: "https://github.com/Chia-Network/chia-dev-tools", Enabled: &disabled},
+	}}
+
+	// Replicas that aren't the leader haven't put together a repo list, but still follow the individual entries in config
+	repoListMu.Lock()
+	repoList = nil
+	repoListMu.Unlock()
+	settings, listed := pushRepoSettings(cfg, "https://github.com/chia-network/chia-blockchain/")
+	if !listed || settings.Branch != "main" {
+		t.Errorf("Result fail. Received %+v listed %v, Expected branch main", settings, listed)
+	}
+	if settings, listed := pushRepoSettings(cfg, "https://github.com/Chia-Network/chia-dev-tools"); !listed || settings.IsEnabled() {
+		t.Errorf("Result fail. Received %+v listed %v, Expected it to be disabled", settings, listed)
+	}
+	if _, listed := pushRepoSettings(cfg, "https://github.com/Chia-Network/go-chia-libs"); listed {
+		t.Errorf("Result fail. Expected go-chia-libs not to be listed without a repo list")
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	h := &webhookHandler{secret: []byte(testWebhookSecret), push: func(ctx context.Context, e *github.PushEvent) error {
+		t.Error("Result fail. Push handled without a valid signature")
+		return nil
+	}}
+
+	payload, err := os.ReadFile(filepath.Join("testdata", "webhooks", "push.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, signature := range []string{
+		sign(payload, "wrong secret"),
+		"sha256=" + hex.EncodeToString(make([]byte, sha256.Size)),
+	} {
+		rec := deliver(t, h, "push", "push.json", signature)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Result fail for signature %s. Received %d, Expected %d", signature, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// Unsigned deliveries are rejected too
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(github.EventTypeHeader, "push")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Result fail. Received %d, Expected %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestWebhookPing(t *testing.T) {
+	h := &webhookHandler{secret: []byte(testWebhookSecret), push: func(ctx context.Context, e *github.PushEvent) error {
+		t.Error("Result fail. Ping handled as a push")
+		return nil
+	}}
+	rec := deliver(t, h, "ping", "ping.json", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Result fail. Received %d, Expected %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestPushedToCollectedBranch(t *testing.T) {
+	repo := &github.PushEventRepository{DefaultBranch: github.String("main")}
+	tests := []struct {
+		event  *github.PushEvent
+		branch string
+		expect bool
+	}{
+		{&github.PushEvent{Ref: github.String("refs/heads/main"), Repo: repo}, "", true},
+		{&github.PushEvent{Ref: github.String("refs/heads/feature"), Repo: repo}, "", false},
+		{&github.PushEvent{Ref: github.String("refs/tags/main"), Repo: repo}, "", false},
+		{&github.PushEvent{Ref: github.String("refs/heads/main"), Repo: repo, Deleted: github.Bool(true)}, "", false},
+		{&github.PushEvent{Ref: github.String("refs/heads/develop"), Repo: repo}, "develop", true},
+		{&github.PushEvent{Ref: github.String("refs/heads/main"), Repo: repo}, "develop", false},
+	}
+	for _, test := range tests {
+		if result := pushedToCollectedBranch(test.event, test.branch); result != test.expect {
+			t.Errorf("Result fail for %s with branch \"%s\". Received %v, Expected %v", test.event.GetRef(), test.branch, result, test.expect)
+		}
+	}
+}
@@ -3,12 +3,164 @@ package config
 // Config Represents the application level config
 type Config struct {
 	GithubOrganizations    []GithubOrganizations `mapstructure:"github_organizations"`
-	IndividualRepositories []string              `mapstructure:"individual_repositories"` // Individual repositories (not owned by specific orgs or users)
+	IndividualRepositories []Repository          `mapstructure:"individual_repositories"` // Individual repositories (not owned by specific orgs or users), as URLs or with collection settings
+	Affiliations           Affiliations          `mapstructure:"affiliations"`            // Rules for classifying users as internal, partner, or community developers
+
+	DeveloperClassification DeveloperClassification `mapstructure:"developer_classification"` // Thresholds for classifying developers as full-time, part-time, or one-time
+	Retention               Retention               `mapstructure:"retention"`                // Settings for cohort retention and churn detection
+	RepoHealth              RepoHealth              `mapstructure:"repo_health"`              // Weights and targets for the repo health score
+
+	ReportDelivery ReportDelivery `mapstructure:"report_delivery"` // Recipients of the scheduled monthly report
+	Discovery      Discovery      `mapstructure:"discovery"`       // GitHub searches for finding repos that aren't in this config yet
 }
 
 // GithubOrganizations represents key attributes for a github organization for this config
 type GithubOrganizations struct {
-	Name         string `mapstructure:"name"`          // The name of the org
-	Visibility   string `mapstructure:"visibility"`    // The visibility level of repos to look at
-	ExcludeForks bool   `mapstructure:"exclude_forks"` // Set to true if you want to exclude repo forks from the organization
+	Name            string   `mapstructure:"name"`             // The name of the org
+	Visibility      string   `mapstructure:"visibility"`       // The visibility level of repos to look at
+	ExcludeForks    bool     `mapstructure:"exclude_forks"`    // Set to true if you want to exclude repo forks from the organization
+	ExcludeArchived bool     `mapstructure:"exclude_archived"` // Set to true if you want to exclude archived repos from the organization
+	Include         []string `mapstructure:"include"`          // Repo name globs, ie. "chia-*". Only matching repos are collected when set
+	Exclude         []string `mapstructure:"exclude"`          // Repo name globs for repos that aren't collected, checked after include
+	Tags            []string `mapstructure:"tags"`             // Tags for every repo collected from the org
+}
+
+// Affiliations holds the rules for classifying users. A user matching the internal rules is internal, otherwise a user matching the partner rules is a partner,
+// and everyone else is a community developer. Explicitly listed users are classified before org and company rules are checked.
+type Affiliations struct {
+	Internal AffiliationRule `mapstructure:"internal"`
+	Partner  AffiliationRule `mapstructure:"partner"`
+}
+
+// AffiliationRule matches users for one affiliation. All matching is case-insensitive
+type AffiliationRule struct {
+	Users     []string `mapstructure:"users"`     // GitHub usernames
+	Orgs      []string `mapstructure:"orgs"`      // GitHub organizations the user is a public member of
+	Companies []string `mapstructure:"companies"` // The company on the user's GitHub profile, with or without a leading @
+}
+
+// DeveloperClassification holds the thresholds for classifying a developer in a month by the distinct days they committed on in a rolling window ending with that month.
+// Zero values are replaced with the defaults by WithDefaults
+type DeveloperClassification struct {
+	WindowDays       int `mapstructure:"window_days"`         // Length of the rolling window (default: 84, three 28 day months)
+	FullTimeDays     int `mapstructure:"full_time_days"`      // Active days per 28 days, averaged over the window, to be full-time (default: 10)
+	OneTimeMaxMonths int `mapstructure:"one_time_max_months"` // Developers below full-time who were active in at most this many 28 day periods of the window are one-time (default: 1)
+}
+
+// WithDefaults returns the thresholds with any unset values replaced by their defaults
+func (d DeveloperClassification) WithDefaults() DeveloperClassification {
+	if d.WindowDays <= 0 {
+		d.WindowDays = 84
+	}
+	if d.FullTimeDays <= 0 {
+		d.FullTimeDays = 10
+	}
+	if d.OneTimeMaxMonths <= 0 {
+		d.OneTimeMaxMonths = 1
+	}
+	return d
+}
+
+// Retention holds the settings for detecting developers who churned and returned. Zero values are replaced with the defaults by WithDefaults
+type Retention struct {
+	ChurnDays int `mapstructure:"churn_days"` // Days without a commit before a developer has churned (default: 90)
+}
+
+// WithDefaults returns the settings with any unset values replaced by their defaults
+func (r Retention) WithDefaults() Retention {
+	if r.ChurnDays <= 0 {
+		r.ChurnDays = 90
+	}
+	return r
+}
+
+// RepoHealth holds the weights and targets for the composite repo health score. Each component is scored from 0 to 1 and the score is their
+// weighted average out of 100. Zero values are replaced with the defaults by WithDefaults
+type RepoHealth struct {
+	Weights HealthWeights `mapstructure:"weights"`
+
+	ContributorsTarget int `mapstructure:"contributors_target"` // Contributors in the trailing 90 days for a full contributors component (default: 5)
+	BusFactorTarget    int `mapstructure:"bus_factor_target"`   // Bus factor for a full bus factor component (default: 3)
+	AbandonedDays      int `mapstructure:"abandoned_days"`      // Days since the last commit before a repo is flagged as abandoned, and its recency component reaches 0 (default: 365)
+}
+
+// HealthWeights holds the weight of each component of the repo health score. They're relative to each other, so they don't need to add up to 1
+type HealthWeights struct {
+	Contributors float64 `mapstructure:"contributors"` // default: 0.3
+	BusFactor    float64 `mapstructure:"bus_factor"`   // default: 0.25
+	Recency      float64 `mapstructure:"recency"`      // default: 0.3
+	Trend        float64 `mapstructure:"trend"`        // default: 0.15
+}
+
+// WithDefaults returns the settings with any unset values replaced by their defaults. Weights are only defaulted when all of them are unset,
+// so a single component can be weighted at 0
+func (h RepoHealth) WithDefaults() RepoHealth {
+	if h.Weights == (HealthWeights{}) {
+		h.Weights = HealthWeights{Contributors: 0.3, BusFactor: 0.25, Recency: 0.3, Trend: 0.15}
+	}
+	if h.ContributorsTarget <= 0 {
+		h.ContributorsTarget = 5
+	}
+	if h.BusFactorTarget <= 0 {
+		h.BusFactorTarget = 3
+	}
+	if h.AbandonedDays <= 0 {
+		h.AbandonedDays = 365
+	}
+	return h
+}
+
+// ReportDelivery holds the recipients of the scheduled monthly report and how failed deliveries are retried. The SMTP password and webhook URL are secrets,
+// so they're set with flags instead. Zero values are replaced with the defaults by WithDefaults
+type ReportDelivery struct {
+	SMTP SMTP `mapstructure:"smtp"`
+
+	MaxAttempts  int `mapstructure:"max_attempts"`  // Attempts at each delivery before it's given up on (default: 5)
+	RetryMinutes int `mapstructure:"retry_minutes"` // Minutes between retries of failed deliveries (default: 30)
+}
+
+// SMTP holds the mail server and recipients for emailing the report. Email is disabled when there are no recipients
+type SMTP struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`     // default: 587
+	Username string   `mapstructure:"username"` // Leave empty for servers that don't require authentication
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// WithDefaults returns the settings with any unset values replaced by their defaults
+func (r ReportDelivery) WithDefaults() ReportDelivery {
+	if r.SMTP.Port <= 0 {
+		r.SMTP.Port = 587
+	}
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = 5
+	}
+	if r.RetryMinutes <= 0 {
+		r.RetryMinutes = 30
+	}
+	return r
+}
+
+// Discovery holds the GitHub searches that find candidate repos, and the filters candidates must pass. Zero values are replaced with the defaults by WithDefaults
+type Discovery struct {
+	RepositoryQueries []string `mapstructure:"repository_queries"` // Repository searches, ie. "topic:chia-blockchain" or "chialisp in:readme"
+	CodeQueries       []string `mapstructure:"code_queries"`       // Code searches, ie. "chia_rs language:rust", which match repos with matching files
+
+	MaxResults   int  `mapstructure:"max_results"`   // Results kept from each query (default: 100, GitHub returns at most 1000)
+	MinStars     int  `mapstructure:"min_stars"`     // Stars a repo needs to be a candidate (default: 0)
+	ActiveDays   int  `mapstructure:"active_days"`   // Days since the last push for a repo to be a candidate (default: 365)
+	ExcludeForks bool `mapstructure:"exclude_forks"` // Set to true to skip forks
+	AutoAdd      bool `mapstructure:"auto_add"`      // Approve new candidates for collection right away, instead of queueing them for review
+}
+
+// WithDefaults returns the settings with any unset values replaced by their defaults
+func (d Discovery) WithDefaults() Discovery {
+	if d.MaxResults <= 0 {
+		d.MaxResults = 100
+	}
+	if d.ActiveDays <= 0 {
+		d.ActiveDays = 365
+	}
+	return d
 }
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const testConfig = `
+github_organizations:
+  - name: "chia-network"
+    exclude_archived: true
+    include: ["chia-*", "go-chia-*"]
+    exclude: ["*-ARCHIVE"]
+    tags: ["core"]
+individual_repositories:
+  - "https://github.com/someone/plain"
+  - url: "https://github.com/someone/quoted-date"
+    since: "2021-03-04"
+    branch: "develop"
+    exclude_authors: ["ci-user"]
+    tags: ["wallets", "explorers"]
+  - url: "https://github.com/someone/unquoted-date"
+    since: 2022-05-06
+    enabled: false
+`
+
+func TestDecodeRepositories(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	err = v.Unmarshal(&cfg, viper.DecodeHook(DecodeHook()))
+	if err != nil {
+		t.Fatalf("Result fail. Received error %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Result fail. Received error %v, Expected a valid config", err)
+	}
+
+	repos := cfg.IndividualRepositories
+	if len(repos) != 3 {
+		t.Fatalf("Result fail. Received %d repositories, Expected 3", len(repos))
+	}
+	if repos[0].URL != "https://github.com/someone/plain" || !repos[0].Since.IsZero() || repos[0].Branch != "" || !repos[0].IsEnabled() {
+		t.Errorf("Result fail. Received %+v, Expected a plain URL entry", repos[0])
+	}
+	if !repos[1].Since.Equal(time.Date(2021, time.March, 4, 0, 0, 0, 0, time.UTC)) || repos[1].Branch != "develop" || len(repos[1].Tags) != 2 {
+		t.Errorf("Result fail. Received %+v", repos[1])
+	}
+	if !repos[1].ExcludesAuthor("CI-User") || repos[1].ExcludesAuthor("alice") {
+		t.Errorf("Result fail. Received exclude_authors %v", repos[1].ExcludeAuthors)
+	}
+	if !repos[2].Since.Equal(time.Date(2022, time.May, 6, 0, 0, 0, 0, time.UTC)) || repos[2].IsEnabled() {
+		t.Errorf("Result fail. Received %+v, Expected a disabled entry since 2022-05-06", repos[2])
+	}
+
+	org := cfg.GithubOrganizations[0]
+	if !org.ExcludeArchived || len(org.Tags) != 1 {
+		t.Errorf("Result fail. Received %+v", org)
+	}
+}
+
+func TestOrganizationIncludes(t *testing.T) {
+	org := GithubOrganizations{Include: []string{"chia-*", "go-chia-*"}, Exclude: []string{"*-ARCHIVE"}}
+	var tests = []struct {
+		name     string
+		expected bool
+	}{
+		{"chia-blockchain", true},
+		{"Go-Chia-Libs", true},
+		{"chia-blockchain-archive", false},
+		{"cadt", false},
+	}
+	for _, tt := range tests {
+		result := org.Includes(tt.name)
+		if result != tt.expected {
+			t.Errorf("Result fail for %s. Received %v, Expected %v", tt.name, result, tt.expected)
+		}
+	}
+
+	if !(GithubOrganizations{}).Includes("anything") {
+		t.Errorf("Result fail. Received false, Expected an org without globs to include every repo")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		cfg     Config
+		wantErr bool
+	}{
+		{Config{IndividualRepositories: []Repository{{URL: "https://github.com/someone/repo"}}}, false},
+		{Config{IndividualRepositories: []Repository{{URL: ""}}}, true},
+		{Config{GithubOrganizations: []GithubOrganizations{{Name: "org", Exclude: []string{"["}}}}, true},
+	}
+	for i, tt := range tests {
+		err := tt.cfg.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Result fail for case %d. Received %v, Expected error %v", i, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Repository is one individual repository in the config file. Entries can be a plain URL, which collects the repo's default branch
+// with no overrides, or an object with a url and any of the settings below
+type Repository struct {
+	URL            string    `mapstructure:"url"`
+	Since          time.Time `mapstructure:"since"`           // Commits before this date (YYYY-MM-DD) aren't collected (default: 2017-08-01)
+	Branch         string    `mapstructure:"branch"`          // The branch to collect commits from (default: the repo's default branch)
+	ExcludeAuthors []string  `mapstructure:"exclude_authors"` // GitHub usernames whose commits aren't collected from this repo, matched case-insensitively
+	Tags           []string  `mapstructure:"tags"`            // Categories for slicing activity by, added to the repo_tags table by the collector
+	Enabled        *bool     `mapstructure:"enabled"`         // Set to false to stop collecting the repo, including when it's also in a configured org (default: true)
+}
+
+// IsEnabled returns whether the repo should be collected
+func (r Repository) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// ExcludesAuthor returns whether commits by a GitHub username are excluded from the repo
+func (r Repository) ExcludesAuthor(login string) bool {
+	for _, a := range r.ExcludeAuthors {
+		if strings.EqualFold(a, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes returns whether a repo in the org, by name, passes the org's include and exclude globs. Globs are matched case-insensitively with path.Match
+func (o GithubOrganizations) Includes(name string) bool {
+	name = strings.ToLower(name)
+	matchesAny := func(globs []string) bool {
+		for _, g := range globs {
+			if ok, _ := path.Match(strings.ToLower(g), name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(o.Include) > 0 && !matchesAny(o.Include) {
+		return false
+	}
+	return !matchesAny(o.Exclude)
+}
+
+// Validate checks the repository URLs and organization globs, which can't be checked while the config file is decoded
+func (c Config) Validate() error {
+	for _, r := range c.IndividualRepositories {
+		u, err := url.Parse(r.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("individual repository \"%s\" is not a URL", r.URL)
+		}
+	}
+	for _, o := range c.GithubOrganizations {
+		for _, g := range append(append([]string{}, o.Include...), o.Exclude...) {
+			if _, err := path.Match(g, ""); err != nil {
+				return fmt.Errorf("organization %s has an invalid glob \"%s\": %v", o.Name, g, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeHook returns the hook the config file must be decoded with. On top of viper's default hooks, it decodes plain URL repository entries
+// and YYYY-MM-DD dates
+func DecodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		repositoryDecodeHook,
+		mapstructure.StringToTimeHookFunc(time.DateOnly),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+// repositoryDecodeHook decodes a repository entry written as a plain URL as if it were an object with only a url
+func repositoryDecodeHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(Repository{}) || from.Kind() != reflect.String {
+		return data, nil
+	}
+	return map[string]any{"url": data}, nil
+}
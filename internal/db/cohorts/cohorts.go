@@ -0,0 +1,175 @@
+package cohorts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	leaderleases "github.com/chia-network/ecosystem-activity/internal/db/leader_leases"
+	log "github.com/sirupsen/logrus"
+)
+
+// The events recorded in the churn_events table
+const (
+	EventChurned  = "churned"
+	EventReturned = "returned"
+)
+
+// insertBatchSize is the number of rows written by each multi-row INSERT in ReplaceAllRecords
+const insertBatchSize = 1000
+
+// Retention represents all columns in one row of the cohort_retention table, the users of one cohort who were active some number of months after it
+type Retention struct {
+	CohortMonth time.Time `json:"cohort_month"` // The first day of the month the cohort's users made their first commit in
+	MonthsSince int       `json:"months_since"`
+	CohortSize  int       `json:"cohort_size"`
+	ActiveUsers int       `json:"active_users"` // Users of the cohort with a commit in the month MonthsSince months after the cohort month
+}
+
+// Share returns the percentage of the cohort that was active
+func (r Retention) Share() float64 {
+	if r.CohortSize == 0 {
+		return 0
+	}
+	return float64(r.ActiveUsers) / float64(r.CohortSize) * 100
+}
+
+// ChurnEvent represents all columns in one row of the churn_events table
+type ChurnEvent struct {
+	UserID int
+	Event  string
+	Date   time.Time // The day the user churned (the end of the window without a commit) or returned (their first commit after churning)
+}
+
+// MonthlyChurn counts the churn events in one month
+type MonthlyChurn struct {
+	Month    time.Time `json:"month"`
+	Churned  int       `json:"churned"`
+	Returned int       `json:"returned"`
+}
+
+// ReplaceAllRecords replaces every row in the cohort_retention and churn_events tables in one transaction, so readers never see a partially refreshed table.
+// The transaction is fenced on the leader lease, so a replica that lost the lease part way through can't overwrite the new leader's rows
+func ReplaceAllRecords(ctx context.Context, retention []Retention, events []ChurnEvent) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for cohort tables: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for cohort tables: %v", err)
+		}
+	}(tx)
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM cohort_retention;`)
+	if err != nil {
+		return fmt.Errorf("error encountered deleting records for cohort_retention table: %v", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM churn_events;`)
+	if err != nil {
+		return fmt.Errorf("error encountered deleting records for churn_events table: %v", err)
+	}
+
+	for start := 0; start < len(retention); start += insertBatchSize {
+		batch := retention[start:min(start+insertBatchSize, len(retention))]
+		args := make([]any, 0, len(batch)*4)
+		for _, r := range batch {
+			args = append(args, r.CohortMonth.Format("2006-01-02"), r.MonthsSince, r.CohortSize, r.ActiveUsers)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO cohort_retention (cohort_month,months_since,cohort_size,active_users) VALUES `+db.ValuesPlaceholders(len(batch), 4)+`;`, args...)
+		if err != nil {
+			return fmt.Errorf("error encountered inserting records to cohort_retention table: %v", err)
+		}
+	}
+	for start := 0; start < len(events); start += insertBatchSize {
+		batch := events[start:min(start+insertBatchSize, len(events))]
+		args := make([]any, 0, len(batch)*3)
+		for _, e := range batch {
+			args = append(args, e.UserID, e.Event, e.Date.Format("2006-01-02"))
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO churn_events (user_id,event,event_date) VALUES `+db.ValuesPlaceholders(len(batch), 3)+`;`, args...)
+		if err != nil {
+			return fmt.Errorf("error encountered inserting records to churn_events table: %v", err)
+		}
+	}
+
+	err = leaderleases.Fence(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("not replacing cohort tables: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction for cohort tables: %v", err)
+	}
+	return nil
+}
+
+// GetRetention returns every row of the cohort_retention table, ordered by cohort and months since
+func GetRetention(ctx context.Context) ([]Retention, error) {
+	var retention []Retention
+	rows, err := db.QueryContext(ctx, "SELECT cohort_month,months_since,cohort_size,active_users FROM cohort_retention ORDER BY cohort_month ASC, months_since ASC")
+	if err != nil {
+		return retention, fmt.Errorf("error querying cohort_retention table for rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var r Retention
+		err := rows.Scan(&r.CohortMonth, &r.MonthsSince, &r.CohortSize, &r.ActiveUsers)
+		if err != nil {
+			return retention, fmt.Errorf("error scanning row for cohort_retention table: %v", err)
+		}
+		retention = append(retention, r)
+	}
+	if err := rows.Err(); err != nil {
+		return retention, fmt.Errorf("error encountered iterating through cohort_retention rows: %v", err)
+	}
+
+	return retention, nil
+}
+
+// GetMonthlyChurn returns the number of users who churned and returned in each month with any churn events, in ascending order
+func GetMonthlyChurn(ctx context.Context) ([]MonthlyChurn, error) {
+	var churn []MonthlyChurn
+	rows, err := db.QueryContext(ctx, `SELECT DATE_FORMAT(event_date, '%Y-%m-01') AS month, SUM(event = ?), SUM(event = ?)
+		FROM churn_events GROUP BY month ORDER BY month ASC`, EventChurned, EventReturned)
+	if err != nil {
+		return churn, fmt.Errorf("error querying churn_events table for monthly churn: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			c     MonthlyChurn
+			month string
+		)
+		err := rows.Scan(&month, &c.Churned, &c.Returned)
+		if err != nil {
+			return churn, fmt.Errorf("error scanning monthly churn row for churn_events table: %v", err)
+		}
+		c.Month, err = time.Parse("2006-01-02", month)
+		if err != nil {
+			return churn, fmt.Errorf("error parsing month %s from churn_events table: %v", month, err)
+		}
+		churn = append(churn, c)
+	}
+	if err := rows.Err(); err != nil {
+		return churn, fmt.Errorf("error encountered iterating through monthly churn rows: %v", err)
+	}
+
+	return churn, nil
+}
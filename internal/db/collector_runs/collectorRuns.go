@@ -0,0 +1,110 @@
+package collectorruns
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// The statuses of a collector run
+const (
+	StatusRunning     = "running"
+	StatusFinished    = "finished"    // Every repo in the repo list was attempted
+	StatusInterrupted = "interrupted" // Stopped part way through by a shutdown, a lost leader lease, or a crash
+)
+
+// Run represents all columns in one row of the collector_runs table, one pass of the collector over the repo list
+type Run struct {
+	ID              int
+	StartedAt       time.Time
+	FinishedAt      time.Time // Zero while running, and for runs cut off by a crash
+	Status          string
+	ReposAttempted  int
+	ReposSucceeded  int
+	ReposFailed     int
+	CommitsInserted int
+}
+
+// Start records a new run that started at startedAt, returning its row ID. Runs left running by a collector that crashed are marked as interrupted,
+// since only one collector runs at a time
+func Start(ctx context.Context, startedAt time.Time) (int, error) {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction for collector_runs table: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			logging.FromContext(ctx).Errorf("error rolling back transaction for collector_runs table: %v", err)
+		}
+	}(tx)
+
+	_, err = tx.ExecContext(ctx, `UPDATE collector_runs SET status = ? WHERE status = ?;`, StatusInterrupted, StatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("error encountered interrupting abandoned collector_runs rows: %v", err)
+	}
+	result, err := tx.ExecContext(ctx, `INSERT INTO collector_runs (started_at,status) VALUES(?, ?);`, startedAt.Format("2006-01-02 15:04:05"), StatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("error encountered adding collector_runs row: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting row ID of new collector_runs row: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error committing transaction for collector_runs table: %v", err)
+	}
+	return int(id), nil
+}
+
+// Finish records the end of a run, with its status and counts
+func Finish(ctx context.Context, r Run) error {
+	_, err := db.ExecContext(ctx, `UPDATE collector_runs SET finished_at = ?, status = ?, repos_attempted = ?, repos_succeeded = ?, repos_failed = ?, commits_inserted = ?
+		WHERE id = ?;`, r.FinishedAt.Format("2006-01-02 15:04:05"), r.Status, r.ReposAttempted, r.ReposSucceeded, r.ReposFailed, r.CommitsInserted, r.ID)
+	if err != nil {
+		return fmt.Errorf("error encountered finishing collector_runs row ID %d: %v", r.ID, err)
+	}
+	return nil
+}
+
+// GetRecent returns up to limit of the most recent runs, newest first
+func GetRecent(ctx context.Context, limit int) ([]Run, error) {
+	var runs []Run
+	rows, err := db.QueryContext(ctx, `SELECT id,started_at,finished_at,status,repos_attempted,repos_succeeded,repos_failed,commits_inserted
+		FROM collector_runs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return runs, fmt.Errorf("error querying collector_runs table for recent runs: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			r          Run
+			finishedAt sql.NullTime
+		)
+		err := rows.Scan(&r.ID, &r.StartedAt, &finishedAt, &r.Status, &r.ReposAttempted, &r.ReposSucceeded, &r.ReposFailed, &r.CommitsInserted)
+		if err != nil {
+			return runs, fmt.Errorf("error scanning row for collector_runs table: %v", err)
+		}
+		r.FinishedAt = finishedAt.Time
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return runs, fmt.Errorf("error encountered iterating through collector_runs rows: %v", err)
+	}
+
+	return runs, nil
+}
@@ -1,14 +1,19 @@
 package commits
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/chia-network/ecosystem-activity/internal/db"
 	log "github.com/sirupsen/logrus"
 )
 
+// updateBatchSize is the number of rows updated by each UPDATE in SetInheritedByIDs
+const updateBatchSize = 1000
+
 // Commit represents all columns in one commit entry in the commits table
 type Commit struct {
 	ID     int
@@ -17,6 +22,10 @@ type Commit struct {
 	Date   time.Time
 	SHA    string
 	Notes  string
+
+	// Set when the same commit is in another tracked repo that it's attributed to instead, ie. history a fork inherited from chia-blockchain.
+	// Inherited commits are left out of analytics so they aren't counted once per fork
+	Inherited bool
 }
 
 // commitWithNulls is a helper struct for mysql rows that may contain null fields
@@ -55,19 +64,175 @@ func convertSQLCommitToCommit(c commitWithNulls) Commit {
 	return commit
 }
 
-// SetNewRecord inserts one new record into the table
-func SetNewRecord(c Commit) error {
-	_, err := db.Exec(`INSERT INTO commits (repo_id,user_id,date,sha,notes) VALUES(?, ?, ?, ?, ?);`, c.RepoID, c.UserID, c.Date.Format("2006-01-02 15:04:05"), c.SHA, c.Notes)
+// SetNewRecord inserts one new record into the table, unless the repo already has a commit with the same SHA
+func SetNewRecord(ctx context.Context, c Commit) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO commits (repo_id,user_id,date,sha,notes) VALUES(?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE id = id;`, c.RepoID, c.UserID, c.Date.Format("2006-01-02 15:04:05"), c.SHA, c.Notes)
 	if err != nil {
 		return fmt.Errorf("error encountered inputting commit to commits table: %v", err)
 	}
 	return nil
 }
 
-// GetAllRowsAscending returns the rows in the commits table sorted in ascending order
-func GetAllRowsAscending() ([]Commit, error) {
+// SetNewRecords inserts a batch of records into the table with one multi-row INSERT as part of a transaction, returning the number of rows inserted.
+// Commits whose repo already has a commit with the same SHA are left as they are, so writers racing to insert the same commit only insert it once
+func SetNewRecords(ctx context.Context, tx *sql.Tx, cs []Commit) (int, error) {
+	if len(cs) == 0 {
+		return 0, nil
+	}
+
+	args := make([]any, 0, len(cs)*6)
+	for _, c := range cs {
+		args = append(args, c.RepoID, c.UserID, c.Date.Format("2006-01-02 15:04:05"), c.SHA, c.Notes, c.Inherited)
+	}
+	// Setting id to itself leaves a duplicate unchanged, which MySQL counts as 0 affected rows
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO commits (repo_id,user_id,date,sha,notes,inherited) VALUES %s ON DUPLICATE KEY UPDATE id = id;`, db.ValuesPlaceholders(len(cs), 6)), args...)
+	if err != nil {
+		return 0, fmt.Errorf("error encountered inputting %d commits to commits table: %v", len(cs), err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting the number of commits inserted to commits table: %v", err)
+	}
+	return int(inserted), nil
+}
+
+// GetExistingSHAsByRepoID returns the subset of the given SHAs that already have a row in the commits table for a repo, as part of a transaction
+func GetExistingSHAsByRepoID(ctx context.Context, tx *sql.Tx, repoID int, shas []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(shas) == 0 {
+		return existing, nil
+	}
+
+	args := make([]any, 0, len(shas)+1)
+	args = append(args, repoID)
+	for _, sha := range shas {
+		args = append(args, sha)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT sha FROM commits WHERE repo_id = ? AND sha IN %s", db.InPlaceholders(len(shas))), args...)
+	if err != nil {
+		return existing, fmt.Errorf("error querying commits table for existing SHAs in repo ID %d: %v", repoID, err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var sha string
+		err := rows.Scan(&sha)
+		if err != nil {
+			return existing, fmt.Errorf("error scanning row for commits table: %v", err)
+		}
+		existing[sha] = true
+	}
+	if err := rows.Err(); err != nil {
+		return existing, fmt.Errorf("error encountered iterating through commit rows: %v", err)
+	}
+
+	return existing, nil
+}
+
+// GetSHAsInOtherRepos returns the subset of the given SHAs that have a row in the commits table for a repo other than repoID,
+// which isn't itself inherited, as part of a transaction
+func GetSHAsInOtherRepos(ctx context.Context, tx *sql.Tx, repoID int, shas []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	if len(shas) == 0 {
+		return found, nil
+	}
+
+	args := make([]any, 0, len(shas)+1)
+	args = append(args, repoID)
+	for _, sha := range shas {
+		args = append(args, sha)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT sha FROM commits WHERE repo_id <> ? AND NOT inherited AND sha IN %s", db.InPlaceholders(len(shas))), args...)
+	if err != nil {
+		return found, fmt.Errorf("error querying commits table for SHAs in repos other than repo ID %d: %v", repoID, err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var sha string
+		err := rows.Scan(&sha)
+		if err != nil {
+			return found, fmt.Errorf("error scanning row for commits table: %v", err)
+		}
+		found[sha] = true
+	}
+	if err := rows.Err(); err != nil {
+		return found, fmt.Errorf("error encountered iterating through commit rows: %v", err)
+	}
+
+	return found, nil
+}
+
+// SHACopy is one row of a commit whose SHA may be in more than one repo
+type SHACopy struct {
+	ID        int
+	RepoID    int
+	SHA       string
+	Inherited bool
+}
+
+// GetSHACopies returns every commit whose SHA is in more than one repo, along with every commit currently flagged as inherited, ordered by SHA
+func GetSHACopies(ctx context.Context) ([]SHACopy, error) {
+	var copies []SHACopy
+	rows, err := db.QueryContext(ctx, `SELECT c.id,c.repo_id,c.sha,c.inherited FROM commits c
+		WHERE c.inherited OR EXISTS (SELECT 1 FROM commits o WHERE o.sha = c.sha AND o.repo_id <> c.repo_id)
+		ORDER BY c.sha, c.id`)
+	if err != nil {
+		return copies, fmt.Errorf("error querying commits table for SHAs in more than one repo: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var c SHACopy
+		err := rows.Scan(&c.ID, &c.RepoID, &c.SHA, &c.Inherited)
+		if err != nil {
+			return copies, fmt.Errorf("error scanning SHA copy row for commits table: %v", err)
+		}
+		copies = append(copies, c)
+	}
+	if err := rows.Err(); err != nil {
+		return copies, fmt.Errorf("error encountered iterating through SHA copy rows: %v", err)
+	}
+
+	return copies, nil
+}
+
+// SetInheritedByIDs sets the inherited flag on a set of rows by ID
+func SetInheritedByIDs(ctx context.Context, ids []int, inherited bool) error {
+	for start := 0; start < len(ids); start += updateBatchSize {
+		batch := ids[start:min(start+updateBatchSize, len(ids))]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, inherited)
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		_, err := db.ExecContext(ctx, `UPDATE commits SET inherited = ? WHERE id IN `+db.InPlaceholders(len(batch))+`;`, args...)
+		if err != nil {
+			return fmt.Errorf("error encountered updating inherited flag on %d rows in commits table: %v", len(batch), err)
+		}
+	}
+	return nil
+}
+
+// GetAllRowsAscending returns the rows in the commits table sorted in ascending order, leaving out inherited commits
+func GetAllRowsAscending(ctx context.Context) ([]Commit, error) {
 	var commits []Commit
-	rows, err := db.Query("SELECT id,repo_id,user_id,date,sha,notes FROM commits WHERE date IS NOT NULL ORDER BY date ASC")
+	rows, err := db.QueryContext(ctx, "SELECT id,repo_id,user_id,date,sha,notes FROM commits WHERE date IS NOT NULL AND NOT inherited ORDER BY date ASC")
 	if err != nil {
 		return commits, fmt.Errorf("error querying commits table for rows: %v", err)
 	}
@@ -96,9 +261,9 @@ func GetAllRowsAscending() ([]Commit, error) {
 }
 
 // GetAllRowsByUserID returns the rows in the commits table that belong to a specific user ID
-func GetAllRowsByUserID(uid int) ([]Commit, error) {
+func GetAllRowsByUserID(ctx context.Context, uid int) ([]Commit, error) {
 	var commits []Commit
-	rows, err := db.Query("SELECT id,repo_id,user_id,date,sha,notes FROM commits WHERE user_id = ?", uid)
+	rows, err := db.QueryContext(ctx, "SELECT id,repo_id,user_id,date,sha,notes FROM commits WHERE user_id = ?", uid)
 	if err != nil {
 		return commits, fmt.Errorf("error querying commits table for rows: %v", err)
 	}
@@ -126,13 +291,276 @@ func GetAllRowsByUserID(uid int) ([]Commit, error) {
 	return commits, nil
 }
 
+// GetRowsBetween returns the rows in the commits table dated on or after start and before end, leaving out inherited commits
+func GetRowsBetween(ctx context.Context, start time.Time, end time.Time) ([]Commit, error) {
+	var commits []Commit
+	rows, err := db.QueryContext(ctx, "SELECT id,repo_id,user_id,date,sha,notes FROM commits WHERE date >= ? AND date < ? AND NOT inherited", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return commits, fmt.Errorf("error querying commits table for rows between %s and %s: %v", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var c commitWithNulls
+		err := rows.Scan(&c.ID, &c.RepoID, &c.UserID, &c.Date, &c.SHA, &c.Notes)
+		if err != nil {
+			return commits, fmt.Errorf("error scanning row for commits table: %v", err)
+		}
+
+		nonNullCommit := convertSQLCommitToCommit(c)
+		commits = append(commits, nonNullCommit)
+	}
+	if err := rows.Err(); err != nil {
+		return commits, fmt.Errorf("error encountered iterating through commit rows: %v", err)
+	}
+
+	return commits, nil
+}
+
+// GetLastCommitDatesByRepo returns the date of the newest commit to each repo, keyed by repo ID. Inherited commits are left out,
+// so a fork that hasn't been committed to since it was forked has no date
+func GetLastCommitDatesByRepo(ctx context.Context) (map[int]time.Time, error) {
+	dates := make(map[int]time.Time)
+	rows, err := db.QueryContext(ctx, "SELECT repo_id, MAX(date) FROM commits WHERE date IS NOT NULL AND NOT inherited GROUP BY repo_id")
+	if err != nil {
+		return dates, fmt.Errorf("error querying commits table for last commit dates: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			repoID int
+			date   time.Time
+		)
+		err := rows.Scan(&repoID, &date)
+		if err != nil {
+			return dates, fmt.Errorf("error scanning last commit date row for commits table: %v", err)
+		}
+		dates[repoID] = date
+	}
+	if err := rows.Err(); err != nil {
+		return dates, fmt.Errorf("error encountered iterating through last commit date rows: %v", err)
+	}
+
+	return dates, nil
+}
+
+// GetFirstCommitDatesByUser returns the date of each user's first commit, keyed by user ID. Inherited commits are left out, unlike the
+// users table's first_commit, so committing to a fork of a repo someone once contributed to doesn't backdate them
+func GetFirstCommitDatesByUser(ctx context.Context) (map[int]time.Time, error) {
+	dates := make(map[int]time.Time)
+	rows, err := db.QueryContext(ctx, "SELECT user_id, MIN(date) FROM commits WHERE date IS NOT NULL AND user_id IS NOT NULL AND NOT inherited GROUP BY user_id")
+	if err != nil {
+		return dates, fmt.Errorf("error querying commits table for first commit dates: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			userID int
+			date   time.Time
+		)
+		err := rows.Scan(&userID, &date)
+		if err != nil {
+			return dates, fmt.Errorf("error scanning first commit date row for commits table: %v", err)
+		}
+		dates[userID] = date
+	}
+	if err := rows.Err(); err != nil {
+		return dates, fmt.Errorf("error encountered iterating through first commit date rows: %v", err)
+	}
+
+	return dates, nil
+}
+
+// GetActiveDaysByUser returns the distinct UTC days each user committed on, keyed by user ID with each user's days in ascending order.
+// Inherited commits are left out
+func GetActiveDaysByUser(ctx context.Context) (map[int][]time.Time, error) {
+	days := make(map[int][]time.Time)
+	rows, err := db.QueryContext(ctx, "SELECT user_id, DATE(date) AS day FROM commits WHERE date IS NOT NULL AND user_id IS NOT NULL AND NOT inherited GROUP BY user_id, day ORDER BY user_id, day")
+	if err != nil {
+		return days, fmt.Errorf("error querying commits table for active days: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			userID int
+			day    time.Time
+		)
+		err := rows.Scan(&userID, &day)
+		if err != nil {
+			return days, fmt.Errorf("error scanning active day row for commits table: %v", err)
+		}
+		days[userID] = append(days[userID], day)
+	}
+	if err := rows.Err(); err != nil {
+		return days, fmt.Errorf("error encountered iterating through active day rows: %v", err)
+	}
+
+	return days, nil
+}
+
+// GetActiveDaysByTagAndUser returns the distinct UTC days each user committed to repos with each tag on, keyed by tag name then user ID,
+// with each user's days in ascending order. Inherited commits are left out
+func GetActiveDaysByTagAndUser(ctx context.Context) (map[string]map[int][]time.Time, error) {
+	days := make(map[string]map[int][]time.Time)
+	rows, err := db.QueryContext(ctx, `SELECT tags.name, c.user_id, DATE(c.date) AS day FROM commits c
+		JOIN repo_tags ON repo_tags.repo_id = c.repo_id JOIN tags ON tags.id = repo_tags.tag_id
+		WHERE c.date IS NOT NULL AND c.user_id IS NOT NULL AND NOT c.inherited GROUP BY tags.name, c.user_id, day ORDER BY tags.name, c.user_id, day`)
+	if err != nil {
+		return days, fmt.Errorf("error querying commits table for active days by tag: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			tag    string
+			userID int
+			day    time.Time
+		)
+		err := rows.Scan(&tag, &userID, &day)
+		if err != nil {
+			return days, fmt.Errorf("error scanning active day by tag row for commits table: %v", err)
+		}
+		if days[tag] == nil {
+			days[tag] = make(map[int][]time.Time)
+		}
+		days[tag][userID] = append(days[tag][userID], day)
+	}
+	if err := rows.Err(); err != nil {
+		return days, fmt.Errorf("error encountered iterating through active day by tag rows: %v", err)
+	}
+
+	return days, nil
+}
+
 // DeleteRow deletes one row in the commits table by ID
 // this will only be used to delete bot user activity once detected
-func DeleteRow(id int) error {
-	_, err := db.Exec(`DELETE FROM commits WHERE id = ?;`, id)
+func DeleteRow(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM commits WHERE id = ?;`, id)
 	if err != nil {
 		return fmt.Errorf("error encountered deleting row for commits table: %v", err)
 	}
 
 	return nil
 }
+
+// Filter narrows commits by date and repo. Zero values don't filter
+type Filter struct {
+	Since time.Time // Inclusive
+	Until time.Time // Exclusive
+	Owner string
+	Repo  string
+}
+
+// IsZero returns whether the filter matches every commit
+func (f Filter) IsZero() bool {
+	return f == Filter{}
+}
+
+// Conditions returns the filter as SQL conditions joined with AND, along with their arguments, for the commits table aliased as c joined to the repos table aliased as r.
+// An empty filter returns "TRUE"
+func (f Filter) Conditions() (string, []any) {
+	conditions := []string{"TRUE"}
+	var args []any
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "c.date >= ?")
+		args = append(args, f.Since.Format("2006-01-02 15:04:05"))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "c.date < ?")
+		args = append(args, f.Until.Format("2006-01-02 15:04:05"))
+	}
+	if f.Owner != "" {
+		conditions = append(conditions, "r.owner = ?")
+		args = append(args, f.Owner)
+	}
+	if f.Repo != "" {
+		conditions = append(conditions, "r.repo = ?")
+		args = append(args, f.Repo)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// ExportRow is a commit joined with its repo's owner and name and its author's username
+type ExportRow struct {
+	ID        int
+	Date      time.Time
+	SHA       string
+	RepoID    int
+	Owner     string
+	Repo      string
+	UserID    int
+	Username  string
+	Inherited bool
+}
+
+// StreamExportRows calls fn with every commit matching the filter joined with its repo and author, in ascending date order. Rows are read from the db
+// as fn is called, so they're never all held in memory. Iteration stops at the first error returned by fn
+func StreamExportRows(ctx context.Context, f Filter, fn func(ExportRow) error) error {
+	conditions, args := f.Conditions()
+	rows, err := db.QueryContext(ctx, `SELECT c.id,c.date,c.sha,r.id,r.owner,r.repo,u.id,u.username,c.inherited
+		FROM commits c
+		JOIN repos r ON r.id = c.repo_id
+		JOIN users u ON u.id = c.user_id
+		WHERE `+conditions+`
+		ORDER BY c.date ASC, c.id ASC`, args...)
+	if err != nil {
+		return fmt.Errorf("error querying commits table for export rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			e    ExportRow
+			date sql.NullTime
+			sha  sql.NullString
+		)
+		err := rows.Scan(&e.ID, &date, &sha, &e.RepoID, &e.Owner, &e.Repo, &e.UserID, &e.Username, &e.Inherited)
+		if err != nil {
+			return fmt.Errorf("error scanning export row for commits table: %v", err)
+		}
+		e.Date = date.Time
+		e.SHA = sha.String
+		err = fn(e)
+		if err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error encountered iterating through commit export rows: %v", err)
+	}
+
+	return nil
+}
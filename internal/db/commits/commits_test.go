@@ -0,0 +1,29 @@
+package commits
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterConditions(t *testing.T) {
+	conditions, args := Filter{}.Conditions()
+	if conditions != "TRUE" || len(args) != 0 {
+		t.Errorf("Result fail. Received %s %v, Expected TRUE without arguments", conditions, args)
+	}
+
+	f := Filter{
+		Since: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2026, time.July, 1, 0, 0, 0, 0, time.UTC),
+		Owner: "Chia-Network",
+	}
+	conditions, args = f.Conditions()
+	expect := "TRUE AND c.date >= ? AND c.date < ? AND r.owner = ?"
+	if conditions != expect {
+		t.Errorf("Result fail. Received %s, Expected %s", conditions, expect)
+	}
+	expectArgs := []any{"2026-01-01 00:00:00", "2026-07-01 00:00:00", "Chia-Network"}
+	if !reflect.DeepEqual(args, expectArgs) {
+		t.Errorf("Result fail. Received %v, Expected %v", args, expectArgs)
+	}
+}
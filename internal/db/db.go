@@ -4,10 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
-	// mysql driver needs comment because linter but this blank import is on purpose
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,12 +16,16 @@ var db *sql.DB
 // Init accepts authentication parameters for a mysql db and creates a client
 // This function may also be configured to create tables in the db on behalf of the application for setup purposes.
 func Init(host, database, user, passwd string) error {
-	// Create db client
-	var err error
-	db, err = sql.Open("mysql", assembleDataSourceName(host, database, user, passwd))
+	// Create db client. Its connections are wrapped so every statement is traced, including those made in transactions
+	cfg, err := mysql.ParseDSN(assembleDataSourceName(host, database, user, passwd))
 	if err != nil {
 		return fmt.Errorf("creating database client: %v", err)
 	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return fmt.Errorf("creating database client: %v", err)
+	}
+	db = sql.OpenDB(tracingConnector{base: connector})
 
 	log.Debug("Creating tables in mysql db if they don't already exist")
 
@@ -30,6 +34,14 @@ func Init(host, database, user, passwd string) error {
 	if err != nil {
 		return fmt.Errorf("creating creating repos table (if it didn't exist): %v", err)
 	}
+	err = migrateReposTable()
+	if err != nil {
+		return fmt.Errorf("adding new columns to repos table (if they didn't exist): %v", err)
+	}
+	err = initRepoAliasesTable()
+	if err != nil {
+		return fmt.Errorf("creating creating repo_aliases table (if it didn't exist): %v", err)
+	}
 	err = initUsersTable()
 	if err != nil {
 		return fmt.Errorf("creating creating users table (if it didn't exist): %v", err)
@@ -38,25 +50,117 @@ func Init(host, database, user, passwd string) error {
 	if err != nil {
 		return fmt.Errorf("creating creating commits table (if it didn't exist): %v", err)
 	}
+	err = migrateCommitsTable()
+	if err != nil {
+		return fmt.Errorf("adding new columns and indexes to commits table (if they didn't exist): %v", err)
+	}
 	err = initSortedCommitsTable()
 	if err != nil {
 		return fmt.Errorf("creating creating sorted_commits table (if it didn't exist): %v", err)
 	}
 
+	err = initRepoMetadataTable()
+	if err != nil {
+		return fmt.Errorf("creating creating repo_metadata table (if it didn't exist): %v", err)
+	}
+	err = initRepoSnapshotsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating repo_snapshots table (if it didn't exist): %v", err)
+	}
+	err = initUserProfilesTable()
+	if err != nil {
+		return fmt.Errorf("creating creating user_profiles table (if it didn't exist): %v", err)
+	}
+	err = initUserOrgsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating user_orgs table (if it didn't exist): %v", err)
+	}
+	err = migrateDeveloperMonthsTable()
+	if err != nil {
+		return fmt.Errorf("dropping developer_months table for a schema change: %v", err)
+	}
+	err = initDeveloperMonthsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating developer_months table (if it didn't exist): %v", err)
+	}
+	err = initCohortRetentionTable()
+	if err != nil {
+		return fmt.Errorf("creating creating cohort_retention table (if it didn't exist): %v", err)
+	}
+	err = initChurnEventsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating churn_events table (if it didn't exist): %v", err)
+	}
+	err = initRepoHealthTable()
+	if err != nil {
+		return fmt.Errorf("creating creating repo_health table (if it didn't exist): %v", err)
+	}
+	err = initReportDeliveriesTable()
+	if err != nil {
+		return fmt.Errorf("creating creating report_deliveries table (if it didn't exist): %v", err)
+	}
+	err = initDiscoveryCandidatesTable()
+	if err != nil {
+		return fmt.Errorf("creating creating discovery_candidates table (if it didn't exist): %v", err)
+	}
+	err = initTagsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating tags table (if it didn't exist): %v", err)
+	}
+	err = initRepoTagsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating repo_tags table (if it didn't exist): %v", err)
+	}
+	err = initLeaderLeasesTable()
+	if err != nil {
+		return fmt.Errorf("creating creating leader_leases table (if it didn't exist): %v", err)
+	}
+	err = initCollectorRunsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating collector_runs table (if it didn't exist): %v", err)
+	}
+	err = initRepoCollectionErrorsTable()
+	if err != nil {
+		return fmt.Errorf("creating creating repo_collection_errors table (if it didn't exist): %v", err)
+	}
+
 	log.Debug("Finished creating tables successfully")
 	log.Info("Finished initializing db package successfully")
 
 	return nil
 }
 
-// Query is an intermediary function to handle database queries on behalf of other packages in this application
-func Query(query string, args ...any) (*sql.Rows, error) {
-	return db.Query(query, args...)
+// QueryContext is an intermediary function to handle database queries on behalf of other packages in this application.
+// Every statement takes a context, so it's cancelled along with whatever it was made for, ie. by a shutdown or a lost leader lease
+func QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext is an intermediary function to handle database queries that return at most one row, for callers that may be cancelled
+func QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext is an intermediary function to handle database queries on behalf of other packages in this application without returning rows
+func ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(ctx, query, args...)
 }
 
-// Exec is an intermediary function to handle database queries on behalf of other packages in this application without returning rows
-func Exec(query string, args ...any) (sql.Result, error) {
-	return db.Exec(query, args...)
+// BeginTx starts a transaction on behalf of other packages in this application. The transaction is rolled back if ctx is cancelled before it's committed.
+// Callers are responsible for committing or rolling back the returned transaction
+func BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return db.BeginTx(ctx, nil)
+}
+
+// ValuesPlaceholders returns the placeholder list for a multi-row INSERT with the given number of rows and columns per row, ie. "(?, ?), (?, ?)"
+func ValuesPlaceholders(rows, cols int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", cols), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+}
+
+// InPlaceholders returns the placeholder list for an IN clause with n members, ie. "(?, ?, ?)"
+func InPlaceholders(n int) string {
+	return ValuesPlaceholders(1, n)
 }
 
 func assembleDataSourceName(host, database, user, passwd string) string {
@@ -79,6 +183,166 @@ func initReposTable() error {
 	return err
 }
 
+// migrateReposTable adds the columns that were added to the repos table after it was first created
+func migrateReposTable() error {
+	err := addColumnIfMissing("repos", "github_id", "BIGINT UNIQUE")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing("repos", "node_id", "VARCHAR(255)")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing("repos", "consecutive_404s", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing("repos", "gone_at", "DATETIME")
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing("repos", "fork_parent", "VARCHAR(512)")
+	if err != nil {
+		return err
+	}
+	return addColumnIfMissing("repos", "fork_parent_id", "INT")
+}
+
+// migrateCommitsTable adds the columns and indexes that were added to the commits table after it was first created
+func migrateCommitsTable() error {
+	err := addColumnIfMissing("commits", "inherited", "BOOLEAN NOT NULL DEFAULT FALSE")
+	if err != nil {
+		return err
+	}
+	err = addIndexIfMissing("commits", "commits_sha", "sha")
+	if err != nil {
+		return err
+	}
+	return addCommitsUniqueIndex()
+}
+
+// addCommitsUniqueIndex adds the unique index on repo_id and sha that keeps a commit from being written to the same repo twice,
+// even by writers in different processes. Copies of a commit written before the index existed are deleted first, keeping the oldest row,
+// along with the sorted_commits rows pointing at them (the sorter rebuilds that table from the commits table anyway)
+func addCommitsUniqueIndex() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelFunc()
+
+	exists, err := indexExists(ctx, "commits", "commits_repo_sha")
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction to remove duplicate commits: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction to remove duplicate commits: %v", err)
+		}
+	}(tx)
+	_, err = tx.ExecContext(ctx, `DELETE sorted_commits FROM sorted_commits
+		JOIN commits dup ON dup.id = sorted_commits.commit_id
+		JOIN commits kept ON kept.repo_id = dup.repo_id AND kept.sha = dup.sha AND kept.id < dup.id;`)
+	if err != nil {
+		return fmt.Errorf("removing sorted_commits rows of duplicate commits: %v", err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE dup FROM commits dup
+		JOIN commits kept ON kept.repo_id = dup.repo_id AND kept.sha = dup.sha AND kept.id < dup.id;`)
+	if err != nil {
+		return fmt.Errorf("removing duplicate commits: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("committing transaction to remove duplicate commits: %v", err)
+	}
+	if removed, err := result.RowsAffected(); err == nil && removed > 0 {
+		log.Warnf("Removed %d duplicate rows from table commits before adding a unique index on repo_id and sha", removed)
+	}
+
+	log.Info("Adding index commits_repo_sha to table commits")
+	_, err = db.ExecContext(ctx, "CREATE UNIQUE INDEX commits_repo_sha ON commits (repo_id, sha);")
+	if err != nil {
+		return fmt.Errorf("adding index commits_repo_sha to table commits: %v", err)
+	}
+	return nil
+}
+
+// indexExists reports whether a table has an index
+func indexExists(ctx context.Context, table, index string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`, table, index).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("checking for index %s in table %s: %v", index, table, err)
+	}
+	return count > 0, nil
+}
+
+// addIndexIfMissing adds an index to an existing table, for indexes that were added after the table was first created
+func addIndexIfMissing(table, index, columns string) error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelFunc()
+
+	exists, err := indexExists(ctx, table, index)
+	if err != nil || exists {
+		return err
+	}
+
+	// Indexing a large table takes a while, so this gets a longer timeout than the other schema changes
+	log.Infof("Adding index %s to table %s", index, table)
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX %s ON %s (%s);", index, table, columns))
+	if err != nil {
+		return fmt.Errorf("adding index %s to table %s: %v", index, table, err)
+	}
+	return nil
+}
+
+// columnExists reports whether a table has a column
+func columnExists(ctx context.Context, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("checking for column %s in table %s: %v", column, table, err)
+	}
+	return count > 0, nil
+}
+
+// addColumnIfMissing adds a column to an existing table, for columns that were added after the table was first created
+func addColumnIfMissing(table, column, definition string) error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+
+	exists, err := columnExists(ctx, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	log.Infof("Adding column %s to table %s", column, table)
+	_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("adding column %s to table %s: %v", column, table, err)
+	}
+	return nil
+}
+
+func initRepoAliasesTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_aliases (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		repo_id INT,
+		owner VARCHAR(255),
+		repo VARCHAR(255),
+		replaced_at DATETIME,
+		UNIQUE(owner,repo),
+		FOREIGN KEY (repo_id) REFERENCES repos(id)
+	);`)
+	return err
+}
+
 func initUsersTable() error {
 	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancelFunc()
@@ -119,3 +383,287 @@ func initSortedCommitsTable() error {
 	);`)
 	return err
 }
+
+func initRepoMetadataTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_metadata (
+		repo_id INT PRIMARY KEY,
+		description TEXT,
+		language VARCHAR(255),
+		topics TEXT,
+		license VARCHAR(255),
+		stars INT,
+		forks INT,
+		watchers INT,
+		archived BOOLEAN,
+		disabled BOOLEAN,
+		default_branch VARCHAR(255),
+		created_at DATETIME,
+		pushed_at DATETIME,
+		refreshed_at DATETIME,
+		FOREIGN KEY (repo_id) REFERENCES repos(id)
+	);`)
+	return err
+}
+
+func initRepoSnapshotsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_snapshots (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		repo_id INT,
+		snapshot_date DATE,
+		stars INT,
+		forks INT,
+		watchers INT,
+		archived BOOLEAN,
+		disabled BOOLEAN,
+		language VARCHAR(255),
+		pushed_at DATETIME,
+		UNIQUE(repo_id,snapshot_date),
+		FOREIGN KEY (repo_id) REFERENCES repos(id)
+	);`)
+	return err
+}
+
+func initUserProfilesTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS user_profiles (
+		user_id INT PRIMARY KEY,
+		github_id BIGINT,
+		name VARCHAR(255),
+		company VARCHAR(255),
+		location VARCHAR(255),
+		account_type VARCHAR(32),
+		github_created_at DATETIME,
+		affiliation VARCHAR(32),
+		refreshed_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`)
+	return err
+}
+
+func initUserOrgsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS user_orgs (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		user_id INT,
+		org VARCHAR(255),
+		UNIQUE(user_id,org),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`)
+	return err
+}
+
+// migrateDeveloperMonthsTable drops a developer_months table from before it had the tag column, so it's created again with the tag in its unique key.
+// The sorter rebuilds the whole table from the commits table on its next run, so nothing is lost
+func migrateDeveloperMonthsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+
+	exists, err := columnExists(ctx, "developer_months", "id")
+	if err != nil || !exists {
+		return err
+	}
+	tagged, err := columnExists(ctx, "developer_months", "tag")
+	if err != nil || tagged {
+		return err
+	}
+
+	log.Info("Dropping table developer_months to add the tag column, it's rebuilt on the next sorter run")
+	_, err = db.ExecContext(ctx, "DROP TABLE developer_months;")
+	return err
+}
+
+func initDeveloperMonthsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS developer_months (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		tag VARCHAR(64) NOT NULL DEFAULT '',
+		user_id INT,
+		month DATE,
+		active_days INT,
+		active_months INT,
+		classification VARCHAR(16),
+		UNIQUE(tag,user_id,month),
+		INDEX(tag,month),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`)
+	return err
+}
+
+func initCohortRetentionTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS cohort_retention (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		cohort_month DATE,
+		months_since INT,
+		cohort_size INT,
+		active_users INT,
+		UNIQUE(cohort_month,months_since)
+	);`)
+	return err
+}
+
+func initChurnEventsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS churn_events (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		user_id INT,
+		event VARCHAR(16),
+		event_date DATE,
+		UNIQUE(user_id,event,event_date),
+		INDEX(event_date),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`)
+	return err
+}
+
+func initRepoHealthTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_health (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		repo_id INT,
+		snapshot_date DATE,
+		contributors_90d INT,
+		contributors_365d INT,
+		bus_factor INT,
+		days_since_last_commit INT,
+		commits_90d INT,
+		commits_prior_90d INT,
+		commit_trend DOUBLE,
+		score DOUBLE,
+		abandoned BOOLEAN,
+		UNIQUE(repo_id,snapshot_date),
+		INDEX(snapshot_date),
+		FOREIGN KEY (repo_id) REFERENCES repos(id)
+	);`)
+	return err
+}
+
+func initReportDeliveriesTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS report_deliveries (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		period DATE,
+		channel VARCHAR(16),
+		target VARCHAR(255),
+		status VARCHAR(16),
+		attempts INT DEFAULT 0,
+		last_error TEXT,
+		created_at DATETIME,
+		last_attempt_at DATETIME,
+		sent_at DATETIME,
+		UNIQUE(period,channel,target),
+		INDEX(status)
+	);`)
+	return err
+}
+
+func initDiscoveryCandidatesTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS discovery_candidates (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		github_id BIGINT UNIQUE,
+		owner VARCHAR(255),
+		repo VARCHAR(255),
+		url VARCHAR(512),
+		description TEXT,
+		language VARCHAR(64),
+		stars INT,
+		fork BOOLEAN,
+		pushed_at DATETIME,
+		queries TEXT,
+		status VARCHAR(16),
+		first_seen DATETIME,
+		last_seen DATETIME,
+		reviewed_at DATETIME,
+		INDEX(status),
+		INDEX(owner,repo)
+	);`)
+	return err
+}
+
+func initTagsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS tags (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		name VARCHAR(64) UNIQUE
+	);`)
+	return err
+}
+
+func initRepoTagsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_tags (
+		repo_id INT,
+		tag_id INT,
+		source VARCHAR(16),
+		created_at DATETIME,
+		PRIMARY KEY(repo_id,tag_id),
+		INDEX(tag_id),
+		FOREIGN KEY (repo_id) REFERENCES repos(id),
+		FOREIGN KEY (tag_id) REFERENCES tags(id)
+	);`)
+	return err
+}
+
+func initLeaderLeasesTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS leader_leases (
+		name VARCHAR(64) PRIMARY KEY,
+		holder VARCHAR(255),
+		acquired_at DATETIME(3),
+		expires_at DATETIME(3)
+	);`)
+	return err
+}
+
+func initCollectorRunsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS collector_runs (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		started_at DATETIME,
+		finished_at DATETIME,
+		status VARCHAR(16),
+		repos_attempted INT DEFAULT 0,
+		repos_succeeded INT DEFAULT 0,
+		repos_failed INT DEFAULT 0,
+		commits_inserted INT DEFAULT 0,
+		INDEX(status)
+	);`)
+	return err
+}
+
+func initRepoCollectionErrorsTable() error {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFunc()
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_collection_errors (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		run_id INT,
+		repo_id INT,
+		repo VARCHAR(512),
+		kind VARCHAR(32),
+		status_code INT,
+		message TEXT,
+		occurred_at DATETIME,
+		INDEX(run_id),
+		INDEX(repo),
+		FOREIGN KEY (run_id) REFERENCES collector_runs(id),
+		FOREIGN KEY (repo_id) REFERENCES repos(id)
+	);`)
+	return err
+}
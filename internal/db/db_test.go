@@ -0,0 +1,30 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestValuesPlaceholders(t *testing.T) {
+	tests := []struct {
+		rows   int
+		cols   int
+		expect string
+	}{
+		{1, 1, "(?)"},
+		{1, 3, "(?, ?, ?)"},
+		{3, 2, "(?, ?), (?, ?), (?, ?)"},
+		{0, 2, ""},
+	}
+	for _, test := range tests {
+		if result := ValuesPlaceholders(test.rows, test.cols); result != test.expect {
+			t.Errorf("Result fail for %d rows and %d cols. Received %s, Expected %s", test.rows, test.cols, result, test.expect)
+		}
+	}
+}
+
+func TestInPlaceholders(t *testing.T) {
+	expect := "(?, ?, ?, ?)"
+	if result := InPlaceholders(4); result != expect {
+		t.Errorf("Result fail. Received %s, Expected %s", result, expect)
+	}
+}
@@ -0,0 +1,118 @@
+package developermonths
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	leaderleases "github.com/chia-network/ecosystem-activity/internal/db/leader_leases"
+	"github.com/chia-network/ecosystem-activity/internal/db/tags"
+	log "github.com/sirupsen/logrus"
+)
+
+// The classifications a developer can have in a month
+const (
+	ClassificationFullTime = "full-time"
+	ClassificationPartTime = "part-time"
+	ClassificationOneTime  = "one-time"
+)
+
+// insertBatchSize is the number of rows written by each multi-row INSERT in ReplaceAllRecords
+const insertBatchSize = 1000
+
+// DeveloperMonth represents all columns in one row of the developer_months table, the classification of one user for one month
+type DeveloperMonth struct {
+	Tag            string // Empty for the classification by commits to every repo, otherwise the tag of the repos the classification counts commits to
+	UserID         int
+	Month          time.Time // The first day of the month
+	ActiveDays     int       // Distinct days with commits in the rolling window ending with this month
+	ActiveMonths   int       // Distinct 28 day periods with commits in the rolling window
+	Classification string
+}
+
+// MonthlyBreakdown counts the developers of each classification in one month
+type MonthlyBreakdown struct {
+	Month    time.Time `json:"month"`
+	FullTime int       `json:"full_time"`
+	PartTime int       `json:"part_time"`
+	OneTime  int       `json:"one_time"`
+	Total    int       `json:"total"`
+}
+
+// ReplaceAllRecords replaces every row in the developer_months table with a new set of rows in one transaction,
+// so readers never see a partially refreshed table. The transaction is fenced on the leader lease, so a replica that lost the lease
+// part way through can't overwrite the new leader's rows
+func ReplaceAllRecords(ctx context.Context, rows []DeveloperMonth) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for developer_months table: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for developer_months table: %v", err)
+		}
+	}(tx)
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM developer_months;`)
+	if err != nil {
+		return fmt.Errorf("error encountered deleting records for developer_months table: %v", err)
+	}
+
+	for start := 0; start < len(rows); start += insertBatchSize {
+		batch := rows[start:min(start+insertBatchSize, len(rows))]
+		args := make([]any, 0, len(batch)*6)
+		for _, r := range batch {
+			args = append(args, r.Tag, r.UserID, r.Month.Format("2006-01-02"), r.ActiveDays, r.ActiveMonths, r.Classification)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO developer_months (tag,user_id,month,active_days,active_months,classification) VALUES `+db.ValuesPlaceholders(len(batch), 6)+`;`, args...)
+		if err != nil {
+			return fmt.Errorf("error encountered inserting records to developer_months table: %v", err)
+		}
+	}
+
+	err = leaderleases.Fence(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("not replacing developer_months table: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction for developer_months table: %v", err)
+	}
+	return nil
+}
+
+// GetMonthlyBreakdown returns the number of developers of each classification per month, in ascending order.
+// With a tag, developers are counted and classified by their commits to repos with the tag alone, by the same rolling window as without one
+func GetMonthlyBreakdown(ctx context.Context, tag string) ([]MonthlyBreakdown, error) {
+	var breakdown []MonthlyBreakdown
+	rows, err := db.QueryContext(ctx, `SELECT month, SUM(classification = ?), SUM(classification = ?), SUM(classification = ?), COUNT(*)
+		FROM developer_months WHERE tag = ? GROUP BY month ORDER BY month ASC`,
+		ClassificationFullTime, ClassificationPartTime, ClassificationOneTime, tags.Normalize(tag))
+	if err != nil {
+		return breakdown, fmt.Errorf("error querying developer_months table for monthly breakdown: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var b MonthlyBreakdown
+		err := rows.Scan(&b.Month, &b.FullTime, &b.PartTime, &b.OneTime, &b.Total)
+		if err != nil {
+			return breakdown, fmt.Errorf("error scanning monthly breakdown row for developer_months table: %v", err)
+		}
+		breakdown = append(breakdown, b)
+	}
+	if err := rows.Err(); err != nil {
+		return breakdown, fmt.Errorf("error encountered iterating through monthly breakdown rows: %v", err)
+	}
+
+	return breakdown, nil
+}
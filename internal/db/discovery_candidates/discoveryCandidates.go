@@ -0,0 +1,151 @@
+package discoverycandidates
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// The review statuses of a candidate
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved" // Collected alongside the repos in the config file
+	StatusRejected = "rejected" // Never suggested again
+)
+
+// Candidate represents all columns in one row of the discovery_candidates table, a repo found by a discovery search that isn't in the config file
+type Candidate struct {
+	ID          int
+	GitHubID    int64
+	Owner       string
+	Repo        string
+	URL         string
+	Description string
+	Language    string
+	Stars       int
+	Fork        bool
+	PushedAt    time.Time
+	Queries     []string // The discovery queries that found the repo
+	Status      string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	ReviewedAt  time.Time // Zero until approved or rejected, including automatically
+}
+
+// candidateWithNulls is used to scan rows that may contain NULLs
+type candidateWithNulls struct {
+	ID          int
+	GitHubID    int64
+	Owner       string
+	Repo        string
+	URL         string
+	Description sql.NullString
+	Language    sql.NullString
+	Stars       int
+	Fork        bool
+	PushedAt    sql.NullTime
+	Queries     sql.NullString
+	Status      string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	ReviewedAt  sql.NullTime
+}
+
+func convertCandidateWithNulls(c candidateWithNulls) Candidate {
+	candidate := Candidate{
+		ID:          c.ID,
+		GitHubID:    c.GitHubID,
+		Owner:       c.Owner,
+		Repo:        c.Repo,
+		URL:         c.URL,
+		Description: c.Description.String,
+		Language:    c.Language.String,
+		Stars:       c.Stars,
+		Fork:        c.Fork,
+		PushedAt:    c.PushedAt.Time,
+		Status:      c.Status,
+		FirstSeen:   c.FirstSeen,
+		LastSeen:    c.LastSeen,
+		ReviewedAt:  c.ReviewedAt.Time,
+	}
+	if c.Queries.String != "" {
+		candidate.Queries = strings.Split(c.Queries.String, "\n")
+	}
+	return candidate
+}
+
+// nullTime stores zero times as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// Upsert inserts a candidate by its GitHub ID, or refreshes the name, stars, activity, and queries of one that was already found.
+// The status is only set on insert, so reviewed candidates keep their review
+func Upsert(ctx context.Context, c Candidate) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO discovery_candidates (github_id,owner,repo,url,description,language,stars,fork,pushed_at,queries,status,first_seen,last_seen,reviewed_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		owner = VALUES(owner), repo = VALUES(repo), url = VALUES(url), description = VALUES(description), language = VALUES(language),
+		stars = VALUES(stars), fork = VALUES(fork), pushed_at = VALUES(pushed_at), queries = VALUES(queries), last_seen = VALUES(last_seen);`,
+		c.GitHubID, c.Owner, c.Repo, c.URL, c.Description, c.Language, c.Stars, c.Fork, nullTime(c.PushedAt), strings.Join(c.Queries, "\n"),
+		c.Status, c.FirstSeen.Format("2006-01-02 15:04:05"), c.LastSeen.Format("2006-01-02 15:04:05"), nullTime(c.ReviewedAt))
+	if err != nil {
+		return fmt.Errorf("error encountered upserting discovery_candidates row for %s/%s: %v", c.Owner, c.Repo, err)
+	}
+	return nil
+}
+
+// GetRows returns the candidates with a status, or every candidate for an empty status, with the most starred first
+func GetRows(ctx context.Context, status string) ([]Candidate, error) {
+	var candidates []Candidate
+	query := `SELECT id,github_id,owner,repo,url,description,language,stars,fork,pushed_at,queries,status,first_seen,last_seen,reviewed_at FROM discovery_candidates`
+	var args []any
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY stars DESC, owner, repo", args...)
+	if err != nil {
+		return candidates, fmt.Errorf("error querying discovery_candidates table for rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var c candidateWithNulls
+		err := rows.Scan(&c.ID, &c.GitHubID, &c.Owner, &c.Repo, &c.URL, &c.Description, &c.Language, &c.Stars, &c.Fork, &c.PushedAt, &c.Queries,
+			&c.Status, &c.FirstSeen, &c.LastSeen, &c.ReviewedAt)
+		if err != nil {
+			return candidates, fmt.Errorf("error scanning row for discovery_candidates table: %v", err)
+		}
+		candidates = append(candidates, convertCandidateWithNulls(c))
+	}
+	if err := rows.Err(); err != nil {
+		return candidates, fmt.Errorf("error encountered iterating through discovery_candidates rows: %v", err)
+	}
+
+	return candidates, nil
+}
+
+// SetStatusByName reviews a candidate by owner and repo name, returning false when there's no such candidate
+func SetStatusByName(ctx context.Context, owner, repo, status string, now time.Time) (bool, error) {
+	result, err := db.ExecContext(ctx, `UPDATE discovery_candidates SET status = ?, reviewed_at = ? WHERE owner = ? AND repo = ?;`,
+		status, now.Format("2006-01-02 15:04:05"), owner, repo)
+	if err != nil {
+		return false, fmt.Errorf("error encountered updating status of discovery_candidates row for %s/%s: %v", owner, repo, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected updating discovery_candidates row for %s/%s: %v", owner, repo, err)
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,76 @@
+package leaderleases
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+)
+
+// TryAcquire takes the named lease for holder, or renews it if holder already has it, so it's held until ttl from now.
+// The lease is only taken from another holder once it has expired or been released. Expiry is judged by the db's clock,
+// so replicas with skewed clocks still agree on it. Returns whether holder has the lease
+func TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	_, err := db.ExecContext(ctx, `INSERT IGNORE INTO leader_leases (name,holder,acquired_at,expires_at) VALUES(?, ?, NOW(3), NOW(3) + INTERVAL ? MICROSECOND);`,
+		name, holder, ttl.Microseconds())
+	if err != nil {
+		return false, fmt.Errorf("error encountered adding lease %s to leader_leases table: %v", name, err)
+	}
+
+	// acquired_at is assigned first so it's compared with the previous holder, and only reset when the lease changes hands
+	_, err = db.ExecContext(ctx, `UPDATE leader_leases SET acquired_at = IF(holder = ?, acquired_at, NOW(3)), holder = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND
+		WHERE name = ? AND (holder = ? OR holder IS NULL OR expires_at < NOW(3));`, holder, holder, ttl.Microseconds(), name, holder)
+	if err != nil {
+		return false, fmt.Errorf("error encountered acquiring lease %s in leader_leases table: %v", name, err)
+	}
+
+	var current sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT holder FROM leader_leases WHERE name = ?;`, name).Scan(&current)
+	if err != nil {
+		return false, fmt.Errorf("error querying leader_leases table for holder of lease %s: %v", name, err)
+	}
+	return current.String == holder, nil
+}
+
+// Release gives up the named lease if holder has it, so another holder can take it without waiting for it to expire
+func Release(ctx context.Context, name, holder string) error {
+	_, err := db.ExecContext(ctx, `UPDATE leader_leases SET holder = NULL WHERE name = ? AND holder = ?;`, name, holder)
+	if err != nil {
+		return fmt.Errorf("error encountered releasing lease %s in leader_leases table: %v", name, err)
+	}
+	return nil
+}
+
+// leaseKey is the context key for the lease a context's work is done under
+type leaseKey struct{}
+
+type lease struct {
+	name   string
+	holder string
+}
+
+// WithLease returns a context for work done while holder has the named lease, so the transactions made with it can be fenced with Fence
+func WithLease(ctx context.Context, name, holder string) context.Context {
+	return context.WithValue(ctx, leaseKey{}, lease{name: name, holder: holder})
+}
+
+// Fence checks, as part of a transaction, that the lease ctx was made with by WithLease is still held and hasn't expired. The lease row is read with
+// a shared lock held until the transaction ends, so the lease can't change hands before the transaction commits. Call it just before committing
+// a transaction that a replica which lost the lease mustn't commit, so it can't race the new leader. Contexts without a lease, ie. for ad-hoc commands, always pass
+func Fence(ctx context.Context, tx *sql.Tx) error {
+	l, ok := ctx.Value(leaseKey{}).(lease)
+	if !ok {
+		return nil
+	}
+	var held bool
+	err := tx.QueryRowContext(ctx, `SELECT IFNULL(holder = ? AND expires_at > NOW(3), FALSE) FROM leader_leases WHERE name = ? LOCK IN SHARE MODE;`, l.holder, l.name).Scan(&held)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error querying leader_leases table for holder of lease %s: %v", l.name, err)
+	}
+	if !held {
+		return fmt.Errorf("%s no longer holds the leader lease %s", l.holder, l.name)
+	}
+	return nil
+}
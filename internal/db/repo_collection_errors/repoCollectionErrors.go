@@ -0,0 +1,91 @@
+package repocollectionerrors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	collectorruns "github.com/chia-network/ecosystem-activity/internal/db/collector_runs"
+	log "github.com/sirupsen/logrus"
+)
+
+// The kinds of errors collecting a repo can fail with
+const (
+	KindNotFound    = "not_found"    // GitHub returned a 404 for the repo
+	KindRateLimited = "rate_limited" // GitHub returned a 403 or 429, which it uses for rate limits
+	KindGitHub      = "github"       // Any other failed GitHub request
+	KindDatabase    = "database"     // Reading or writing the db failed
+	KindInvalidURL  = "invalid_url"  // The repo URL in config couldn't be parsed, or isn't on a supported host
+)
+
+// CollectionError represents all columns in one row of the repo_collection_errors table, one failed attempt to collect a repo
+type CollectionError struct {
+	ID         int
+	RunID      int
+	RepoID     int    // Zero when the repo isn't in the repos table
+	Repo       string // The repo's owner/repo, or its URL from config when that couldn't be parsed
+	Kind       string
+	StatusCode int // The status code of the GitHub response, zero when there wasn't one
+	Message    string
+	OccurredAt time.Time
+}
+
+// SetNewRecord inserts one new record into the table
+func SetNewRecord(ctx context.Context, e CollectionError) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO repo_collection_errors (run_id,repo_id,repo,kind,status_code,message,occurred_at) VALUES(?, ?, ?, ?, ?, ?, ?);`,
+		e.RunID, sql.NullInt64{Int64: int64(e.RepoID), Valid: e.RepoID != 0}, e.Repo, e.Kind, e.StatusCode, e.Message, e.OccurredAt.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("error encountered adding repo_collection_errors row for %s: %v", e.Repo, err)
+	}
+	return nil
+}
+
+// RepeatedFailure summarizes the errors of a repo that failed in every one of the most recent finished runs
+type RepeatedFailure struct {
+	Repo        string
+	Runs        int       // The number of runs it failed in
+	FirstFailed time.Time // The earliest failure among those runs
+	Last        CollectionError
+}
+
+// GetRepeatedFailures returns the repos that failed in each of the last runs finished runs (or every finished run, if there are fewer),
+// with the repos that have been failing longest first
+func GetRepeatedFailures(ctx context.Context, runs int) ([]RepeatedFailure, error) {
+	var failures []RepeatedFailure
+	recentRuns := `SELECT id FROM collector_runs WHERE status = ? ORDER BY id DESC LIMIT ?`
+	rows, err := db.QueryContext(ctx, `SELECT f.repo, f.runs, f.first_failed, l.id, l.run_id, l.repo_id, l.kind, l.status_code, l.message, l.occurred_at
+		FROM (SELECT e.repo, COUNT(DISTINCT e.run_id) AS runs, MIN(e.occurred_at) AS first_failed, MAX(e.id) AS last_id
+			FROM repo_collection_errors e JOIN (`+recentRuns+`) r ON r.id = e.run_id GROUP BY e.repo) f
+		JOIN repo_collection_errors l ON l.id = f.last_id
+		WHERE f.runs = (SELECT COUNT(*) FROM (`+recentRuns+`) n)
+		ORDER BY f.first_failed ASC, f.repo ASC`, collectorruns.StatusFinished, runs, collectorruns.StatusFinished, runs)
+	if err != nil {
+		return failures, fmt.Errorf("error querying repo_collection_errors table for repeated failures: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			f      RepeatedFailure
+			repoID sql.NullInt64
+		)
+		err := rows.Scan(&f.Repo, &f.Runs, &f.FirstFailed, &f.Last.ID, &f.Last.RunID, &repoID, &f.Last.Kind, &f.Last.StatusCode, &f.Last.Message, &f.Last.OccurredAt)
+		if err != nil {
+			return failures, fmt.Errorf("error scanning repeated failure row for repo_collection_errors table: %v", err)
+		}
+		f.Last.Repo, f.Last.RepoID = f.Repo, int(repoID.Int64)
+		failures = append(failures, f)
+	}
+	if err := rows.Err(); err != nil {
+		return failures, fmt.Errorf("error encountered iterating through repeated failure rows: %v", err)
+	}
+
+	return failures, nil
+}
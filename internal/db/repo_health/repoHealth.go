@@ -0,0 +1,120 @@
+package repohealth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/tags"
+	log "github.com/sirupsen/logrus"
+)
+
+// RepoHealth represents all columns in one row of the repo_health table, the health of one repo on one snapshot date
+type RepoHealth struct {
+	RepoID              int       `json:"repo_id"`
+	Owner               string    `json:"owner"` // From the repos table when read back
+	Repo                string    `json:"repo"`  // From the repos table when read back
+	SnapshotDate        time.Time `json:"snapshot_date"`
+	Contributors90d     int       `json:"contributors_90d"`
+	Contributors365d    int       `json:"contributors_365d"`
+	BusFactor           int       `json:"bus_factor"`             // The smallest number of authors covering half of the trailing 365 days of commits
+	DaysSinceLastCommit int       `json:"days_since_last_commit"` // -1 for repos without any commits
+	Commits90d          int       `json:"commits_90d"`
+	CommitsPrior90d     int       `json:"commits_prior_90d"` // Commits in the 90 days before the trailing 90 days
+	CommitTrend         float64   `json:"commit_trend"`      // Percent change from the prior 90 days of commits to the trailing 90 days
+	Score               float64   `json:"score"`             // The composite health score, out of 100
+	Abandoned           bool      `json:"abandoned"`
+}
+
+// SetRecords inserts or replaces the health rows for a snapshot date in one transaction
+func SetRecords(ctx context.Context, rows []RepoHealth) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for repo_health table: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for repo_health table: %v", err)
+		}
+	}(tx)
+
+	for _, h := range rows {
+		daysSince := sql.NullInt64{Int64: int64(h.DaysSinceLastCommit), Valid: h.DaysSinceLastCommit >= 0}
+		_, err = tx.ExecContext(ctx, `INSERT INTO repo_health (repo_id,snapshot_date,contributors_90d,contributors_365d,bus_factor,days_since_last_commit,commits_90d,commits_prior_90d,commit_trend,score,abandoned)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+			contributors_90d = VALUES(contributors_90d), contributors_365d = VALUES(contributors_365d), bus_factor = VALUES(bus_factor),
+			days_since_last_commit = VALUES(days_since_last_commit), commits_90d = VALUES(commits_90d), commits_prior_90d = VALUES(commits_prior_90d),
+			commit_trend = VALUES(commit_trend), score = VALUES(score), abandoned = VALUES(abandoned);`,
+			h.RepoID, h.SnapshotDate.Format("2006-01-02"), h.Contributors90d, h.Contributors365d, h.BusFactor, daysSince,
+			h.Commits90d, h.CommitsPrior90d, h.CommitTrend, h.Score, h.Abandoned)
+		if err != nil {
+			return fmt.Errorf("error encountered setting repo_health row for repo ID %d: %v", h.RepoID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction for repo_health table: %v", err)
+	}
+	return nil
+}
+
+// GetLatest returns the most recent health row of every repo, from the lowest score to the highest. When abandonedOnly is set only abandoned repos are returned,
+// and with a tag only repos with the tag are returned
+func GetLatest(ctx context.Context, abandonedOnly bool, tag string) ([]RepoHealth, error) {
+	var health []RepoHealth
+	query := `SELECT h.repo_id,repos.owner,repos.repo,h.snapshot_date,h.contributors_90d,h.contributors_365d,h.bus_factor,h.days_since_last_commit,
+		h.commits_90d,h.commits_prior_90d,h.commit_trend,h.score,h.abandoned
+		FROM repo_health h
+		JOIN repos ON repos.id = h.repo_id
+		JOIN (SELECT repo_id, MAX(snapshot_date) AS snapshot_date FROM repo_health GROUP BY repo_id) latest
+			ON latest.repo_id = h.repo_id AND latest.snapshot_date = h.snapshot_date`
+	conditions := []string{"TRUE"}
+	var args []any
+	if abandonedOnly {
+		conditions = append(conditions, "h.abandoned")
+	}
+	if tag != "" {
+		condition, tagArgs := tags.RepoCondition("h.repo_id", tag)
+		conditions = append(conditions, condition)
+		args = append(args, tagArgs...)
+	}
+	rows, err := db.QueryContext(ctx, query+" WHERE "+strings.Join(conditions, " AND ")+" ORDER BY h.score ASC, repos.owner, repos.repo", args...)
+	if err != nil {
+		return health, fmt.Errorf("error querying repo_health table for latest rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			h         RepoHealth
+			daysSince sql.NullInt64
+		)
+		err := rows.Scan(&h.RepoID, &h.Owner, &h.Repo, &h.SnapshotDate, &h.Contributors90d, &h.Contributors365d, &h.BusFactor, &daysSince,
+			&h.Commits90d, &h.CommitsPrior90d, &h.CommitTrend, &h.Score, &h.Abandoned)
+		if err != nil {
+			return health, fmt.Errorf("error scanning row for repo_health table: %v", err)
+		}
+		h.DaysSinceLastCommit = -1
+		if daysSince.Valid {
+			h.DaysSinceLastCommit = int(daysSince.Int64)
+		}
+		health = append(health, h)
+	}
+	if err := rows.Err(); err != nil {
+		return health, fmt.Errorf("error encountered iterating through repo_health rows: %v", err)
+	}
+
+	return health, nil
+}
@@ -0,0 +1,62 @@
+package repometadata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RepoMetadata represents all columns in one row of the repo_metadata table, which holds the latest known GitHub attributes of a repo
+type RepoMetadata struct {
+	RepoID        int
+	Description   string
+	Language      string
+	Topics        []string
+	License       string
+	Stars         int
+	Forks         int
+	Watchers      int
+	Archived      bool
+	Disabled      bool
+	DefaultBranch string
+	CreatedAt     time.Time
+	PushedAt      time.Time
+	RefreshedAt   time.Time
+}
+
+// nullTime stores zero times as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// Upsert inserts or replaces the metadata row for a repo, as part of a transaction
+func Upsert(ctx context.Context, tx *sql.Tx, m RepoMetadata) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO repo_metadata (repo_id,description,language,topics,license,stars,forks,watchers,archived,disabled,default_branch,created_at,pushed_at,refreshed_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		description = VALUES(description), language = VALUES(language), topics = VALUES(topics), license = VALUES(license),
+		stars = VALUES(stars), forks = VALUES(forks), watchers = VALUES(watchers), archived = VALUES(archived), disabled = VALUES(disabled),
+		default_branch = VALUES(default_branch), created_at = VALUES(created_at), pushed_at = VALUES(pushed_at), refreshed_at = VALUES(refreshed_at);`,
+		m.RepoID, m.Description, m.Language, strings.Join(m.Topics, ","), m.License, m.Stars, m.Forks, m.Watchers, m.Archived, m.Disabled,
+		m.DefaultBranch, nullTime(m.CreatedAt), nullTime(m.PushedAt), m.RefreshedAt.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("error encountered upserting repo_metadata row for repo ID %d: %v", m.RepoID, err)
+	}
+	return nil
+}
+
+// SetSnapshot records the metadata's counts and flags in the repo_snapshots table for a day, replacing any snapshot already taken that day, as part of a transaction
+func SetSnapshot(ctx context.Context, tx *sql.Tx, m RepoMetadata, day time.Time) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO repo_snapshots (repo_id,snapshot_date,stars,forks,watchers,archived,disabled,language,pushed_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		stars = VALUES(stars), forks = VALUES(forks), watchers = VALUES(watchers), archived = VALUES(archived), disabled = VALUES(disabled),
+		language = VALUES(language), pushed_at = VALUES(pushed_at);`,
+		m.RepoID, day.Format("2006-01-02"), m.Stars, m.Forks, m.Watchers, m.Archived, m.Disabled, m.Language, nullTime(m.PushedAt))
+	if err != nil {
+		return fmt.Errorf("error encountered setting repo_snapshots row for repo ID %d: %v", m.RepoID, err)
+	}
+	return nil
+}
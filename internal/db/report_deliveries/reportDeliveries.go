@@ -0,0 +1,127 @@
+package reportdeliveries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// The statuses of a delivery
+const (
+	StatusPending = "pending" // Not sent yet, including deliveries that failed and will be retried
+	StatusSent    = "sent"
+	StatusFailed  = "failed" // Gave up after the maximum number of attempts
+)
+
+// Delivery represents all columns in one row of the report_deliveries table, the delivery of one month's report to one recipient
+type Delivery struct {
+	ID            int
+	Period        time.Time
+	Channel       string // The delivery channel, ie. email or webhook
+	Target        string // The email address, or the webhook's host
+	Status        string
+	Attempts      int
+	LastError     string
+	CreatedAt     time.Time
+	LastAttemptAt time.Time // Zero before the first attempt
+	SentAt        time.Time // Zero until sent
+}
+
+// deliveryWithNulls is used to scan rows that may contain NULLs
+type deliveryWithNulls struct {
+	ID            int
+	Period        time.Time
+	Channel       string
+	Target        string
+	Status        string
+	Attempts      int
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	LastAttemptAt sql.NullTime
+	SentAt        sql.NullTime
+}
+
+func convertDeliveryWithNulls(d deliveryWithNulls) Delivery {
+	return Delivery{
+		ID:            d.ID,
+		Period:        d.Period,
+		Channel:       d.Channel,
+		Target:        d.Target,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		LastError:     d.LastError.String,
+		CreatedAt:     d.CreatedAt,
+		LastAttemptAt: d.LastAttemptAt.Time,
+		SentAt:        d.SentAt.Time,
+	}
+}
+
+// Ensure creates a pending delivery of a month's report to a recipient, doing nothing if that delivery already exists
+func Ensure(ctx context.Context, period time.Time, channel, target string, now time.Time) error {
+	_, err := db.ExecContext(ctx, `INSERT IGNORE INTO report_deliveries (period,channel,target,status,attempts,created_at) VALUES(?, ?, ?, ?, 0, ?);`,
+		period.Format("2006-01-02"), channel, target, StatusPending, now.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("error encountered creating report_deliveries row for %s %s: %v", channel, target, err)
+	}
+	return nil
+}
+
+// GetPending returns every delivery that hasn't been sent or given up on, oldest period first
+func GetPending(ctx context.Context) ([]Delivery, error) {
+	var deliveries []Delivery
+	rows, err := db.QueryContext(ctx, `SELECT id,period,channel,target,status,attempts,last_error,created_at,last_attempt_at,sent_at
+		FROM report_deliveries WHERE status = ? ORDER BY period, id`, StatusPending)
+	if err != nil {
+		return deliveries, fmt.Errorf("error querying report_deliveries table for pending rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var d deliveryWithNulls
+		err := rows.Scan(&d.ID, &d.Period, &d.Channel, &d.Target, &d.Status, &d.Attempts, &d.LastError, &d.CreatedAt, &d.LastAttemptAt, &d.SentAt)
+		if err != nil {
+			return deliveries, fmt.Errorf("error scanning row for report_deliveries table: %v", err)
+		}
+		deliveries = append(deliveries, convertDeliveryWithNulls(d))
+	}
+	if err := rows.Err(); err != nil {
+		return deliveries, fmt.Errorf("error encountered iterating through report_deliveries rows: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// Claim takes a pending delivery for an attempt, counting the attempt before anything is sent so no other run attempts it too. It returns false when
+// another run has already claimed or finished the delivery since it was read, in which case it must not be sent
+func Claim(ctx context.Context, d Delivery, now time.Time) (bool, error) {
+	result, err := db.ExecContext(ctx, `UPDATE report_deliveries SET attempts = attempts + 1, last_attempt_at = ? WHERE id = ? AND status = ? AND attempts = ?;`,
+		now.Format("2006-01-02 15:04:05"), d.ID, StatusPending, d.Attempts)
+	if err != nil {
+		return false, fmt.Errorf("error encountered claiming report_deliveries row ID %d: %v", d.ID, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected claiming report_deliveries row ID %d: %v", d.ID, err)
+	}
+	return affected > 0, nil
+}
+
+// UpdateAttempt records the outcome of an attempt claimed with Claim, with the delivery's new status, attempt count, and last error
+func UpdateAttempt(ctx context.Context, d Delivery) error {
+	sentAt := sql.NullTime{Time: d.SentAt, Valid: !d.SentAt.IsZero()}
+	_, err := db.ExecContext(ctx, `UPDATE report_deliveries SET status = ?, attempts = ?, last_error = ?, last_attempt_at = ?, sent_at = ? WHERE id = ?;`,
+		d.Status, d.Attempts, d.LastError, d.LastAttemptAt.Format("2006-01-02 15:04:05"), sentAt, d.ID)
+	if err != nil {
+		return fmt.Errorf("error encountered updating report_deliveries row ID %d: %v", d.ID, err)
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package repos
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,6 +22,12 @@ type Repo struct {
 	FirstCommit     time.Time
 	LastCommit      time.Time
 	Notes           string
+	GitHubID        int64  // GitHub's stable database ID for the repo, which survives renames and transfers
+	NodeID          string // GitHub's stable GraphQL node ID for the repo
+	Consecutive404s int
+	GoneAt          time.Time // Set once the repo has returned enough 404s in a row to be considered deleted
+	ForkParent      string    // The owner/repo this repo was forked from on GitHub, empty for repos that aren't forks
+	ForkParentID    int       // The row ID of the fork parent, when it's in the repos table too
 }
 
 // repoWithNulls is a helper struct for mysql rows that may contain null fields
@@ -30,8 +40,17 @@ type repoWithNulls struct {
 	FirstCommit     sql.NullTime
 	LastCommit      sql.NullTime
 	Notes           sql.NullString
+	GitHubID        sql.NullInt64
+	NodeID          sql.NullString
+	Consecutive404s sql.NullInt64
+	GoneAt          sql.NullTime
+	ForkParent      sql.NullString
+	ForkParentID    sql.NullInt64
 }
 
+// repoColumns is the column list scanned by scanRepoRows, qualified with the repos table name so it can be used in joins
+const repoColumns = "repos.id,repos.owner,repos.repo,repos.imported_through,repos.first_commit,repos.last_commit,repos.notes,repos.github_id,repos.node_id,repos.consecutive_404s,repos.gone_at,repos.fork_parent,repos.fork_parent_id"
+
 // convertSQLRepoToRepo handles the internal conversion between an sql row response and a user-friendly repo struct
 // because Go's sql package errors when scanning nil columns in a row
 func convertSQLRepoToRepo(r repoWithNulls) Repo {
@@ -57,43 +76,84 @@ func convertSQLRepoToRepo(r repoWithNulls) Repo {
 	if r.Notes.Valid {
 		repo.Notes = r.Notes.String
 	}
+	if r.GitHubID.Valid {
+		repo.GitHubID = r.GitHubID.Int64
+	}
+	if r.NodeID.Valid {
+		repo.NodeID = r.NodeID.String
+	}
+	if r.Consecutive404s.Valid {
+		repo.Consecutive404s = int(r.Consecutive404s.Int64)
+	}
+	if r.GoneAt.Valid {
+		repo.GoneAt = r.GoneAt.Time
+	}
+	if r.ForkParent.Valid {
+		repo.ForkParent = r.ForkParent.String
+	}
+	if r.ForkParentID.Valid {
+		repo.ForkParentID = int(r.ForkParentID.Int64)
+	}
 	return repo
 }
 
-// GetRowsByOwnerAndRepo returns the rows where the owner and repo both match (should be one row)
-func GetRowsByOwnerAndRepo(owner, repo string) ([]Repo, error) {
+// scanRepoRows runs a query selecting repoColumns and returns the rows it found
+// desc describes the query for error messages
+func scanRepoRows(ctx context.Context, desc string, query string, args ...any) ([]Repo, error) {
 	var repos []Repo
-	rows, err := db.Query("SELECT id,owner,repo,imported_through,first_commit,last_commit,notes FROM repos WHERE owner = ? AND repo = ?", owner, repo)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return repos, fmt.Errorf("error querying repos table for rows by owner \"%s\" and repo \"%s\": %v", owner, repo, err)
+		return repos, fmt.Errorf("error querying repos table for rows %s: %v", desc, err)
 	}
 	defer func(r *sql.Rows) {
 		err := r.Close()
 		if err != nil {
-			log.Errorf("error closing sql rows: %v", err)
+			logging.FromContext(ctx).Errorf("error closing sql rows: %v", err)
 		}
 	}(rows)
 
 	for rows.Next() {
 		var r repoWithNulls
-		err := rows.Scan(&r.ID, &r.Owner, &r.Repo, &r.ImportedThrough, &r.FirstCommit, &r.LastCommit, &r.Notes)
+		err := rows.Scan(&r.ID, &r.Owner, &r.Repo, &r.ImportedThrough, &r.FirstCommit, &r.LastCommit, &r.Notes, &r.GitHubID, &r.NodeID, &r.Consecutive404s, &r.GoneAt, &r.ForkParent, &r.ForkParentID)
 		if err != nil {
-			return repos, fmt.Errorf("error scanning row for owner \"%s\" and repo \"%s\": %v", owner, repo, err)
+			return repos, fmt.Errorf("error scanning row %s: %v", desc, err)
 		}
 
 		nonNullRepo := convertSQLRepoToRepo(r)
 		repos = append(repos, nonNullRepo)
 	}
 	if err := rows.Err(); err != nil {
-		return repos, fmt.Errorf("error encountered iterating through rows for owner \"%s\" and repo \"%s\": %v", owner, repo, err)
+		return repos, fmt.Errorf("error encountered iterating through rows %s: %v", desc, err)
 	}
 
 	return repos, nil
 }
 
+// GetRowsByOwnerAndRepo returns the rows where the owner and repo both match (should be one row)
+func GetRowsByOwnerAndRepo(ctx context.Context, owner, repo string) ([]Repo, error) {
+	return scanRepoRows(ctx, fmt.Sprintf("for owner \"%s\" and repo \"%s\"", owner, repo), "SELECT "+repoColumns+" FROM repos WHERE owner = ? AND repo = ?", owner, repo)
+}
+
+// GetRowsByAlias returns the rows that used to be named owner/repo before being renamed or transferred (should be at most one row)
+func GetRowsByAlias(ctx context.Context, owner, repo string) ([]Repo, error) {
+	return scanRepoRows(ctx, fmt.Sprintf("for alias \"%s/%s\"", owner, repo), "SELECT "+repoColumns+" FROM repos JOIN repo_aliases ON repo_aliases.repo_id = repos.id WHERE repo_aliases.owner = ? AND repo_aliases.repo = ?", owner, repo)
+}
+
+// GetRowsByGitHubID returns the rows matching a GitHub repo ID (should be at most one row)
+func GetRowsByGitHubID(ctx context.Context, githubID int64) ([]Repo, error) {
+	return scanRepoRows(ctx, fmt.Sprintf("for GitHub ID %d", githubID), "SELECT "+repoColumns+" FROM repos WHERE github_id = ?", githubID)
+}
+
+// GetAllRows returns every row in the repos table
+func GetAllRows(ctx context.Context) ([]Repo, error) {
+	return scanRepoRows(ctx, "for all repos", "SELECT "+repoColumns+" FROM repos ORDER BY id ASC")
+}
+
 // SetNewRecord inserts one new record into the table
-func SetNewRecord(repo Repo) error {
-	_, err := db.Exec(`INSERT INTO repos (owner,repo) VALUES(?, ?);`, repo.Owner, repo.Repo)
+func SetNewRecord(ctx context.Context, repo Repo) error {
+	githubID := sql.NullInt64{Int64: repo.GitHubID, Valid: repo.GitHubID != 0}
+	nodeID := sql.NullString{String: repo.NodeID, Valid: repo.NodeID != ""}
+	_, err := db.ExecContext(ctx, `INSERT INTO repos (owner,repo,github_id,node_id) VALUES(?, ?, ?, ?);`, repo.Owner, repo.Repo, githubID, nodeID)
 	if err != nil {
 		return fmt.Errorf("error adding repo to repos table for \"%s\" and repo \"%s\": %v", repo.Owner, repo.Repo, err)
 	}
@@ -101,9 +161,22 @@ func SetNewRecord(repo Repo) error {
 	return nil
 }
 
+// Create inserts a repo by owner and name as part of a transaction, returning its row ID
+func Create(ctx context.Context, tx *sql.Tx, owner, repo string) (int, error) {
+	result, err := tx.ExecContext(ctx, `INSERT INTO repos (owner,repo) VALUES(?, ?);`, owner, repo)
+	if err != nil {
+		return 0, fmt.Errorf("error adding repo to repos table for \"%s\" and repo \"%s\": %v", owner, repo, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting row ID of new repo \"%s/%s\": %v", owner, repo, err)
+	}
+	return int(id), nil
+}
+
 // UpdateLastCommitByID accepts a row ID and time object and updates the matching row's last_commit column to the timestamp
-func UpdateLastCommitByID(id int, ts time.Time) error {
-	_, err := db.Exec(`UPDATE repos SET last_commit=? WHERE id=?;`, ts.Format("2006-01-02 15:04:05"), id)
+func UpdateLastCommitByID(ctx context.Context, id int, ts time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET last_commit=? WHERE id=?;`, ts.Format("2006-01-02 15:04:05"), id)
 	if err != nil {
 		return fmt.Errorf("error encountered updating last_commit on row ID %d: %v", id, err)
 	}
@@ -111,8 +184,8 @@ func UpdateLastCommitByID(id int, ts time.Time) error {
 }
 
 // UpdateFirstCommitByID accepts a row ID and time object and updates the matching row's first_commit column to the timestamp
-func UpdateFirstCommitByID(id int, ts time.Time) error {
-	_, err := db.Exec(`UPDATE repos SET first_commit=? WHERE id=?;`, ts.Format("2006-01-02 15:04:05"), id)
+func UpdateFirstCommitByID(ctx context.Context, id int, ts time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET first_commit=? WHERE id=?;`, ts.Format("2006-01-02 15:04:05"), id)
 	if err != nil {
 		return fmt.Errorf("error encountered updating first_commit on row ID %d: %v", id, err)
 	}
@@ -120,10 +193,275 @@ func UpdateFirstCommitByID(id int, ts time.Time) error {
 }
 
 // UpdateImportedThroughByID accepts a row ID and time object and updates the matching row's imported_through column to the timestamp
-func UpdateImportedThroughByID(id int, ts time.Time) error {
-	_, err := db.Exec(`UPDATE repos SET imported_through=? WHERE id=?;`, ts.Format("2006-01-02 15:04:05"), id)
+func UpdateImportedThroughByID(ctx context.Context, id int, ts time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET imported_through=? WHERE id=?;`, ts.Format("2006-01-02 15:04:05"), id)
 	if err != nil {
 		return fmt.Errorf("error encountered updating imported_through on row ID %d: %v", id, err)
 	}
 	return err
 }
+
+// UpdateCommitRangeByID widens the matching row's first_commit and last_commit columns to include the given timestamps
+// and sets imported_through, as part of a transaction. Zero timestamps leave their column untouched.
+func UpdateCommitRangeByID(ctx context.Context, tx *sql.Tx, id int, first, last, importedThrough time.Time) error {
+	var (
+		sets []string
+		args []any
+	)
+	if !first.IsZero() {
+		sets = append(sets, "first_commit = LEAST(COALESCE(first_commit, ?), ?)")
+		args = append(args, first.Format("2006-01-02 15:04:05"), first.Format("2006-01-02 15:04:05"))
+	}
+	if !last.IsZero() {
+		sets = append(sets, "last_commit = GREATEST(COALESCE(last_commit, ?), ?)")
+		args = append(args, last.Format("2006-01-02 15:04:05"), last.Format("2006-01-02 15:04:05"))
+	}
+	if !importedThrough.IsZero() {
+		sets = append(sets, "imported_through = ?")
+		args = append(args, importedThrough.Format("2006-01-02 15:04:05"))
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	args = append(args, id)
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE repos SET %s WHERE id=?;`, strings.Join(sets, ", ")), args...)
+	if err != nil {
+		return fmt.Errorf("error encountered updating commit range on row ID %d: %v", id, err)
+	}
+	return nil
+}
+
+// UpdateGitHubIDByID accepts a row ID and sets the GitHub repo ID and node ID of the matching row
+func UpdateGitHubIDByID(ctx context.Context, id int, githubID int64, nodeID string) error {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET github_id=?, node_id=? WHERE id=?;`, githubID, nodeID, id)
+	if err != nil {
+		return fmt.Errorf("error encountered updating github_id on row ID %d: %v", id, err)
+	}
+	return nil
+}
+
+// Rename moves a repo row to a new owner and name after a rename or transfer on GitHub, keeping its old name in the repo_aliases table
+func Rename(ctx context.Context, id int, newOwner, newRepo string) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction to rename row ID %d: %v", id, err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			logging.FromContext(ctx).Errorf("error rolling back transaction to rename row ID %d: %v", id, err)
+		}
+	}(tx)
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	_, err = tx.ExecContext(ctx, `INSERT INTO repo_aliases (repo_id,owner,repo,replaced_at) SELECT id, owner, repo, ? FROM repos WHERE id=?
+		ON DUPLICATE KEY UPDATE repo_id = VALUES(repo_id), replaced_at = VALUES(replaced_at);`, now, id)
+	if err != nil {
+		return fmt.Errorf("error encountered recording alias for row ID %d: %v", id, err)
+	}
+	// A repo renamed back to an old name shouldn't keep that name as an alias
+	_, err = tx.ExecContext(ctx, `DELETE FROM repo_aliases WHERE owner=? AND repo=?;`, newOwner, newRepo)
+	if err != nil {
+		return fmt.Errorf("error encountered removing alias %s/%s: %v", newOwner, newRepo, err)
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE repos SET owner=?, repo=? WHERE id=?;`, newOwner, newRepo, id)
+	if err != nil {
+		return fmt.Errorf("error encountered renaming row ID %d to %s/%s: %v", id, newOwner, newRepo, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction to rename row ID %d: %v", id, err)
+	}
+	return nil
+}
+
+// AddAlias records another name that refers to a repo row, ie. an outdated name still used in config
+func AddAlias(ctx context.Context, id int, owner, repo string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO repo_aliases (repo_id,owner,repo,replaced_at) VALUES(?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE repo_id = VALUES(repo_id);`, id, owner, repo, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("error encountered adding alias %s/%s for row ID %d: %v", owner, repo, id, err)
+	}
+	return nil
+}
+
+// IncrementNotFoundByID counts another 404 in a row for the matching row, setting gone_at once goneAfter 404s in a row have been seen.
+// Returns the updated row
+func IncrementNotFoundByID(ctx context.Context, id int, goneAfter int) (Repo, error) {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET consecutive_404s = consecutive_404s + 1,
+		gone_at = IF(gone_at IS NULL AND consecutive_404s >= ?, ?, gone_at) WHERE id=?;`, goneAfter, time.Now().UTC().Format("2006-01-02 15:04:05"), id)
+	if err != nil {
+		return Repo{}, fmt.Errorf("error encountered counting 404 on row ID %d: %v", id, err)
+	}
+
+	rows, err := scanRepoRows(ctx, fmt.Sprintf("for ID %d", id), "SELECT "+repoColumns+" FROM repos WHERE id = ?", id)
+	if err != nil {
+		return Repo{}, err
+	}
+	if len(rows) != 1 {
+		return Repo{}, fmt.Errorf("expected one row for ID %d after counting 404, found %d", id, len(rows))
+	}
+	return rows[0], nil
+}
+
+// ResetNotFoundByID clears the 404 count and gone_at of the matching row, once it's been found again
+func ResetNotFoundByID(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET consecutive_404s = 0, gone_at = NULL WHERE id=?;`, id)
+	if err != nil {
+		return fmt.Errorf("error encountered resetting 404 count on row ID %d: %v", id, err)
+	}
+	return nil
+}
+
+// UpdateForkParentByID records the repo a row was forked from on GitHub, by owner/repo and by row ID when the parent is in the repos table too.
+// An empty parent clears it
+func UpdateForkParentByID(ctx context.Context, id int, parent string, parentID int) error {
+	_, err := db.ExecContext(ctx, `UPDATE repos SET fork_parent = ?, fork_parent_id = ? WHERE id = ?;`,
+		sql.NullString{String: parent, Valid: parent != ""}, sql.NullInt64{Int64: int64(parentID), Valid: parentID != 0}, id)
+	if err != nil {
+		return fmt.Errorf("error encountered updating fork parent on row ID %d: %v", id, err)
+	}
+	return nil
+}
+
+// Lineage holds what's known about where a repo's history came from, for deciding which copy of a commit found in several repos is the original
+type Lineage struct {
+	RepoID       int
+	ForkParent   string
+	ForkParentID int
+	CreatedAt    time.Time // When the repo was created on GitHub, from the repo_metadata table, zero until its metadata is refreshed
+	FirstCommit  time.Time
+}
+
+// GetAllLineage returns the lineage of every repo in the repos table
+func GetAllLineage(ctx context.Context) ([]Lineage, error) {
+	var lineage []Lineage
+	rows, err := db.QueryContext(ctx, `SELECT repos.id, repos.fork_parent, repos.fork_parent_id, repo_metadata.created_at, repos.first_commit
+		FROM repos LEFT JOIN repo_metadata ON repo_metadata.repo_id = repos.id ORDER BY repos.id ASC`)
+	if err != nil {
+		return lineage, fmt.Errorf("error querying repos table for lineage: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			l            Lineage
+			forkParent   sql.NullString
+			forkParentID sql.NullInt64
+			createdAt    sql.NullTime
+			firstCommit  sql.NullTime
+		)
+		err := rows.Scan(&l.RepoID, &forkParent, &forkParentID, &createdAt, &firstCommit)
+		if err != nil {
+			return lineage, fmt.Errorf("error scanning lineage row for repos table: %v", err)
+		}
+		l.ForkParent, l.ForkParentID, l.CreatedAt, l.FirstCommit = forkParent.String, int(forkParentID.Int64), createdAt.Time, firstCommit.Time
+		lineage = append(lineage, l)
+	}
+	if err := rows.Err(); err != nil {
+		return lineage, fmt.Errorf("error encountered iterating through lineage rows: %v", err)
+	}
+
+	return lineage, nil
+}
+
+// Alias represents one row of the repo_aliases table, along with the current name of the repo it points to
+type Alias struct {
+	RepoID       int
+	Owner        string
+	Repo         string
+	CurrentOwner string
+	CurrentRepo  string
+	ReplacedAt   time.Time
+}
+
+// GetAllAliases returns every row in the repo_aliases table
+func GetAllAliases(ctx context.Context) ([]Alias, error) {
+	var aliases []Alias
+	rows, err := db.QueryContext(ctx, "SELECT repo_aliases.repo_id, repo_aliases.owner, repo_aliases.repo, repos.owner, repos.repo, repo_aliases.replaced_at FROM repo_aliases JOIN repos ON repos.id = repo_aliases.repo_id ORDER BY repo_aliases.owner, repo_aliases.repo")
+	if err != nil {
+		return aliases, fmt.Errorf("error querying repo_aliases table for rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			a          Alias
+			replacedAt sql.NullTime
+		)
+		err := rows.Scan(&a.RepoID, &a.Owner, &a.Repo, &a.CurrentOwner, &a.CurrentRepo, &replacedAt)
+		if err != nil {
+			return aliases, fmt.Errorf("error scanning row for repo_aliases table: %v", err)
+		}
+		if replacedAt.Valid {
+			a.ReplacedAt = replacedAt.Time
+		}
+		aliases = append(aliases, a)
+	}
+	if err := rows.Err(); err != nil {
+		return aliases, fmt.Errorf("error encountered iterating through repo_aliases rows: %v", err)
+	}
+
+	return aliases, nil
+}
+
+// StreamRows calls fn with every repo matching the filter's owner and name, which had a commit in the filter's date range when it has one, in ID order.
+// Rows are read from the db as fn is called, so they're never all held in memory. Iteration stops at the first error returned by fn
+func StreamRows(ctx context.Context, f commits.Filter, fn func(Repo) error) error {
+	conditions := []string{"TRUE"}
+	var args []any
+	if f.Owner != "" {
+		conditions = append(conditions, "repos.owner = ?")
+		args = append(args, f.Owner)
+	}
+	if f.Repo != "" {
+		conditions = append(conditions, "repos.repo = ?")
+		args = append(args, f.Repo)
+	}
+	if dates := (commits.Filter{Since: f.Since, Until: f.Until}); !dates.IsZero() {
+		dateConditions, dateArgs := dates.Conditions()
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM commits c JOIN repos r ON r.id = c.repo_id WHERE c.repo_id = repos.id AND `+dateConditions+`)`)
+		args = append(args, dateArgs...)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+repoColumns+" FROM repos WHERE "+strings.Join(conditions, " AND ")+" ORDER BY repos.id ASC", args...)
+	if err != nil {
+		return fmt.Errorf("error querying repos table for export rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var r repoWithNulls
+		err := rows.Scan(&r.ID, &r.Owner, &r.Repo, &r.ImportedThrough, &r.FirstCommit, &r.LastCommit, &r.Notes, &r.GitHubID, &r.NodeID, &r.Consecutive404s, &r.GoneAt, &r.ForkParent, &r.ForkParentID)
+		if err != nil {
+			return fmt.Errorf("error scanning export row for repos table: %v", err)
+		}
+		err = fn(convertSQLRepoToRepo(r))
+		if err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error encountered iterating through repo export rows: %v", err)
+	}
+
+	return nil
+}
@@ -1,12 +1,19 @@
 package sortedcommits
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/chia-network/ecosystem-activity/internal/db"
+	leaderleases "github.com/chia-network/ecosystem-activity/internal/db/leader_leases"
+	log "github.com/sirupsen/logrus"
 )
 
+// insertBatchSize is the number of rows written by each multi-row INSERT in ReplaceAllRecords
+const insertBatchSize = 1000
+
 // SortedCommit represents all columns in one sorted_commit entry in the sorted_commits table
 type SortedCommit struct {
 	ID       int
@@ -14,38 +21,61 @@ type SortedCommit struct {
 	Date     time.Time
 }
 
-// ResetAllRecords this function would be called to reset the sorted_commits table for reorganization
-// First deletes all rows in the table and then resets the auto_increment counter for the id column
-func ResetAllRecords() error {
-	_, err := db.Exec(`DELETE FROM sorted_commits;`)
+// ReplaceAllRecords replaces every row in the sorted_commits table with a new set of rows in one transaction, so readers never see a partially
+// refreshed table. Rows are numbered from 1 in the order given, like they would be by the auto incrementer of an empty table.
+// The transaction is fenced on the leader lease, so a replica that lost the lease part way through can't overwrite the new leader's rows
+func ReplaceAllRecords(ctx context.Context, rows []SortedCommit) error {
+	tx, err := db.BeginTx(ctx)
 	if err != nil {
-		return fmt.Errorf("error encountered deleting records for sorted_commits table: %v", err)
+		return fmt.Errorf("error starting transaction for sorted_commits table: %v", err)
 	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for sorted_commits table: %v", err)
+		}
+	}(tx)
 
-	_, err = db.Exec(`ALTER TABLE sorted_commits AUTO_INCREMENT = 1;`)
+	_, err = tx.ExecContext(ctx, `DELETE FROM sorted_commits;`)
 	if err != nil {
-		return fmt.Errorf("error encountered reseting auto_increment for sorted_commits table: %v", err)
+		return fmt.Errorf("error encountered deleting records for sorted_commits table: %v", err)
 	}
-	return nil
-}
 
-// SetNewRecord inserts one new record into the table
-func SetNewRecord(c SortedCommit) error {
-	if c.CommitID == 0 || c.Date.IsZero() {
-		// Safe to just return here, we sanity checked the input and it was bad but we don't need to gate anything with this
-		return nil
+	var valid []SortedCommit
+	for _, c := range rows {
+		// Safe to just skip these, we sanity checked the input and it was bad but we don't need to gate anything with this
+		if c.CommitID != 0 && !c.Date.IsZero() {
+			valid = append(valid, c)
+		}
+	}
+	for start := 0; start < len(valid); start += insertBatchSize {
+		batch := valid[start:min(start+insertBatchSize, len(valid))]
+		args := make([]any, 0, len(batch)*3)
+		for i, c := range batch {
+			args = append(args, start+i+1, c.CommitID, c.Date.Format("2006-01-02 15:04:05"))
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO sorted_commits (id,commit_id,date) VALUES `+db.ValuesPlaceholders(len(batch), 3)+`;`, args...)
+		if err != nil {
+			return fmt.Errorf("error encountered inserting records to sorted_commits table: %v", err)
+		}
+	}
+
+	err = leaderleases.Fence(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("not replacing sorted_commits table: %v", err)
 	}
-	_, err := db.Exec(`INSERT INTO sorted_commits (commit_id,date) VALUES(?, ?);`, c.CommitID, c.Date.Format("2006-01-02 15:04:05"))
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("error encountered inputting commit to sorted_commits table: %v", err)
+		return fmt.Errorf("error committing transaction for sorted_commits table: %v", err)
 	}
 	return nil
 }
 
 // DeleteRow deletes one row in the sorted_commits table by special commit_id (foreign key to commits table)
 // this will only be used to delete bot user activity once detected
-func DeleteRow(id int) error {
-	_, err := db.Exec(`DELETE FROM sorted_commits WHERE commit_id = ?;`, id)
+func DeleteRow(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM sorted_commits WHERE commit_id = ?;`, id)
 	if err != nil {
 		return fmt.Errorf("error encountered deleting row for sorted_commits table: %v", err)
 	}
@@ -0,0 +1,279 @@
+package tags
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// The sources of a repo's tag. Tags from config are replaced every time config is synced, and manual tags are only removed by hand
+const (
+	SourceConfig = "config"
+	SourceManual = "manual"
+)
+
+// Tag is one row of the tags table, along with the number of repos it's on
+type Tag struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Repos int    `json:"repos"`
+}
+
+// RepoTag represents all columns in one row of the repo_tags table, along with the tag's name
+type RepoTag struct {
+	RepoID    int
+	Tag       string
+	Source    string
+	CreatedAt time.Time
+}
+
+// Normalize returns a tag name as it's stored, trimmed and lowercased so "Wallets" and "wallets" are the same tag
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// normalizeAll normalizes a list of tag names, dropping empty and duplicate names
+func normalizeAll(names []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, n := range names {
+		n = Normalize(n)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	return normalized
+}
+
+// ensureTags adds any tags that don't exist yet and returns the ID of each tag by name, as part of a transaction
+func ensureTags(ctx context.Context, tx *sql.Tx, names []string) (map[string]int, error) {
+	ids := make(map[string]int)
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	args := make([]any, 0, len(names))
+	for _, n := range names {
+		args = append(args, n)
+	}
+	_, err := tx.ExecContext(ctx, `INSERT IGNORE INTO tags (name) VALUES `+db.ValuesPlaceholders(len(names), 1)+`;`, args...)
+	if err != nil {
+		return ids, fmt.Errorf("error adding %d tags to tags table: %v", len(names), err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id,name FROM tags WHERE name IN `+db.InPlaceholders(len(names)), args...)
+	if err != nil {
+		return ids, fmt.Errorf("error querying tags table for IDs of %d tags: %v", len(names), err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return ids, fmt.Errorf("error scanning row for tags table: %v", err)
+		}
+		ids[name] = id
+	}
+	if err := rows.Err(); err != nil {
+		return ids, fmt.Errorf("error encountered iterating through tag rows: %v", err)
+	}
+
+	return ids, nil
+}
+
+// withTx runs fn in a transaction, committing it when fn succeeds
+func withTx(ctx context.Context, desc string, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for %s: %v", desc, err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for %s: %v", desc, err)
+		}
+	}(tx)
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction for %s: %v", desc, err)
+	}
+	return nil
+}
+
+// AddRepoTags tags a repo, adding any tags that don't exist yet. A manual tag that's also in config stays manual,
+// so it isn't removed when it's taken out of config
+func AddRepoTags(ctx context.Context, repoID int, names []string, source string) error {
+	names = normalizeAll(names)
+	if len(names) == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	return withTx(ctx, "repo_tags table", func(tx *sql.Tx) error {
+		ids, err := ensureTags(ctx, tx, names)
+		if err != nil {
+			return err
+		}
+		args := make([]any, 0, len(names)*4)
+		for _, n := range names {
+			args = append(args, repoID, ids[n], source, now)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO repo_tags (repo_id,tag_id,source,created_at) VALUES `+db.ValuesPlaceholders(len(names), 4)+`
+			ON DUPLICATE KEY UPDATE source = IF(VALUES(source) = ?, VALUES(source), source);`, append(args, SourceManual)...)
+		if err != nil {
+			return fmt.Errorf("error tagging repo ID %d in repo_tags table: %v", repoID, err)
+		}
+		return nil
+	})
+}
+
+// RemoveRepoTags removes tags from a repo whatever their source, returning the number removed. A tag removed while it's in config
+// comes back the next time config is synced
+func RemoveRepoTags(ctx context.Context, repoID int, names []string) (int, error) {
+	names = normalizeAll(names)
+	if len(names) == 0 {
+		return 0, nil
+	}
+	args := []any{repoID}
+	for _, n := range names {
+		args = append(args, n)
+	}
+	result, err := db.ExecContext(ctx, `DELETE repo_tags FROM repo_tags JOIN tags ON tags.id = repo_tags.tag_id WHERE repo_tags.repo_id = ? AND tags.name IN `+db.InPlaceholders(len(names))+`;`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error removing tags from repo ID %d in repo_tags table: %v", repoID, err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected removing tags from repo ID %d: %v", repoID, err)
+	}
+	return int(removed), nil
+}
+
+// SyncConfigTags replaces every tag from config with the tags of each repo ID in byRepo, in one transaction. Manual tags are left alone
+func SyncConfigTags(ctx context.Context, byRepo map[int][]string) error {
+	var names []string
+	for repoID, repoTags := range byRepo {
+		byRepo[repoID] = normalizeAll(repoTags)
+		names = append(names, byRepo[repoID]...)
+	}
+	names = normalizeAll(names)
+	sort.Strings(names)
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	return withTx(ctx, "repo_tags table", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM repo_tags WHERE source = ?;`, SourceConfig)
+		if err != nil {
+			return fmt.Errorf("error removing config tags from repo_tags table: %v", err)
+		}
+
+		ids, err := ensureTags(ctx, tx, names)
+		if err != nil {
+			return err
+		}
+		var args []any
+		for repoID, repoTags := range byRepo {
+			for _, n := range repoTags {
+				args = append(args, repoID, ids[n], SourceConfig, now)
+			}
+		}
+		if len(args) == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO repo_tags (repo_id,tag_id,source,created_at) VALUES `+db.ValuesPlaceholders(len(args)/4, 4)+`;`, args...)
+		if err != nil {
+			return fmt.Errorf("error adding config tags to repo_tags table: %v", err)
+		}
+		return nil
+	})
+}
+
+// GetByRepoID returns a repo's tags in name order
+func GetByRepoID(ctx context.Context, repoID int) ([]RepoTag, error) {
+	var repoTags []RepoTag
+	rows, err := db.QueryContext(ctx, `SELECT repo_tags.repo_id,tags.name,repo_tags.source,repo_tags.created_at FROM repo_tags
+		JOIN tags ON tags.id = repo_tags.tag_id WHERE repo_tags.repo_id = ? ORDER BY tags.name`, repoID)
+	if err != nil {
+		return repoTags, fmt.Errorf("error querying repo_tags table for repo ID %d: %v", repoID, err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			rt        RepoTag
+			createdAt sql.NullTime
+		)
+		err := rows.Scan(&rt.RepoID, &rt.Tag, &rt.Source, &createdAt)
+		if err != nil {
+			return repoTags, fmt.Errorf("error scanning row for repo_tags table: %v", err)
+		}
+		rt.CreatedAt = createdAt.Time
+		repoTags = append(repoTags, rt)
+	}
+	if err := rows.Err(); err != nil {
+		return repoTags, fmt.Errorf("error encountered iterating through repo_tags rows: %v", err)
+	}
+
+	return repoTags, nil
+}
+
+// GetAll returns every tag that's on at least one repo, with the number of repos it's on, in name order
+func GetAll(ctx context.Context) ([]Tag, error) {
+	var all []Tag
+	rows, err := db.QueryContext(ctx, `SELECT tags.id,tags.name,COUNT(*) FROM tags JOIN repo_tags ON repo_tags.tag_id = tags.id GROUP BY tags.id,tags.name ORDER BY tags.name`)
+	if err != nil {
+		return all, fmt.Errorf("error querying tags table for rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var t Tag
+		err := rows.Scan(&t.ID, &t.Name, &t.Repos)
+		if err != nil {
+			return all, fmt.Errorf("error scanning row for tags table: %v", err)
+		}
+		all = append(all, t)
+	}
+	if err := rows.Err(); err != nil {
+		return all, fmt.Errorf("error encountered iterating through tag rows: %v", err)
+	}
+
+	return all, nil
+}
+
+// RepoCondition returns an SQL condition, and its args, matching rows whose repo ID column has a tag
+func RepoCondition(repoIDColumn string, tag string) (string, []any) {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM repo_tags JOIN tags ON tags.id = repo_tags.tag_id WHERE repo_tags.repo_id = %s AND tags.name = ?)`, repoIDColumn), []any{Normalize(tag)}
+}
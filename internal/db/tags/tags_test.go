@@ -0,0 +1,25 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeAll(t *testing.T) {
+	result := normalizeAll([]string{" Wallets", "wallets", "", "Chialisp Libraries", "  "})
+	expected := []string{"wallets", "chialisp libraries"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result fail. Received %v, Expected %v", result, expected)
+	}
+}
+
+func TestRepoCondition(t *testing.T) {
+	condition, args := RepoCondition("h.repo_id", " Explorers")
+	expected := "EXISTS (SELECT 1 FROM repo_tags JOIN tags ON tags.id = repo_tags.tag_id WHERE repo_tags.repo_id = h.repo_id AND tags.name = ?)"
+	if condition != expected {
+		t.Errorf("Result fail. Received %v, Expected %v", condition, expected)
+	}
+	if len(args) != 1 || args[0] != "explorers" {
+		t.Errorf("Result fail. Received args %v, Expected [explorers]", args)
+	}
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
+)
+
+// tracingConnector wraps the connections of a driver.Connector so every statement sent through them gets a span and a trace level log line,
+// including statements made in transactions and statements made without a context
+type tracingConnector struct {
+	base driver.Connector
+}
+
+func (c tracingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracingConn{base: conn}, nil
+}
+
+func (c tracingConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// tracingConn traces the statements sent through one connection. database/sql only uses a connection from one goroutine at a time
+type tracingConn struct {
+	base driver.Conn
+
+	// The context the open transaction was started with, if any. Statements made in the transaction without a span of their own,
+	// such as with tx.Exec, are traced and logged under it
+	txCtx context.Context
+}
+
+// statementContext returns the context to trace a statement made with ctx under
+func (c *tracingConn) statementContext(ctx context.Context) context.Context {
+	if c.txCtx != nil && !trace.SpanContextFromContext(ctx).IsValid() {
+		return c.txCtx
+	}
+	return ctx
+}
+
+// recordStatement records a statement that started at start with a span, and logs it at trace level with how long it took,
+// so slow statements can be tied to the repo and run they were made for. driver.ErrSkip means the statement wasn't run, and is run again another way
+func (c *tracingConn) recordStatement(ctx context.Context, query string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	ctx = c.statementContext(ctx)
+	statement := strings.Join(strings.Fields(query), " ")
+	_, span := tracing.Tracer().Start(ctx, "db.statement", trace.WithTimestamp(start), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system.name", "mysql"),
+		attribute.String("db.query.text", statement),
+	))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	logger := logging.FromContext(ctx)
+	if logger.Logger.IsLevelEnabled(log.TraceLevel) {
+		logger.WithFields(logging.Since(start)).Tracef("ran query: %s", statement)
+	}
+}
+
+func (c *tracingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.base.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.base.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracingStmt{base: stmt, conn: c, query: query}, nil
+}
+
+func (c *tracingConn) Close() error {
+	return c.base.Close()
+}
+
+func (c *tracingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *tracingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if b, ok := c.base.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.base.Begin() //nolint:staticcheck // Only for drivers without BeginTx
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.txCtx = ctx
+	return &tracingTx{base: tx, conn: c}, nil
+}
+
+func (c *tracingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.base.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.recordStatement(ctx, query, start, err)
+	return result, err
+}
+
+func (c *tracingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.base.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.recordStatement(ctx, query, start, err)
+	return rows, err
+}
+
+func (c *tracingConn) Ping(ctx context.Context) error {
+	if p, ok := c.base.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.base.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracingConn) IsValid() bool {
+	if v, ok := c.base.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *tracingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.base.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tracingTx ends the transaction its connection's statements are traced under
+type tracingTx struct {
+	base driver.Tx
+	conn *tracingConn
+}
+
+func (t *tracingTx) Commit() error {
+	t.conn.txCtx = nil
+	return t.base.Commit()
+}
+
+func (t *tracingTx) Rollback() error {
+	t.conn.txCtx = nil
+	return t.base.Rollback()
+}
+
+// tracingStmt traces each execution of a prepared statement. database/sql prepares a statement for each call with arguments
+// when the driver doesn't interpolate them itself, which the mysql driver doesn't by default
+type tracingStmt struct {
+	base  driver.Stmt
+	conn  *tracingConn
+	query string
+}
+
+func (s *tracingStmt) Close() error {
+	return s.base.Close()
+}
+
+func (s *tracingStmt) NumInput() int {
+	return s.base.NumInput()
+}
+
+func (s *tracingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.base.Exec(args) //nolint:staticcheck // Only used by callers of the deprecated driver.Stmt interface
+}
+
+func (s *tracingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.base.Query(args) //nolint:staticcheck // Only used by callers of the deprecated driver.Stmt interface
+}
+
+func (s *tracingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		result driver.Result
+		err    error
+	)
+	if e, ok := s.base.(driver.StmtExecContext); ok {
+		result, err = e.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			result, err = s.base.Exec(values) //nolint:staticcheck // Only for drivers without StmtExecContext
+		}
+	}
+	s.conn.recordStatement(ctx, s.query, start, err)
+	return result, err
+}
+
+func (s *tracingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.base.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			rows, err = s.base.Query(values) //nolint:staticcheck // Only for drivers without StmtQueryContext
+		}
+	}
+	s.conn.recordStatement(ctx, s.query, start, err)
+	return rows, err
+}
+
+func (s *tracingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.base.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// namedValuesToValues converts arguments for drivers that only take positional arguments
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("named arguments aren't supported by this driver")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
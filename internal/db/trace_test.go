@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
+)
+
+// fakeConnector hands out connections that behave like the mysql driver's without interpolateParams,
+// skipping statements with arguments so database/sql prepares them
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	if strings.HasPrefix(query, "LOCK") {
+		return nil, errors.New("Error 1205: Lock wait timeout exceeded")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, errors.New("not supported") }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func TestTracingConnector(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	client := sql.OpenDB(tracingConnector{base: fakeConnector{}})
+	defer client.Close()
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "collector.repo")
+	tx, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Statements made without a context are traced under the transaction's
+	if _, err := tx.Exec(`UPDATE repos SET notes = ?
+		WHERE id = ?`, "moved", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("LOCK TABLES commits WRITE"); err == nil {
+		t.Fatal("Result fail. Received no error, Expected an error")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+	// Once the transaction ends, statements on the same connection are no longer traced under it
+	if _, err := client.Exec("DELETE FROM tags"); err != nil {
+		t.Fatal(err)
+	}
+
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "db.statement" {
+			spans = append(spans, span)
+		}
+	}
+	if len(spans) != 3 {
+		t.Fatalf("Result fail. Received %d spans, Expected %d", len(spans), 3)
+	}
+	statements := []string{"UPDATE repos SET notes = ? WHERE id = ?", "LOCK TABLES commits WRITE", "DELETE FROM tags"}
+	for i, span := range spans {
+		var statement string
+		for _, kv := range span.Attributes() {
+			if kv.Key == "db.query.text" {
+				statement = kv.Value.AsString()
+			}
+		}
+		if statement != statements[i] {
+			t.Errorf("Result fail. Received %s, Expected %s", statement, statements[i])
+		}
+	}
+	for _, span := range spans[:2] {
+		if received, expect := span.Parent().SpanID(), parent.SpanContext().SpanID(); received != expect {
+			t.Errorf("Result fail. Received %v, Expected %v", received, expect)
+		}
+	}
+	if spans[2].Parent().IsValid() {
+		t.Errorf("Result fail. Received parent %v, Expected none", spans[2].Parent().SpanID())
+	}
+	if status := spans[0].Status().Code; status != codes.Unset {
+		t.Errorf("Result fail. Received %v, Expected %v", status, codes.Unset)
+	}
+	if status := spans[1].Status().Code; status != codes.Error {
+		t.Errorf("Result fail. Received %v, Expected %v", status, codes.Error)
+	}
+}
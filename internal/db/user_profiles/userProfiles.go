@@ -0,0 +1,198 @@
+package userprofiles
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// UserProfile represents one row of the user_profiles table, which holds the GitHub profile of a user in the users table,
+// along with the user's public organization memberships from the user_orgs table
+type UserProfile struct {
+	UserID          int
+	Username        string // From the users table
+	GitHubID        int64
+	Name            string
+	Company         string
+	Location        string
+	AccountType     string // User, Organization, or Bot
+	GitHubCreatedAt time.Time
+	Orgs            []string
+	Affiliation     string
+	RefreshedAt     time.Time // Zero for users whose profile hasn't been looked up yet
+}
+
+// userProfileWithNulls is a helper struct for mysql rows that may contain null fields
+// a null field using the mysql database driver won't scan into the appropriate field
+type userProfileWithNulls struct {
+	UserID          sql.NullInt64
+	Username        sql.NullString
+	GitHubID        sql.NullInt64
+	Name            sql.NullString
+	Company         sql.NullString
+	Location        sql.NullString
+	AccountType     sql.NullString
+	GitHubCreatedAt sql.NullTime
+	Affiliation     sql.NullString
+	RefreshedAt     sql.NullTime
+}
+
+// convertSQLUserProfileToUserProfile handles the internal conversion between an sql row response and a user-friendly UserProfile struct
+// because Go's sql package errors when scanning nil columns in a row
+func convertSQLUserProfileToUserProfile(p userProfileWithNulls) UserProfile {
+	var profile UserProfile
+	if p.UserID.Valid {
+		profile.UserID = int(p.UserID.Int64)
+	}
+	if p.Username.Valid {
+		profile.Username = p.Username.String
+	}
+	if p.GitHubID.Valid {
+		profile.GitHubID = p.GitHubID.Int64
+	}
+	if p.Name.Valid {
+		profile.Name = p.Name.String
+	}
+	if p.Company.Valid {
+		profile.Company = p.Company.String
+	}
+	if p.Location.Valid {
+		profile.Location = p.Location.String
+	}
+	if p.AccountType.Valid {
+		profile.AccountType = p.AccountType.String
+	}
+	if p.GitHubCreatedAt.Valid {
+		profile.GitHubCreatedAt = p.GitHubCreatedAt.Time
+	}
+	if p.Affiliation.Valid {
+		profile.Affiliation = p.Affiliation.String
+	}
+	if p.RefreshedAt.Valid {
+		profile.RefreshedAt = p.RefreshedAt.Time
+	}
+	return profile
+}
+
+// GetAllRows returns a profile for every user in the users table, including users whose profile hasn't been looked up yet
+func GetAllRows(ctx context.Context) ([]UserProfile, error) {
+	var profiles []UserProfile
+	rows, err := db.QueryContext(ctx, `SELECT users.id,users.username,p.github_id,p.name,p.company,p.location,p.account_type,p.github_created_at,p.affiliation,p.refreshed_at
+		FROM users LEFT JOIN user_profiles p ON p.user_id = users.id ORDER BY users.id ASC`)
+	if err != nil {
+		return profiles, fmt.Errorf("error querying user_profiles table for all rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	index := make(map[int]int)
+	for rows.Next() {
+		var p userProfileWithNulls
+		err := rows.Scan(&p.UserID, &p.Username, &p.GitHubID, &p.Name, &p.Company, &p.Location, &p.AccountType, &p.GitHubCreatedAt, &p.Affiliation, &p.RefreshedAt)
+		if err != nil {
+			return profiles, fmt.Errorf("error scanning row for user_profiles table: %v", err)
+		}
+
+		nonNullProfile := convertSQLUserProfileToUserProfile(p)
+		index[nonNullProfile.UserID] = len(profiles)
+		profiles = append(profiles, nonNullProfile)
+	}
+	if err := rows.Err(); err != nil {
+		return profiles, fmt.Errorf("error encountered iterating through user_profiles rows: %v", err)
+	}
+
+	orgs, err := getAllOrgs(ctx)
+	if err != nil {
+		return profiles, err
+	}
+	for userID, o := range orgs {
+		if i, ok := index[userID]; ok {
+			profiles[i].Orgs = o
+		}
+	}
+
+	return profiles, nil
+}
+
+// getAllOrgs returns the organizations of every user in the user_orgs table, keyed by user ID
+func getAllOrgs(ctx context.Context) (map[int][]string, error) {
+	orgs := make(map[int][]string)
+	rows, err := db.QueryContext(ctx, "SELECT user_id,org FROM user_orgs ORDER BY user_id, org")
+	if err != nil {
+		return orgs, fmt.Errorf("error querying user_orgs table for all rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			userID int
+			org    string
+		)
+		err := rows.Scan(&userID, &org)
+		if err != nil {
+			return orgs, fmt.Errorf("error scanning row for user_orgs table: %v", err)
+		}
+		orgs[userID] = append(orgs[userID], org)
+	}
+	if err := rows.Err(); err != nil {
+		return orgs, fmt.Errorf("error encountered iterating through user_orgs rows: %v", err)
+	}
+
+	return orgs, nil
+}
+
+// nullTime stores zero times as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// Upsert inserts or replaces the profile row for a user and replaces their organizations in the user_orgs table, as part of a transaction
+func Upsert(ctx context.Context, tx *sql.Tx, p UserProfile) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO user_profiles (user_id,github_id,name,company,location,account_type,github_created_at,affiliation,refreshed_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		github_id = VALUES(github_id), name = VALUES(name), company = VALUES(company), location = VALUES(location), account_type = VALUES(account_type),
+		github_created_at = VALUES(github_created_at), affiliation = VALUES(affiliation), refreshed_at = VALUES(refreshed_at);`,
+		p.UserID, sql.NullInt64{Int64: p.GitHubID, Valid: p.GitHubID != 0}, p.Name, p.Company, p.Location, p.AccountType,
+		nullTime(p.GitHubCreatedAt), p.Affiliation, nullTime(p.RefreshedAt))
+	if err != nil {
+		return fmt.Errorf("error encountered upserting user_profiles row for user ID %d: %v", p.UserID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_orgs WHERE user_id=?;`, p.UserID)
+	if err != nil {
+		return fmt.Errorf("error encountered deleting user_orgs rows for user ID %d: %v", p.UserID, err)
+	}
+	if len(p.Orgs) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(p.Orgs)*2)
+	seen := make(map[string]bool)
+	for _, o := range p.Orgs {
+		if seen[strings.ToLower(o)] {
+			continue
+		}
+		seen[strings.ToLower(o)] = true
+		args = append(args, p.UserID, o)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO user_orgs (user_id,org) VALUES `+db.ValuesPlaceholders(len(args)/2, 2)+`;`, args...)
+	if err != nil {
+		return fmt.Errorf("error encountered inserting user_orgs rows for user ID %d: %v", p.UserID, err)
+	}
+	return nil
+}
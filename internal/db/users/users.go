@@ -1,11 +1,14 @@
 package users
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
 	"github.com/chia-network/ecosystem-activity/internal/utils"
 	log "github.com/sirupsen/logrus"
 )
@@ -52,9 +55,9 @@ func convertSQLUserToUser(u userWithNulls) User {
 }
 
 // GetRowsByUsername gets a slice of rows matching a username
-func GetRowsByUsername(username string) ([]User, error) {
+func GetRowsByUsername(ctx context.Context, username string) ([]User, error) {
 	var users []User
-	rows, err := db.Query("SELECT id,username,first_commit,last_commit,notes FROM users WHERE username = ?", username)
+	rows, err := db.QueryContext(ctx, "SELECT id,username,first_commit,last_commit,notes FROM users WHERE username = ?", username)
 	if err != nil {
 		return users, fmt.Errorf("error querying users table for rows by username \"%s\": %v", username, err)
 	}
@@ -82,8 +85,8 @@ func GetRowsByUsername(username string) ([]User, error) {
 }
 
 // SetNewRecord inserts one new record into the table
-func SetNewRecord(u User) error {
-	_, err := db.Exec(`INSERT INTO users (username,first_commit,last_commit,notes) VALUES(?, ?, ?, ?);`, u.Username, u.FirstCommit, u.LastCommit, u.Notes)
+func SetNewRecord(ctx context.Context, u User) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO users (username,first_commit,last_commit,notes) VALUES(?, ?, ?, ?);`, u.Username, u.FirstCommit, u.LastCommit, u.Notes)
 	if err != nil {
 		return fmt.Errorf("error adding user to users table for \"%s\": %v", u.Username, err)
 	}
@@ -91,9 +94,71 @@ func SetNewRecord(u User) error {
 	return nil
 }
 
+// UpsertCommitRanges inserts any users that don't exist yet and widens first_commit and last_commit for those that do, as part of a transaction
+// Each User's FirstCommit and LastCommit should be the earliest and latest commit timestamps seen for that user in the current batch
+func UpsertCommitRanges(ctx context.Context, tx *sql.Tx, us []User) error {
+	if len(us) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(us)*3)
+	for _, u := range us {
+		args = append(args, u.Username, u.FirstCommit.Format("2006-01-02 15:04:05"), u.LastCommit.Format("2006-01-02 15:04:05"))
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO users (username,first_commit,last_commit) VALUES %s
+		ON DUPLICATE KEY UPDATE
+		first_commit = IF(first_commit IS NULL OR VALUES(first_commit) < first_commit, VALUES(first_commit), first_commit),
+		last_commit = IF(last_commit IS NULL OR VALUES(last_commit) > last_commit, VALUES(last_commit), last_commit);`, db.ValuesPlaceholders(len(us), 3)), args...)
+	if err != nil {
+		return fmt.Errorf("error upserting %d users in users table: %v", len(us), err)
+	}
+
+	return nil
+}
+
+// GetIDsByUsernames returns a map of lowercased username to row ID for each of the given usernames found in the users table, as part of a transaction
+func GetIDsByUsernames(ctx context.Context, tx *sql.Tx, usernames []string) (map[string]int, error) {
+	ids := make(map[string]int)
+	if len(usernames) == 0 {
+		return ids, nil
+	}
+
+	args := make([]any, 0, len(usernames))
+	for _, u := range usernames {
+		args = append(args, u)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id,username FROM users WHERE username IN %s", db.InPlaceholders(len(usernames))), args...)
+	if err != nil {
+		return ids, fmt.Errorf("error querying users table for IDs of %d usernames: %v", len(usernames), err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var (
+			id       int
+			username string
+		)
+		err := rows.Scan(&id, &username)
+		if err != nil {
+			return ids, fmt.Errorf("error scanning row for users table: %v", err)
+		}
+		ids[strings.ToLower(username)] = id
+	}
+	if err := rows.Err(); err != nil {
+		return ids, fmt.Errorf("error encountered iterating through user rows: %v", err)
+	}
+
+	return ids, nil
+}
+
 // UpdateLastCommitByUsername accepts a username and time object and updates the matching row's last_commit column to the timestamp
-func UpdateLastCommitByUsername(username string, ts time.Time) error {
-	_, err := db.Exec(`UPDATE users SET last_commit=? WHERE username=?;`, ts.Format("2006-01-02 15:04:05"), username)
+func UpdateLastCommitByUsername(ctx context.Context, username string, ts time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE users SET last_commit=? WHERE username=?;`, ts.Format("2006-01-02 15:04:05"), username)
 	if err != nil {
 		return fmt.Errorf("error encountered updating last_commit on row for %s: %v", username, err)
 	}
@@ -101,18 +166,48 @@ func UpdateLastCommitByUsername(username string, ts time.Time) error {
 }
 
 // UpdateFirstCommitByUsername accepts a username and time object and updates the matching row's first_commit column to the timestamp
-func UpdateFirstCommitByUsername(username string, ts time.Time) error {
-	_, err := db.Exec(`UPDATE users SET first_commit=? WHERE username=?;`, ts.Format("2006-01-02 15:04:05"), username)
+func UpdateFirstCommitByUsername(ctx context.Context, username string, ts time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE users SET first_commit=? WHERE username=?;`, ts.Format("2006-01-02 15:04:05"), username)
 	if err != nil {
 		return fmt.Errorf("error encountered updating first_commit on row for %s: %v", username, err)
 	}
 	return err
 }
 
+// GetAllRows gets every row in the users table
+func GetAllRows(ctx context.Context) ([]User, error) {
+	var users []User
+	rows, err := db.QueryContext(ctx, "SELECT id,username,first_commit,last_commit,notes FROM users ORDER BY id ASC")
+	if err != nil {
+		return users, fmt.Errorf("error querying users table for all rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var uWithNull userWithNulls
+		err := rows.Scan(&uWithNull.ID, &uWithNull.Username, &uWithNull.FirstCommit, &uWithNull.LastCommit, &uWithNull.Notes)
+		if err != nil {
+			return users, fmt.Errorf("error scanning row for all users: %v", err)
+		}
+		nonNullUser := convertSQLUserToUser(uWithNull)
+		users = append(users, nonNullUser)
+	}
+	if err := rows.Err(); err != nil {
+		return users, fmt.Errorf("error encountered iterating through rows for all users: %v", err)
+	}
+
+	return users, nil
+}
+
 // GetBotUserRows gets a slice of rows matching possible bot matchers
-func GetBotUserRows() ([]User, error) {
+func GetBotUserRows(ctx context.Context) ([]User, error) {
 	var users []User
-	rows, err := db.Query(fmt.Sprintf("SELECT id,username,first_commit,last_commit,notes FROM users WHERE %s", getBotLikes(utils.Bots)))
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT id,username,first_commit,last_commit,notes FROM users WHERE %s", getBotLikes(utils.Bots)))
 	if err != nil {
 		return users, fmt.Errorf("error querying users table for rows for possible bots: %v", err)
 	}
@@ -141,8 +236,8 @@ func GetBotUserRows() ([]User, error) {
 
 // DeleteRow deletes one row in the users table by ID
 // this will only be used to delete bot user activity once detected
-func DeleteRow(id int) error {
-	_, err := db.Exec(`DELETE FROM users WHERE id = ?;`, id)
+func DeleteRow(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM users WHERE id = ?;`, id)
 	if err != nil {
 		return fmt.Errorf("error encountered deleting row for users table: %v", err)
 	}
@@ -163,3 +258,42 @@ func getBotLikes(botMatchers []string) string {
 
 	return r
 }
+
+// StreamRows calls fn with every user who authored a commit matching the filter, or every user for an empty filter, in ID order.
+// Rows are read from the db as fn is called, so they're never all held in memory. Iteration stops at the first error returned by fn
+func StreamRows(ctx context.Context, f commits.Filter, fn func(User) error) error {
+	query := "SELECT id,username,first_commit,last_commit,notes FROM users"
+	var args []any
+	if !f.IsZero() {
+		var conditions string
+		conditions, args = f.Conditions()
+		query += ` WHERE EXISTS (SELECT 1 FROM commits c JOIN repos r ON r.id = c.repo_id WHERE c.user_id = users.id AND ` + conditions + `)`
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY id ASC", args...)
+	if err != nil {
+		return fmt.Errorf("error querying users table for export rows: %v", err)
+	}
+	defer func(r *sql.Rows) {
+		err := r.Close()
+		if err != nil {
+			log.Errorf("error closing sql rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var uWithNull userWithNulls
+		err := rows.Scan(&uWithNull.ID, &uWithNull.Username, &uWithNull.FirstCommit, &uWithNull.LastCommit, &uWithNull.Notes)
+		if err != nil {
+			return fmt.Errorf("error scanning export row for users table: %v", err)
+		}
+		err = fn(convertSQLUserToUser(uWithNull))
+		if err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error encountered iterating through user export rows: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	reportdeliveries "github.com/chia-network/ecosystem-activity/internal/db/report_deliveries"
+	"github.com/chia-network/ecosystem-activity/internal/report"
+)
+
+// The channels a report can be delivered on
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// webhookClient posts reports to the webhook
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// Options holds the delivery settings from the config file, along with the secrets that are set with flags
+type Options struct {
+	Config       config.ReportDelivery
+	SMTPPassword string
+	WebhookURL   string // A Slack-compatible incoming webhook URL
+	ChurnDays    int    // Passed to report.Build
+}
+
+// Enabled returns whether there's anywhere to deliver reports to
+func (o Options) Enabled() bool {
+	return len(o.Config.SMTP.To) > 0 || o.WebhookURL != ""
+}
+
+// Schedule creates a cron for delivering the last full month's report, and one for retrying failed deliveries, returning it so it can be stopped.
+// A job still running when it's next due is skipped, and deliveries are claimed before they're sent, so the two jobs never send one delivery twice.
+// Nothing is scheduled, and nil is returned, when delivery isn't configured
+func Schedule(ctx context.Context, schedule string, opts Options) *cron.Cron {
+	if !opts.Enabled() {
+		log.Info("no report email recipients or webhook are configured, skipping report delivery cron")
+		return nil
+	}
+	opts.Config = opts.Config.WithDefaults()
+
+	log.Infof("registering report delivery cron with schedule \"%s\"", schedule)
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	_, err := c.AddFunc(schedule, func() {
+		period, _ := report.ParsePeriod("", time.Now().UTC())
+		Run(ctx, period, opts)
+	})
+	if err != nil {
+		log.Errorf("error encountered registering report delivery cron: %v", err)
+	}
+	_, err = c.AddFunc(fmt.Sprintf("@every %dm", opts.Config.RetryMinutes), func() { RunPending(ctx, opts) })
+	if err != nil {
+		log.Errorf("error encountered registering report delivery retry cron: %v", err)
+	}
+	c.Start()
+	return c
+}
+
+// Run records a pending delivery of the month's report to every configured recipient, and sends all pending deliveries
+func Run(ctx context.Context, period time.Time, opts Options) {
+	log.Infof("Running report delivery for %s", period.Format("2006-01"))
+	opts.Config = opts.Config.WithDefaults()
+
+	now := time.Now().UTC()
+	for _, to := range opts.Config.SMTP.To {
+		err := reportdeliveries.Ensure(ctx, period, ChannelEmail, to, now)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	if opts.WebhookURL != "" {
+		err := reportdeliveries.Ensure(ctx, period, ChannelWebhook, webhookTarget(opts.WebhookURL), now)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	RunPending(ctx, opts)
+}
+
+// RunPending attempts every delivery in the report_deliveries table that hasn't been sent or given up on. Each delivery is claimed before it's sent,
+// and skipped when another run got to it first. A send in progress is abandoned when ctx is cancelled, and counts as a failed attempt
+func RunPending(ctx context.Context, opts Options) {
+	opts.Config = opts.Config.WithDefaults()
+
+	pending, err := reportdeliveries.GetPending(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	// Reports are built once per period, as several recipients usually share one
+	reports := make(map[string]report.Report)
+	for _, d := range pending {
+		if ctx.Err() != nil {
+			log.Info("Report delivery stopped part way through, the remaining deliveries are attempted on the next run")
+			return
+		}
+		r, ok := reports[d.Period.Format("2006-01")]
+		if !ok {
+			r, err = report.Build(ctx, d.Period, opts.ChurnDays)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			reports[d.Period.Format("2006-01")] = r
+		}
+
+		claimed, err := reportdeliveries.Claim(ctx, d, time.Now().UTC())
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if !claimed {
+			log.Debugf("skipping delivery of %s report by %s to %s, another run already attempted it", r.Period, d.Channel, d.Target)
+			continue
+		}
+
+		sendErr := send(ctx, d, r, opts)
+		if sendErr != nil {
+			log.Errorf("error delivering %s report by %s to %s: %v", r.Period, d.Channel, d.Target, sendErr)
+		}
+		d = recordAttempt(d, sendErr, opts.Config.MaxAttempts, time.Now().UTC())
+		if d.Status == reportdeliveries.StatusFailed {
+			log.Errorf("giving up on delivering %s report by %s to %s after %d attempts", r.Period, d.Channel, d.Target, d.Attempts)
+		}
+		// The outcome is recorded even when ctx was cancelled during the send, so the claimed attempt isn't left without an error
+		err = reportdeliveries.UpdateAttempt(context.WithoutCancel(ctx), d)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// send delivers a report on the delivery's channel
+func send(ctx context.Context, d reportdeliveries.Delivery, r report.Report, opts Options) error {
+	switch d.Channel {
+	case ChannelEmail:
+		return sendEmail(ctx, opts.Config.SMTP, opts.SMTPPassword, d.Target, r)
+	case ChannelWebhook:
+		if webhookTarget(opts.WebhookURL) != d.Target {
+			return fmt.Errorf("the webhook URL no longer matches %s", d.Target)
+		}
+		return sendWebhook(ctx, opts.WebhookURL, r)
+	default:
+		return fmt.Errorf("unsupported delivery channel \"%s\"", d.Channel)
+	}
+}
+
+// recordAttempt returns the delivery updated with the outcome of an attempt at it. Deliveries are given up on once they've failed maxAttempts times
+func recordAttempt(d reportdeliveries.Delivery, sendErr error, maxAttempts int, now time.Time) reportdeliveries.Delivery {
+	d.Attempts++
+	d.LastAttemptAt = now
+	if sendErr == nil {
+		d.Status = reportdeliveries.StatusSent
+		d.SentAt = now
+		d.LastError = ""
+		return d
+	}
+	d.LastError = sendErr.Error()
+	d.Status = reportdeliveries.StatusPending
+	if d.Attempts >= maxAttempts {
+		d.Status = reportdeliveries.StatusFailed
+	}
+	return d
+}
+
+// webhookTarget is how a webhook is recorded in the report_deliveries table. Incoming webhook URLs are secrets, so only the host is kept
+func webhookTarget(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Host == "" {
+		return "webhook"
+	}
+	return u.Host
+}
+
+// subject is the email subject of a report
+func subject(r report.Report) string {
+	return fmt.Sprintf("Ecosystem activity report: %s", r.Period)
+}
+
+// sendEmail emails a report to one recipient, with HTML and Markdown alternatives. The SMTP connection is closed when ctx is cancelled
+func sendEmail(ctx context.Context, cfg config.SMTP, password string, to string, r report.Report) error {
+	msg, err := emailMessage(cfg.From, to, r)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, password, cfg.Host)
+	}
+	err = sendMail(ctx, cfg.Host, cfg.Port, auth, cfg.From, to, msg)
+	if err != nil {
+		return fmt.Errorf("error sending report email: %v", err)
+	}
+	return nil
+}
+
+// sendMail does what smtp.SendMail does, over a connection that's dialed with ctx and closed when ctx is cancelled
+func sendMail(ctx context.Context, host string, port int, auth smtp.Auth, from, to string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("the SMTP server doesn't support AUTH")
+		}
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailMessage assembles a multipart/alternative email of a report
+func emailMessage(from, to string, r report.Report) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		format      string
+	}{
+		// Mail clients prefer the last alternative they can display
+		{"text/plain; charset=utf-8", report.FormatMarkdown},
+		{"text/html; charset=utf-8", report.FormatHTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, fmt.Errorf("error creating report email part: %v", err)
+		}
+		err = report.Render(w, r, part.format)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering report email part: %v", err)
+		}
+	}
+	err := mw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error closing report email body: %v", err)
+	}
+
+	var msg bytes.Buffer
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + subject(r),
+		"Date: " + r.GeneratedAt.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	msg.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// sendWebhook posts a report's Markdown to a Slack-compatible incoming webhook
+func sendWebhook(ctx context.Context, webhookURL string, r report.Report) error {
+	var text bytes.Buffer
+	err := report.Render(&text, r, report.FormatMarkdown)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]string{"text": text.String()})
+	if err != nil {
+		return fmt.Errorf("error marshalling webhook payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting report to webhook: %v", err)
+	}
+	defer func(b io.ReadCloser) {
+		err := b.Close()
+		if err != nil {
+			log.Errorf("error closing webhook response body: %v", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	reportdeliveries "github.com/chia-network/ecosystem-activity/internal/db/report_deliveries"
+	"github.com/chia-network/ecosystem-activity/internal/report"
+)
+
+func testReport() report.Report {
+	return report.Report{
+		Period:      "2026-09",
+		Previous:    "2026-08",
+		GeneratedAt: time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC),
+		Metrics:     []report.Metric{{Name: "Commits", Current: 5, Previous: 2, Change: 3, PercentChange: 150}},
+		TopRepos:    []report.RepoActivity{{Owner: "Chia-Network", Repo: "chia-blockchain", Commits: 5, Contributors: 2}},
+	}
+}
+
+// smtpStandIn accepts one SMTP session on a local port and sends the message data it receives on the returned channel
+func smtpStandIn(t *testing.T) (string, int, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestSendEmail(t *testing.T) {
+	host, port, received := smtpStandIn(t)
+	cfg := config.SMTP{Host: host, Port: port, From: "reports@example.com", To: []string{"team@example.com"}}
+
+	err := sendEmail(context.Background(), cfg, "", "team@example.com", testReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		for _, expect := range []string{
+			"To: team@example.com",
+			"Subject: Ecosystem activity report: 2026-09",
+			"Content-Type: multipart/alternative",
+			"Content-Type: text/html",
+			"Chia-Network/chia-blockchain",
+		} {
+			if !strings.Contains(msg, expect) {
+				t.Errorf("Result fail. Message is missing %s:\n%s", expect, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Result fail. SMTP stand-in didn't receive a message")
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Result fail. Received Content-Type %s, Expected application/json", r.Header.Get("Content-Type"))
+		}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			t.Error(err)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	err := sendWebhook(context.Background(), srv.URL+"/services/T000/B000/secret", testReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(payload["text"], "# Ecosystem activity report: 2026-09") {
+		t.Errorf("Result fail. Received text %q", payload["text"])
+	}
+}
+
+func TestSendWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := sendWebhook(context.Background(), srv.URL, testReport())
+	if err == nil || !strings.Contains(err.Error(), "invalid_token") {
+		t.Errorf("Result fail. Received %v, Expected an error with the response body", err)
+	}
+}
+
+func TestSendWebhookCancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancelFunc)
+	start := time.Now()
+	err := sendWebhook(ctx, srv.URL, testReport())
+	if err == nil || time.Since(start) > 5*time.Second {
+		t.Errorf("Result fail. Received %v after %s, Expected an error once ctx was cancelled", err, time.Since(start))
+	}
+}
+
+func TestRecordAttempt(t *testing.T) {
+	now := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+	d := reportdeliveries.Delivery{ID: 1, Status: reportdeliveries.StatusPending}
+
+	d = recordAttempt(d, errors.New("connection refused"), 2, now)
+	if d.Status != reportdeliveries.StatusPending || d.Attempts != 1 || d.LastError != "connection refused" || !d.SentAt.IsZero() {
+		t.Errorf("Result fail. Received %+v, Expected a pending delivery to retry", d)
+	}
+
+	failed := recordAttempt(d, errors.New("connection refused"), 2, now)
+	if failed.Status != reportdeliveries.StatusFailed || failed.Attempts != 2 {
+		t.Errorf("Result fail. Received %+v, Expected a failed delivery after 2 attempts", failed)
+	}
+
+	sent := recordAttempt(d, nil, 2, now)
+	if sent.Status != reportdeliveries.StatusSent || sent.Attempts != 2 || sent.LastError != "" || !sent.SentAt.Equal(now) {
+		t.Errorf("Result fail. Received %+v, Expected a sent delivery", sent)
+	}
+}
+
+func TestWebhookTarget(t *testing.T) {
+	if result := webhookTarget("https://hooks.slack.com/services/T000/B000/secret"); result != "hooks.slack.com" {
+		t.Errorf("Result fail. Received %s, Expected hooks.slack.com", result)
+	}
+}
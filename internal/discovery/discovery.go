@@ -0,0 +1,257 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	discoverycandidates "github.com/chia-network/ecosystem-activity/internal/db/discovery_candidates"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	gh "github.com/chia-network/ecosystem-activity/internal/github"
+)
+
+// Schedule creates a cron for running the discovery searches and recording new candidates in the discovery_candidates table, returning it so it can be stopped.
+// Nothing is scheduled, and nil is returned, when there are no discovery queries. A run in progress is cancelled with ctx
+func Schedule(ctx context.Context, schedule string, cfg config.Config) *cron.Cron {
+	if len(cfg.Discovery.RepositoryQueries) == 0 && len(cfg.Discovery.CodeQueries) == 0 {
+		log.Info("no discovery queries are configured, skipping discovery cron")
+		return nil
+	}
+
+	log.Infof("registering discovery cron with schedule \"%s\"", schedule)
+	c := cron.New()
+	_, err := c.AddFunc(schedule, func() {
+		_, err := Run(ctx, cfg)
+		if err != nil {
+			log.Error(err)
+		}
+	})
+	if err != nil {
+		log.Errorf("error encountered registering discovery cron: %v", err)
+	}
+	c.Start()
+	return c
+}
+
+// Run searches GitHub with the discovery queries in the config file, and records every repo that passes the discovery filters and isn't collected yet
+// in the discovery_candidates table. New candidates are approved right away when auto_add is set, and otherwise wait for review.
+// It returns the candidates from this run, most starred first, with the status they have after it
+func Run(ctx context.Context, cfg config.Config) ([]discoverycandidates.Candidate, error) {
+	d := cfg.Discovery.WithDefaults()
+	log.Infof("Running discovery with %d repository and %d code queries", len(d.RepositoryQueries), len(d.CodeQueries))
+
+	found, err := search(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	known, err := loadKnownRepos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := discoverycandidates.GetRows(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[int64]string)
+	for _, c := range existing {
+		statuses[c.GitHubID] = c.Status
+	}
+
+	now := time.Now().UTC()
+	status := discoverycandidates.StatusPending
+	var reviewedAt time.Time
+	if d.AutoAdd {
+		status = discoverycandidates.StatusApproved
+		reviewedAt = now
+	}
+
+	var candidates []discoverycandidates.Candidate
+	for _, f := range found {
+		if known.has(f.repo) {
+			continue
+		}
+		if reason := skipReason(f.repo, d, now); reason != "" {
+			log.Debugf("skipping discovered repo %s (%s)", f.repo.GetFullName(), reason)
+			continue
+		}
+
+		c := candidateFromGitHub(f.repo, f.queries, now)
+		c.Status, c.ReviewedAt = status, reviewedAt
+		err := discoverycandidates.Upsert(ctx, c)
+		if err != nil {
+			return candidates, err
+		}
+		// Upsert leaves the status of candidates found by an earlier run alone
+		if s, ok := statuses[c.GitHubID]; ok {
+			c.Status = s
+		}
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Stars > candidates[j].Stars
+	})
+	log.Infof("Discovery found %d candidate repos from %d search results", len(candidates), len(found))
+	return candidates, nil
+}
+
+// result is one repo found by the discovery searches, along with every query that found it
+type result struct {
+	repo    *github.Repository
+	queries []string
+}
+
+// search runs every discovery query, returning the distinct repos found in the order they were first found
+func search(ctx context.Context, d config.Discovery) ([]*result, error) {
+	var results []*result
+	byName := make(map[string]*result)
+	add := func(r *github.Repository, query string) {
+		name := strings.ToLower(r.GetFullName())
+		if existing, ok := byName[name]; ok {
+			existing.queries = append(existing.queries, query)
+			return
+		}
+		byName[name] = &result{repo: r, queries: []string{query}}
+		results = append(results, byName[name])
+	}
+
+	for _, q := range d.RepositoryQueries {
+		found, err := gh.SearchRepositories(ctx, q, d.MaxResults)
+		if err != nil {
+			return nil, fmt.Errorf("error running discovery repository query \"%s\": %v", q, err)
+		}
+		for _, r := range found {
+			add(r, q)
+		}
+	}
+
+	// Code search only returns repo names, so the stars and activity of repos that weren't already found are looked up
+	for _, q := range d.CodeQueries {
+		fullNames, err := gh.SearchCode(ctx, q, d.MaxResults)
+		if err != nil {
+			return nil, fmt.Errorf("error running discovery code query \"%s\": %v", q, err)
+		}
+		var missing []string
+		for _, name := range fullNames {
+			if existing, ok := byName[strings.ToLower(name)]; ok {
+				existing.queries = append(existing.queries, q)
+				continue
+			}
+			missing = append(missing, name)
+		}
+		repos, err := gh.GetRepositories(ctx, missing)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up repos found by discovery code query \"%s\": %v", q, err)
+		}
+		for _, name := range missing {
+			if r, ok := repos[name]; ok {
+				add(r, q)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// skipReason returns why a repo fails the discovery filters, or an empty string when it's a candidate
+func skipReason(r *github.Repository, d config.Discovery, now time.Time) string {
+	if r.GetArchived() || r.GetDisabled() {
+		return "archived"
+	}
+	if d.ExcludeForks && r.GetFork() {
+		return "fork"
+	}
+	if r.GetStargazersCount() < d.MinStars {
+		return fmt.Sprintf("%d stars", r.GetStargazersCount())
+	}
+	if r.GetPushedAt().Time.Before(now.AddDate(0, 0, -d.ActiveDays)) {
+		return fmt.Sprintf("last pushed %s", r.GetPushedAt().Format("2006-01-02"))
+	}
+	return ""
+}
+
+// candidateFromGitHub maps a repo found by the discovery searches to a discovery_candidates row
+func candidateFromGitHub(r *github.Repository, queries []string, now time.Time) discoverycandidates.Candidate {
+	return discoverycandidates.Candidate{
+		GitHubID:    r.GetID(),
+		Owner:       r.GetOwner().GetLogin(),
+		Repo:        r.GetName(),
+		URL:         r.GetHTMLURL(),
+		Description: r.GetDescription(),
+		Language:    r.GetLanguage(),
+		Stars:       r.GetStargazersCount(),
+		Fork:        r.GetFork(),
+		PushedAt:    r.GetPushedAt().Time,
+		Queries:     queries,
+		FirstSeen:   now,
+		LastSeen:    now,
+	}
+}
+
+// knownRepos holds the repos that are already collected, which are never discovery candidates
+type knownRepos struct {
+	ids   map[int64]bool
+	names map[string]bool                       // Lowercased owner/repo
+	orgs  map[string]config.GithubOrganizations // Organizations from the config file by lowercased name
+}
+
+// has returns whether a repo found by the discovery searches is already collected. A repo in a configured org is only collected when it passes
+// the org's filters, the same as the collector applies, so repos an org's globs or fork and archived settings leave out can still be candidates
+func (k knownRepos) has(r *github.Repository) bool {
+	if k.ids[r.GetID()] || k.names[strings.ToLower(r.GetFullName())] {
+		return true
+	}
+	org, ok := k.orgs[strings.ToLower(r.GetOwner().GetLogin())]
+	return ok && !(org.ExcludeForks && r.GetFork()) && !(org.ExcludeArchived && r.GetArchived()) && org.Includes(r.GetName())
+}
+
+// loadKnownRepos gathers the repos in the config file and the repos table, including their previous names
+func loadKnownRepos(ctx context.Context, cfg config.Config) (knownRepos, error) {
+	k := newKnownRepos(cfg)
+
+	repoRows, err := repos.GetAllRows(ctx)
+	if err != nil {
+		return k, err
+	}
+	for _, r := range repoRows {
+		if r.GitHubID != 0 {
+			k.ids[r.GitHubID] = true
+		}
+		k.names[strings.ToLower(r.Owner+"/"+r.Repo)] = true
+	}
+
+	aliases, err := repos.GetAllAliases(ctx)
+	if err != nil {
+		return k, err
+	}
+	for _, a := range aliases {
+		k.names[strings.ToLower(a.Owner+"/"+a.Repo)] = true
+	}
+
+	return k, nil
+}
+
+// newKnownRepos returns the known repos from the config file alone
+func newKnownRepos(cfg config.Config) knownRepos {
+	k := knownRepos{ids: make(map[int64]bool), names: make(map[string]bool), orgs: make(map[string]config.GithubOrganizations)}
+	for _, org := range cfg.GithubOrganizations {
+		k.orgs[strings.ToLower(org.Name)] = org
+	}
+	for _, repo := range cfg.IndividualRepositories {
+		u, err := url.Parse(repo.URL)
+		if err != nil {
+			continue
+		}
+		k.names[strings.ToLower(strings.Trim(u.Path, "/"))] = true
+	}
+	return k
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+)
+
+func TestSkipReason(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	d := config.Discovery{MinStars: 5, ExcludeForks: true}.WithDefaults()
+	pushed := func(days int) *github.Timestamp {
+		return &github.Timestamp{Time: now.AddDate(0, 0, -days)}
+	}
+
+	var tests = []struct {
+		name     string
+		repo     *github.Repository
+		expected string
+	}{
+		{"candidate", &github.Repository{StargazersCount: github.Int(5), PushedAt: pushed(10)}, ""},
+		{"archived", &github.Repository{StargazersCount: github.Int(50), PushedAt: pushed(10), Archived: github.Bool(true)}, "archived"},
+		{"fork", &github.Repository{StargazersCount: github.Int(50), PushedAt: pushed(10), Fork: github.Bool(true)}, "fork"},
+		{"few stars", &github.Repository{StargazersCount: github.Int(4), PushedAt: pushed(10)}, "4 stars"},
+		{"inactive", &github.Repository{StargazersCount: github.Int(50), PushedAt: pushed(400)}, "last pushed 2025-09-14"},
+		{"never pushed", &github.Repository{StargazersCount: github.Int(50)}, "last pushed 0001-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := skipReason(tt.repo, d, now)
+			if result != tt.expected {
+				t.Errorf("Result fail. Received %v, Expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKnownRepos(t *testing.T) {
+	k := newKnownRepos(config.Config{
+		GithubOrganizations:    []config.GithubOrganizations{{Name: "Chia-Network", ExcludeForks: true, Exclude: []string{"*-archive"}}},
+		IndividualRepositories: []config.Repository{{URL: "https://github.com/Someone/Chialisp-Toolkit"}},
+	})
+	k.ids[42] = true
+
+	repo := func(id int64, owner, name string) *github.Repository {
+		return &github.Repository{ID: github.Int64(id), Name: github.String(name), FullName: github.String(owner + "/" + name), Owner: &github.User{Login: github.String(owner)}}
+	}
+	fork := func(id int64, owner, name string) *github.Repository {
+		r := repo(id, owner, name)
+		r.Fork = github.Bool(true)
+		return r
+	}
+	var tests = []struct {
+		repo     *github.Repository
+		expected bool
+	}{
+		{repo(1, "chia-network", "chia-blockchain"), true},
+		// Repos the org's filters leave out aren't collected
+		{repo(4, "chia-network", "docs-archive"), false},
+		{fork(5, "chia-network", "chia-blockchain-fork"), false},
+		{repo(2, "someone", "chialisp-toolkit"), true},
+		{repo(42, "renamed", "repo"), true},
+		{repo(3, "someone", "other"), false},
+	}
+
+	for _, tt := range tests {
+		result := k.has(tt.repo)
+		if result != tt.expected {
+			t.Errorf("Result fail for %s. Received %v, Expected %v", tt.repo.GetFullName(), result, tt.expected)
+		}
+	}
+}
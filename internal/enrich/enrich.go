@@ -0,0 +1,225 @@
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	repometadata "github.com/chia-network/ecosystem-activity/internal/db/repo_metadata"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	gh "github.com/chia-network/ecosystem-activity/internal/github"
+)
+
+// repoBatchSize is the number of repos looked up from the GitHub API and written to the db at a time
+const repoBatchSize = 100
+
+// Schedule creates a cron for refreshing the repo_metadata and repo_snapshots tables, returning it so it can be stopped.
+// A run in progress stops early when ctx is cancelled
+func Schedule(ctx context.Context, schedule string) *cron.Cron {
+	log.Infof("registering repo metadata cron with schedule \"%s\"", schedule)
+	c := cron.New()
+	_, err := c.AddFunc(schedule, func() {
+		RunRepoMetadata(ctx)
+	})
+	if err != nil {
+		log.Errorf("error encountered registering repo metadata cron: %v", err)
+	}
+	c.Start()
+	return c
+}
+
+// RunRepoMetadata looks up the GitHub attributes of every repo in the repos table, updating the repo_metadata table and taking today's snapshot in the repo_snapshots table.
+// It stops between batches of repos when ctx is cancelled
+func RunRepoMetadata(ctx context.Context) {
+	log.Info("Running the repo metadata enrichment for the repo_metadata and repo_snapshots tables")
+
+	repoRows, err := repos.GetAllRows(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now().UTC()
+	var refreshed, missing int
+	for start := 0; start < len(repoRows); start += repoBatchSize {
+		if ctx.Err() != nil {
+			log.Infof("Stopped the repo metadata enrichment after refreshing %d repos", refreshed)
+			return
+		}
+		batch := repoRows[start:min(start+repoBatchSize, len(repoRows))]
+		fullNames := make([]string, 0, len(batch))
+		for _, r := range batch {
+			fullNames = append(fullNames, fmt.Sprintf("%s/%s", r.Owner, r.Repo))
+		}
+
+		found, err := gh.GetRepositories(ctx, fullNames)
+		if err != nil {
+			log.Errorf("error looking up repo metadata for %d repos: %v", len(batch), err)
+			continue
+		}
+
+		var rows []repometadata.RepoMetadata
+		for i, r := range batch {
+			ghRepo, ok := found[fullNames[i]]
+			if !ok {
+				log.Debugf("no repo metadata found for %s", fullNames[i])
+				missing++
+				continue
+			}
+			err := followRename(ctx, r, ghRepo)
+			if err != nil {
+				log.Error(err)
+			}
+			err = recordForkParent(ctx, r, ghRepo)
+			if err != nil {
+				log.Error(err)
+			}
+			rows = append(rows, repoMetadataFromGitHub(r.ID, ghRepo, now))
+		}
+
+		err = writeRepoMetadata(ctx, rows, now)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		refreshed += len(rows)
+	}
+
+	log.Infof("Refreshed repo metadata for %d repos, %d could not be found", refreshed, missing)
+}
+
+// followRename fills in the GitHub ID of a repo's row if it's missing, and renames the row when GitHub answered for the repo
+// under a new name after a rename or transfer
+func followRename(ctx context.Context, row repos.Repo, r *github.Repository) error {
+	if row.GitHubID == 0 && r.GetID() != 0 {
+		err := repos.UpdateGitHubIDByID(ctx, row.ID, r.GetID(), r.GetNodeID())
+		if err != nil {
+			return err
+		}
+	}
+
+	owner, name := r.GetOwner().GetLogin(), r.GetName()
+	if owner == "" || name == "" || (strings.EqualFold(owner, row.Owner) && strings.EqualFold(name, row.Repo)) {
+		return nil
+	}
+	log.Infof("Repo %s/%s is now %s/%s on GitHub, renaming its row and keeping the old name as an alias", row.Owner, row.Repo, owner, name)
+	return repos.Rename(ctx, row.ID, owner, name)
+}
+
+// recordForkParent stores the repo a row was forked from on GitHub, along with the parent's row ID when the parent is tracked too,
+// so commits the fork inherited can be told apart from its own
+func recordForkParent(ctx context.Context, row repos.Repo, r *github.Repository) error {
+	parent := r.GetParent().GetFullName()
+	var parentID int
+	if parent != "" {
+		var err error
+		parentID, err = trackedRepoID(ctx, r.GetParent())
+		if err != nil {
+			return err
+		}
+	}
+	if parent == row.ForkParent && parentID == row.ForkParentID {
+		return nil
+	}
+	if parent != "" {
+		log.Infof("Repo %s/%s is a fork of %s", row.Owner, row.Repo, parent)
+	}
+	return repos.UpdateForkParentByID(ctx, row.ID, parent, parentID)
+}
+
+// trackedRepoID returns the ID of a GitHub repository's row in the repos table, looking it up by GitHub ID, then name, then alias.
+// It returns 0 when the repository isn't tracked
+func trackedRepoID(ctx context.Context, r *github.Repository) (int, error) {
+	if r.GetID() != 0 {
+		rows, err := repos.GetRowsByGitHubID(ctx, r.GetID())
+		if err != nil {
+			return 0, err
+		}
+		if len(rows) > 0 {
+			return rows[0].ID, nil
+		}
+	}
+	owner, name := r.GetOwner().GetLogin(), r.GetName()
+	if owner == "" || name == "" {
+		owner, name, _ = strings.Cut(r.GetFullName(), "/")
+	}
+	rows, err := repos.GetRowsByOwnerAndRepo(ctx, owner, name)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		rows, err = repos.GetRowsByAlias(ctx, owner, name)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].ID, nil
+}
+
+// writeRepoMetadata updates the repo_metadata table and today's snapshots for a batch of repos in one transaction
+func writeRepoMetadata(ctx context.Context, rows []repometadata.RepoMetadata, now time.Time) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for repo metadata: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for repo metadata: %v", err)
+		}
+	}(tx)
+
+	for _, m := range rows {
+		err = repometadata.Upsert(ctx, tx, m)
+		if err != nil {
+			return err
+		}
+		err = repometadata.SetSnapshot(ctx, tx, m, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction for repo metadata: %v", err)
+	}
+	return nil
+}
+
+// repoMetadataFromGitHub picks the attributes stored in the repo_metadata table out of a GitHub API repository
+func repoMetadataFromGitHub(repoID int, r *github.Repository, now time.Time) repometadata.RepoMetadata {
+	m := repometadata.RepoMetadata{
+		RepoID:        repoID,
+		Description:   r.GetDescription(),
+		Language:      r.GetLanguage(),
+		Topics:        r.Topics,
+		Stars:         r.GetStargazersCount(),
+		Forks:         r.GetForksCount(),
+		Watchers:      r.GetSubscribersCount(),
+		Archived:      r.GetArchived(),
+		Disabled:      r.GetDisabled(),
+		DefaultBranch: r.GetDefaultBranch(),
+		CreatedAt:     r.GetCreatedAt().Time,
+		PushedAt:      r.GetPushedAt().Time,
+		RefreshedAt:   now,
+	}
+	if r.License != nil {
+		m.License = r.License.GetSPDXID()
+		if m.License == "" || m.License == "NOASSERTION" {
+			m.License = r.License.GetName()
+		}
+	}
+	return m
+}
@@ -0,0 +1,35 @@
+package enrich
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+func TestRepoMetadataFromGitHub(t *testing.T) {
+	now := time.Date(2023, time.May, 3, 0, 0, 0, 0, time.UTC)
+	r := &github.Repository{
+		Description:      github.String("Chia blockchain"),
+		Language:         github.String("Python"),
+		Topics:           []string{"chia", "blockchain"},
+		License:          &github.License{SPDXID: github.String("NOASSERTION"), Name: github.String("Other")},
+		StargazersCount:  github.Int(10800),
+		ForksCount:       github.Int(2000),
+		SubscribersCount: github.Int(380),
+		Archived:         github.Bool(true),
+		DefaultBranch:    github.String("main"),
+		CreatedAt:        &github.Timestamp{Time: time.Date(2019, time.August, 21, 0, 0, 0, 0, time.UTC)},
+	}
+
+	m := repoMetadataFromGitHub(7, r, now)
+	if m.RepoID != 7 || m.Stars != 10800 || m.Forks != 2000 || m.Watchers != 380 || !m.Archived || m.Disabled {
+		t.Errorf("Result fail. Received %+v", m)
+	}
+	if m.License != "Other" {
+		t.Errorf("Result fail. Received license %s, Expected the name when there's no SPDX ID", m.License)
+	}
+	if !m.PushedAt.IsZero() || !m.RefreshedAt.Equal(now) || m.CreatedAt.Year() != 2019 {
+		t.Errorf("Result fail. Received timestamps %v %v %v", m.CreatedAt, m.PushedAt, m.RefreshedAt)
+	}
+}
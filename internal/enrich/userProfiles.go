@@ -0,0 +1,203 @@
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	userprofiles "github.com/chia-network/ecosystem-activity/internal/db/user_profiles"
+	gh "github.com/chia-network/ecosystem-activity/internal/github"
+)
+
+// The affiliations a user can be classified as
+const (
+	AffiliationInternal  = "internal"
+	AffiliationPartner   = "partner"
+	AffiliationCommunity = "community"
+)
+
+// userProfileMaxAge is how old a user's profile can get before it's looked up from GitHub again.
+// Affiliations are reclassified from the stored profiles on every run, so config changes apply right away
+const userProfileMaxAge = 7 * 24 * time.Hour
+
+// userBatchSize is the number of user profiles written to the db in one transaction
+const userBatchSize = 100
+
+// ScheduleUserProfiles creates a cron for refreshing the user_profiles and user_orgs tables and classifying users by the affiliation rules in config,
+// returning it so it can be stopped. A run in progress stops early when ctx is cancelled
+func ScheduleUserProfiles(ctx context.Context, schedule string, affiliations config.Affiliations) *cron.Cron {
+	log.Infof("registering user profile cron with schedule \"%s\"", schedule)
+	c := cron.New()
+	_, err := c.AddFunc(schedule, func() {
+		RunUserProfiles(ctx, affiliations)
+	})
+	if err != nil {
+		log.Errorf("error encountered registering user profile cron: %v", err)
+	}
+	c.Start()
+	return c
+}
+
+// RunUserProfiles looks up the GitHub profile and public organizations of every user whose profile is missing or older than userProfileMaxAge,
+// then classifies every user by the affiliation rules, writing the profiles that changed every userBatchSize users so a run that's stopped part way
+// keeps what it looked up. When ctx is cancelled, the profiles changed since the last batch are written before returning
+func RunUserProfiles(ctx context.Context, affiliations config.Affiliations) {
+	log.Info("Running the user profile enrichment for the user_profiles and user_orgs tables")
+
+	profiles, err := userprofiles.GetAllRows(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now().UTC()
+	var (
+		changed                     []userprofiles.UserProfile
+		refreshed, missing, written int
+	)
+	flush := func(ctx context.Context) {
+		if len(changed) == 0 {
+			return
+		}
+		err := writeUserProfiles(ctx, changed)
+		if err != nil {
+			log.Error(err)
+		} else {
+			written += len(changed)
+		}
+		changed = changed[:0]
+	}
+	for _, p := range profiles {
+		if ctx.Err() != nil {
+			log.Infof("Stopped the user profile enrichment after refreshing %d users", refreshed)
+			break
+		}
+		dirty := false
+		if now.Sub(p.RefreshedAt) > userProfileMaxAge {
+			u, orgs, statusCode, err := gh.GetUser(ctx, p.Username)
+			switch {
+			case statusCode == 404:
+				// Deleted accounts, organizations, and bots aren't found, so leave what's known about them until the next refresh
+				log.Debugf("no GitHub profile found for %s", p.Username)
+				missing++
+			case err != nil:
+				log.Errorf("error looking up GitHub profile for %s: %v", p.Username, err)
+				continue
+			default:
+				p = userProfileFromGitHub(p, u, orgs)
+				refreshed++
+			}
+			p.RefreshedAt = now
+			dirty = true
+		}
+
+		affiliation := Classify(affiliations, p)
+		if affiliation != p.Affiliation {
+			p.Affiliation = affiliation
+			dirty = true
+		}
+		if dirty {
+			changed = append(changed, p)
+		}
+		if len(changed) >= userBatchSize {
+			flush(ctx)
+		}
+	}
+	// The last batch is written even when the run was stopped, so the lookups it holds aren't repeated
+	flush(context.WithoutCancel(ctx))
+
+	log.Infof("Refreshed user profiles for %d users, %d could not be found, %d rows changed", refreshed, missing, written)
+}
+
+// writeUserProfiles upserts a batch of user profiles and their organizations in one transaction
+func writeUserProfiles(ctx context.Context, profiles []userprofiles.UserProfile) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for user profiles: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for user profiles: %v", err)
+		}
+	}(tx)
+
+	for _, p := range profiles {
+		err = userprofiles.Upsert(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction for user profiles: %v", err)
+	}
+	return nil
+}
+
+// userProfileFromGitHub copies the profile fields stored in the user_profiles table out of a GitHub API user
+func userProfileFromGitHub(p userprofiles.UserProfile, u *github.User, orgs []string) userprofiles.UserProfile {
+	p.GitHubID = u.GetID()
+	p.Name = u.GetName()
+	p.Company = u.GetCompany()
+	p.Location = u.GetLocation()
+	p.AccountType = u.GetType()
+	p.GitHubCreatedAt = u.GetCreatedAt().Time
+	p.Orgs = orgs
+	return p
+}
+
+// Classify returns the affiliation of a user by the rules in config. Users listed by name are classified first,
+// then users matching the internal org or company rules, then the partner org or company rules. Everyone else is community
+func Classify(affiliations config.Affiliations, p userprofiles.UserProfile) string {
+	switch {
+	case containsFold(affiliations.Internal.Users, p.Username):
+		return AffiliationInternal
+	case containsFold(affiliations.Partner.Users, p.Username):
+		return AffiliationPartner
+	case matchesOrgsOrCompany(affiliations.Internal, p):
+		return AffiliationInternal
+	case matchesOrgsOrCompany(affiliations.Partner, p):
+		return AffiliationPartner
+	default:
+		return AffiliationCommunity
+	}
+}
+
+// matchesOrgsOrCompany reports whether a user is a member of one of a rule's orgs, or lists one of its companies on their profile
+func matchesOrgsOrCompany(rule config.AffiliationRule, p userprofiles.UserProfile) bool {
+	for _, o := range p.Orgs {
+		if containsFold(rule.Orgs, o) {
+			return true
+		}
+	}
+	company := normalizeCompany(p.Company)
+	if company == "" {
+		return false
+	}
+	return slices.ContainsFunc(rule.Companies, func(c string) bool {
+		return normalizeCompany(c) == company
+	})
+}
+
+// normalizeCompany lowercases a company from a GitHub profile and drops the leading @ GitHub uses to link an organization, along with trailing punctuation
+func normalizeCompany(company string) string {
+	return strings.TrimRight(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(company)), "@"), " .,")
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(l string) bool {
+		return strings.EqualFold(l, s)
+	})
+}
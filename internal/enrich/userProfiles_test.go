@@ -0,0 +1,44 @@
+package enrich
+
+import (
+	"testing"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	userprofiles "github.com/chia-network/ecosystem-activity/internal/db/user_profiles"
+)
+
+func TestClassify(t *testing.T) {
+	affiliations := config.Affiliations{
+		Internal: config.AffiliationRule{
+			Users:     []string{"contractor"},
+			Orgs:      []string{"chia-network"},
+			Companies: []string{"Chia Network Inc"},
+		},
+		Partner: config.AffiliationRule{
+			Users:     []string{"alice"},
+			Orgs:      []string{"chia-mineos"},
+			Companies: []string{"@partner-co"},
+		},
+	}
+
+	tests := []struct {
+		profile userprofiles.UserProfile
+		expect  string
+	}{
+		{userprofiles.UserProfile{Username: "Contractor"}, AffiliationInternal},
+		{userprofiles.UserProfile{Username: "dev", Orgs: []string{"Chia-Network"}}, AffiliationInternal},
+		{userprofiles.UserProfile{Username: "dev", Company: "chia network inc."}, AffiliationInternal},
+		// Explicitly listed users win over org membership
+		{userprofiles.UserProfile{Username: "alice", Orgs: []string{"chia-network"}}, AffiliationPartner},
+		{userprofiles.UserProfile{Username: "dev", Orgs: []string{"other", "chia-mineos"}}, AffiliationPartner},
+		{userprofiles.UserProfile{Username: "dev", Company: "Partner-Co"}, AffiliationPartner},
+		{userprofiles.UserProfile{Username: "dev", Company: "Chia Network Incorporated"}, AffiliationCommunity},
+		{userprofiles.UserProfile{Username: "dev"}, AffiliationCommunity},
+	}
+	for _, test := range tests {
+		result := Classify(affiliations, test.profile)
+		if result != test.expect {
+			t.Errorf("Result fail for %+v. Received %s, Expected %s", test.profile, result, test.expect)
+		}
+	}
+}
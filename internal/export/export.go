@@ -0,0 +1,207 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/db/users"
+	"github.com/chia-network/ecosystem-activity/internal/utils"
+)
+
+// The ways bots can be filtered, by matching usernames with utils.MatchesBot
+const (
+	BotsInclude = "include"
+	BotsExclude = "exclude"
+	BotsOnly    = "only"
+)
+
+// Options narrows the rows exported
+type Options struct {
+	Filter commits.Filter
+	Bots   string // One of the bot filters, an empty string includes bots
+}
+
+// keepUsername returns whether rows by a user pass the bot filter
+func (o Options) keepUsername(username string) bool {
+	switch o.Bots {
+	case BotsExclude:
+		return !utils.MatchesBot(username)
+	case BotsOnly:
+		return utils.MatchesBot(username)
+	default:
+		return true
+	}
+}
+
+// Validate returns an error for an unsupported bot filter
+func (o Options) Validate() error {
+	switch o.Bots {
+	case "", BotsInclude, BotsExclude, BotsOnly:
+		return nil
+	default:
+		return fmt.Errorf("unsupported bot filter \"%s\", expected one of include, exclude, or only", o.Bots)
+	}
+}
+
+// Commit is an exported commit, joined with its repo's owner and name and its author's username
+type Commit struct {
+	ID        int       `json:"id" parquet:"id"`
+	Date      time.Time `json:"date" parquet:"date,timestamp"`
+	SHA       string    `json:"sha" parquet:"sha"`
+	RepoID    int       `json:"repo_id" parquet:"repo_id"`
+	Owner     string    `json:"owner" parquet:"owner"`
+	Repo      string    `json:"repo" parquet:"repo"`
+	UserID    int       `json:"user_id" parquet:"user_id"`
+	Username  string    `json:"username" parquet:"username"`
+	IsBot     bool      `json:"is_bot" parquet:"is_bot"`
+	Inherited bool      `json:"inherited" parquet:"inherited"` // In another repo it's attributed to instead, ie. history a fork inherited
+}
+
+func (c Commit) csvHeader() []string {
+	return []string{"id", "date", "sha", "repo_id", "owner", "repo", "user_id", "username", "is_bot", "inherited"}
+}
+
+func (c Commit) csvRecord() []string {
+	return []string{strconv.Itoa(c.ID), formatTime(&c.Date), c.SHA, strconv.Itoa(c.RepoID), c.Owner, c.Repo, strconv.Itoa(c.UserID), c.Username, strconv.FormatBool(c.IsBot),
+		strconv.FormatBool(c.Inherited)}
+}
+
+// User is an exported user
+type User struct {
+	ID          int        `json:"id" parquet:"id"`
+	Username    string     `json:"username" parquet:"username"`
+	FirstCommit *time.Time `json:"first_commit" parquet:"first_commit,optional,timestamp"`
+	LastCommit  *time.Time `json:"last_commit" parquet:"last_commit,optional,timestamp"`
+	IsBot       bool       `json:"is_bot" parquet:"is_bot"`
+}
+
+func (u User) csvHeader() []string {
+	return []string{"id", "username", "first_commit", "last_commit", "is_bot"}
+}
+
+func (u User) csvRecord() []string {
+	return []string{strconv.Itoa(u.ID), u.Username, formatTime(u.FirstCommit), formatTime(u.LastCommit), strconv.FormatBool(u.IsBot)}
+}
+
+// Repo is an exported repo
+type Repo struct {
+	ID          int        `json:"id" parquet:"id"`
+	Owner       string     `json:"owner" parquet:"owner"`
+	Repo        string     `json:"repo" parquet:"repo"`
+	GitHubID    int64      `json:"github_id" parquet:"github_id"` // 0 until the repo has been collected by its GitHub ID
+	FirstCommit *time.Time `json:"first_commit" parquet:"first_commit,optional,timestamp"`
+	LastCommit  *time.Time `json:"last_commit" parquet:"last_commit,optional,timestamp"`
+	GoneAt      *time.Time `json:"gone_at" parquet:"gone_at,optional,timestamp"`
+	ForkParent  string     `json:"fork_parent" parquet:"fork_parent"` // owner/repo the repo was forked from on GitHub, empty for repos that aren't forks
+}
+
+func (r Repo) csvHeader() []string {
+	return []string{"id", "owner", "repo", "github_id", "first_commit", "last_commit", "gone_at", "fork_parent"}
+}
+
+func (r Repo) csvRecord() []string {
+	return []string{strconv.Itoa(r.ID), r.Owner, r.Repo, strconv.FormatInt(r.GitHubID, 10), formatTime(r.FirstCommit), formatTime(r.LastCommit), formatTime(r.GoneAt), r.ForkParent}
+}
+
+// optionalTime returns nil for zero times, which are NULL in the db
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// formatTime formats a time for CSV, leaving missing times empty
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Commits streams the commits matching opts to w in one of the export formats, returning the number of rows written
+func Commits(ctx context.Context, w io.Writer, format string, opts Options) (int, error) {
+	return write(w, format, func(write func(Commit) error) error {
+		return commits.StreamExportRows(ctx, opts.Filter, func(e commits.ExportRow) error {
+			if !opts.keepUsername(e.Username) {
+				return nil
+			}
+			return write(Commit{
+				ID:        e.ID,
+				Date:      e.Date,
+				SHA:       e.SHA,
+				RepoID:    e.RepoID,
+				Owner:     e.Owner,
+				Repo:      e.Repo,
+				UserID:    e.UserID,
+				Username:  e.Username,
+				IsBot:     utils.MatchesBot(e.Username),
+				Inherited: e.Inherited,
+			})
+		})
+	})
+}
+
+// Users streams the users who authored commits matching opts to w in one of the export formats, returning the number of rows written
+func Users(ctx context.Context, w io.Writer, format string, opts Options) (int, error) {
+	return write(w, format, func(write func(User) error) error {
+		return users.StreamRows(ctx, opts.Filter, func(u users.User) error {
+			if !opts.keepUsername(u.Username) {
+				return nil
+			}
+			return write(User{
+				ID:          u.ID,
+				Username:    u.Username,
+				FirstCommit: optionalTime(u.FirstCommit),
+				LastCommit:  optionalTime(u.LastCommit),
+				IsBot:       utils.MatchesBot(u.Username),
+			})
+		})
+	})
+}
+
+// Repos streams the repos matching opts to w in one of the export formats, returning the number of rows written. Repos aren't filtered by bots
+func Repos(ctx context.Context, w io.Writer, format string, opts Options) (int, error) {
+	return write(w, format, func(write func(Repo) error) error {
+		return repos.StreamRows(ctx, opts.Filter, func(r repos.Repo) error {
+			return write(Repo{
+				ID:          r.ID,
+				Owner:       r.Owner,
+				Repo:        r.Repo,
+				GitHubID:    r.GitHubID,
+				FirstCommit: optionalTime(r.FirstCommit),
+				LastCommit:  optionalTime(r.LastCommit),
+				GoneAt:      optionalTime(r.GoneAt),
+				ForkParent:  r.ForkParent,
+			})
+		})
+	})
+}
+
+// write creates a writer for the format and passes stream a function writing one row to it, returning the number of rows written
+func write[T Row](w io.Writer, format string, stream func(write func(T) error) error) (int, error) {
+	writer, err := NewWriter[T](w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = stream(func(row T) error {
+		n++
+		return writer.Write(row)
+	})
+	if err != nil {
+		return n, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return n, err
+	}
+	return n, nil
+}
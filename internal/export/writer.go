@@ -0,0 +1,138 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// The formats data can be exported in
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// parquetBatchSize is the number of rows buffered before they're handed to the Parquet writer
+const parquetBatchSize = 1024
+
+// parquetRowGroupSize bounds the rows the Parquet writer holds in memory before flushing a row group to the output
+const parquetRowGroupSize = 100000
+
+// Row is one exported row. JSON Lines and Parquet columns come from the struct tags of the row type, CSV columns from these methods
+type Row interface {
+	csvHeader() []string
+	csvRecord() []string
+}
+
+// Writer writes rows of one type to an output in one of the export formats. Close must be called to flush the output
+type Writer[T Row] interface {
+	Write(row T) error
+	Close() error
+}
+
+// NewWriter returns a writer of rows to w in one of the export formats
+func NewWriter[T Row](w io.Writer, format string) (Writer[T], error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter[T]{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlWriter[T]{enc: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return &parquetWriter[T]{w: parquet.NewGenericWriter[T](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize))}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format \"%s\", expected one of csv, jsonl, or parquet", format)
+	}
+}
+
+// csvWriter writes a header line before the first row
+type csvWriter[T Row] struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter[T]) Write(row T) error {
+	if !c.headerWritten {
+		err := c.w.Write(row.csvHeader())
+		if err != nil {
+			return fmt.Errorf("error writing CSV header: %v", err)
+		}
+		c.headerWritten = true
+	}
+	err := c.w.Write(row.csvRecord())
+	if err != nil {
+		return fmt.Errorf("error writing CSV row: %v", err)
+	}
+	return nil
+}
+
+func (c *csvWriter[T]) Close() error {
+	// An export without rows still gets its header
+	if !c.headerWritten {
+		var zero T
+		err := c.w.Write(zero.csvHeader())
+		if err != nil {
+			return fmt.Errorf("error writing CSV header: %v", err)
+		}
+	}
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV output: %v", err)
+	}
+	return nil
+}
+
+// jsonlWriter writes one JSON object per line
+type jsonlWriter[T Row] struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter[T]) Write(row T) error {
+	err := j.enc.Encode(row)
+	if err != nil {
+		return fmt.Errorf("error writing JSON Lines row: %v", err)
+	}
+	return nil
+}
+
+func (j *jsonlWriter[T]) Close() error {
+	return nil
+}
+
+// parquetWriter buffers rows into small batches for the Parquet writer, which flushes a row group to the output every parquetRowGroupSize rows
+type parquetWriter[T Row] struct {
+	w     *parquet.GenericWriter[T]
+	batch []T
+}
+
+func (p *parquetWriter[T]) Write(row T) error {
+	p.batch = append(p.batch, row)
+	if len(p.batch) >= parquetBatchSize {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetWriter[T]) flush() error {
+	_, err := p.w.Write(p.batch)
+	if err != nil {
+		return fmt.Errorf("error writing Parquet rows: %v", err)
+	}
+	p.batch = p.batch[:0]
+	return nil
+}
+
+func (p *parquetWriter[T]) Close() error {
+	err := p.flush()
+	if err != nil {
+		return err
+	}
+	err = p.w.Close()
+	if err != nil {
+		return fmt.Errorf("error closing Parquet output: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func testUsers() []User {
+	first := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	last := time.Date(2026, time.September, 30, 8, 30, 0, 0, time.UTC)
+	return []User{
+		{ID: 1, Username: "alice", FirstCommit: &first, LastCommit: &last},
+		{ID: 2, Username: "dependabot[bot]", IsBot: true},
+	}
+}
+
+func writeAll[T Row](t *testing.T, format string, rows []T) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter[T](&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		err = w.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	result := string(writeAll(t, FormatCSV, testUsers()))
+	expect := "id,username,first_commit,last_commit,is_bot\n" +
+		"1,alice,2024-03-01T12:00:00Z,2026-09-30T08:30:00Z,false\n" +
+		"2,dependabot[bot],,,true\n"
+	if result != expect {
+		t.Errorf("Result fail. Received %q, Expected %q", result, expect)
+	}
+
+	// An empty export still has a header
+	result = string(writeAll(t, FormatCSV, []Repo{}))
+	expect = "id,owner,repo,github_id,first_commit,last_commit,gone_at,fork_parent\n"
+	if result != expect {
+		t.Errorf("Result fail. Received %q, Expected %q", result, expect)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	result := string(writeAll(t, FormatJSONL, testUsers()))
+	expect := `{"id":1,"username":"alice","first_commit":"2024-03-01T12:00:00Z","last_commit":"2026-09-30T08:30:00Z","is_bot":false}` + "\n" +
+		`{"id":2,"username":"dependabot[bot]","first_commit":null,"last_commit":null,"is_bot":true}` + "\n"
+	if result != expect {
+		t.Errorf("Result fail. Received %q, Expected %q", result, expect)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	// Write more rows than a batch, so rows are flushed to the Parquet writer during and after writing
+	var expect []Commit
+	for i := 0; i < parquetBatchSize+10; i++ {
+		expect = append(expect, Commit{
+			ID:       i + 1,
+			Date:     time.Date(2026, time.September, 1, 0, 0, i, 0, time.UTC),
+			SHA:      "abc123",
+			RepoID:   1,
+			Owner:    "Chia-Network",
+			Repo:     "chia-blockchain",
+			UserID:   1,
+			Username: "alice",
+		})
+	}
+	data := writeAll(t, FormatParquet, expect)
+
+	result, err := parquet.Read[Commit](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != len(expect) {
+		t.Fatalf("Result fail. Received %d rows, Expected %d", len(result), len(expect))
+	}
+	for i := range expect {
+		result[i].Date = result[i].Date.UTC()
+		if !reflect.DeepEqual(result[i], expect[i]) {
+			t.Fatalf("Result fail. Received %+v, Expected %+v", result[i], expect[i])
+		}
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter[User](&bytes.Buffer{}, "xlsx")
+	if err == nil {
+		t.Error("Result fail. Received no error for an unsupported format")
+	}
+}
+
+func TestKeepUsername(t *testing.T) {
+	tests := []struct {
+		bots     string
+		username string
+		expect   bool
+	}{
+		{"", "dependabot[bot]", true},
+		{BotsInclude, "alice", true},
+		{BotsExclude, "dependabot[bot]", false},
+		{BotsExclude, "alice", true},
+		{BotsOnly, "dependabot[bot]", true},
+		{BotsOnly, "alice", false},
+	}
+	for _, test := range tests {
+		if result := (Options{Bots: test.bots}).keepUsername(test.username); result != test.expect {
+			t.Errorf("Result fail for %s with bots %q. Received %v, Expected %v", test.username, test.bots, result, test.expect)
+		}
+	}
+}
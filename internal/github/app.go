@@ -0,0 +1,285 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// headerRouteOwner lets callers that don't put the owner in the URL path (GraphQL) tell appTransport which installation to use
+	// appTransport removes it, and tracingTransport removes it from requests that didn't go through an app, ie. with only tokens configured
+	headerRouteOwner = "X-Ecosystem-Activity-Owner"
+
+	// installationTokenSlack is how long before expiry an installation token is replaced
+	installationTokenSlack = 5 * time.Minute
+
+	// installationsTTL is how long the list of installations is trusted before it's requested again
+	installationsTTL = 10 * time.Minute
+)
+
+// installationToken is an access token minted for one installation of the app
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// appTransport authenticates requests as a GitHub App, using the token of the installation on the owner named in the request.
+// Requests for owners the app isn't installed on use the fallback transport (usually authenticated with a personal access token),
+// or any installation's token when there is no fallback, since an installation can still read public repos outside its account.
+type appTransport struct {
+	appID    int64
+	key      *rsa.PrivateKey
+	baseURL  *url.URL
+	base     http.RoundTripper // Sends requests once they're authenticated, and the app's own requests
+	fallback http.RoundTripper // Optional
+
+	mu            sync.Mutex
+	installations map[string]int64 // Installation ID by lowercased account login
+	listedAt      time.Time
+	tokens        map[int64]installationToken
+}
+
+func newAppTransport(appID int64, privateKeyPEM []byte, baseURL *url.URL, base http.RoundTripper, fallback http.RoundTripper) (*appTransport, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &appTransport{
+		appID:    appID,
+		key:      key,
+		baseURL:  baseURL,
+		base:     base,
+		fallback: fallback,
+		tokens:   make(map[int64]installationToken),
+	}, nil
+}
+
+// parsePrivateKey reads an RSA private key in the PKCS#1 format GitHub issues app keys in, or PKCS#8
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in GitHub App private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing GitHub App private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt signs a short lived JSON Web Token that authenticates as the app itself
+func (t *appTransport) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-60 * time.Second).Unix(), // Allow for clock drift between here and GitHub
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(t.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("signing GitHub App JWT: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// appRequest makes a request authenticated as the app and decodes the JSON response into out. path is relative to the API base URL
+func (t *appTransport) appRequest(ctx context.Context, method string, path string, out any) (*http.Response, error) {
+	token, err := t.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.baseURL.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v", method, path, err)
+	}
+	defer func(b io.ReadCloser) {
+		err := b.Close()
+		if err != nil {
+			log.Errorf("error closing GitHub App response body: %v", err)
+		}
+	}(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp, fmt.Errorf("%s %s returned status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return resp, fmt.Errorf("decoding response from %s %s: %v", method, path, err)
+	}
+	return resp, nil
+}
+
+// listInstallations refreshes the map of account logins to installation IDs, returning the new map.
+// The installations are requested without holding t.mu, so requests with tokens already in hand aren't held up
+func (t *appTransport) listInstallations(ctx context.Context) (map[string]int64, error) {
+	installations := make(map[string]int64)
+	for page := 1; ; page++ {
+		var r []struct {
+			ID      int64 `json:"id"`
+			Account struct {
+				Login string `json:"login"`
+			} `json:"account"`
+		}
+		_, err := t.appRequest(ctx, http.MethodGet, fmt.Sprintf("app/installations?per_page=100&page=%d", page), &r)
+		if err != nil {
+			return nil, fmt.Errorf("listing GitHub App installations: %v", err)
+		}
+		for _, i := range r {
+			installations[strings.ToLower(i.Account.Login)] = i.ID
+		}
+		if len(r) < 100 {
+			break
+		}
+	}
+
+	log.Debugf("GitHub App %d has %d installations", t.appID, len(installations))
+	t.mu.Lock()
+	t.installations = installations
+	t.listedAt = time.Now()
+	t.mu.Unlock()
+	return installations, nil
+}
+
+// currentInstallations returns the map of account logins to installation IDs, listing them again when the list is older than installationsTTL
+func (t *appTransport) currentInstallations(ctx context.Context) (map[string]int64, error) {
+	t.mu.Lock()
+	installations, listedAt := t.installations, t.listedAt
+	t.mu.Unlock()
+	if installations == nil || time.Since(listedAt) > installationsTTL {
+		return t.listInstallations(ctx)
+	}
+	return installations, nil
+}
+
+// installationFor returns the installation ID for an owner, or false if the app isn't installed there
+func (t *appTransport) installationFor(ctx context.Context, owner string) (int64, bool, error) {
+	installations, err := t.currentInstallations(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	id, ok := installations[strings.ToLower(owner)]
+	return id, ok, nil
+}
+
+// anyInstallation returns the lowest installation ID, so the choice is stable between requests
+func (t *appTransport) anyInstallation(ctx context.Context) (int64, bool, error) {
+	installations, err := t.currentInstallations(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	var lowest int64
+	for _, id := range installations {
+		if lowest == 0 || id < lowest {
+			lowest = id
+		}
+	}
+	return lowest, lowest != 0, nil
+}
+
+// tokenFor returns a current access token for an installation, minting a new one when the cached token is close to expiry.
+// t.mu is only held to read and swap the cached token, not while minting, so a refresh doesn't hold up requests for other installations
+func (t *appTransport) tokenFor(ctx context.Context, id int64) (string, error) {
+	t.mu.Lock()
+	tok, ok := t.tokens[id]
+	t.mu.Unlock()
+	if ok && time.Until(tok.ExpiresAt) > installationTokenSlack {
+		return tok.Token, nil
+	}
+
+	_, err := t.appRequest(ctx, http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", id), &tok)
+	if err != nil {
+		return "", fmt.Errorf("minting token for GitHub App installation %d: %v", id, err)
+	}
+	log.Debugf("minted token for GitHub App installation %d, expires %s", id, tok.ExpiresAt)
+	t.mu.Lock()
+	t.tokens[id] = tok
+	t.mu.Unlock()
+	return tok.Token, nil
+}
+
+// ownerFromRequest finds the account a request is for from the route hint header or the URL path, ie. /repos/{owner}/... or /orgs/{org}/...
+func (t *appTransport) ownerFromRequest(req *http.Request) string {
+	if owner := req.Header.Get(headerRouteOwner); owner != "" {
+		return owner
+	}
+	path := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(t.baseURL.Path, "/"))
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) >= 2 {
+		switch parts[0] {
+		case "repos", "orgs", "users":
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	owner := t.ownerFromRequest(req)
+
+	// RoundTrippers must not modify the request they're given
+	req = req.Clone(req.Context())
+	req.Header.Del(headerRouteOwner)
+
+	ctx := req.Context()
+	id, ok, err := t.installationFor(ctx, owner)
+	if err == nil && !ok && t.fallback == nil {
+		id, ok, err = t.anyInstallation(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if t.fallback == nil {
+			return nil, fmt.Errorf("GitHub App %d has no installations to authenticate with", t.appID)
+		}
+		return t.fallback.RoundTrip(req)
+	}
+	token, err := t.tokenFor(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(req)
+}
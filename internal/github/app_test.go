@@ -0,0 +1,242 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// appStandIn stands in for the GitHub App endpoints, verifying app JWTs and minting installation tokens that expire after tokenTTL
+type appStandIn struct {
+	t        *testing.T
+	key      *rsa.PublicKey
+	tokenTTL time.Duration
+	minting  chan struct{} // Optional, token requests wait to receive from it before they're answered
+
+	mu     sync.Mutex
+	minted map[string]int // Tokens minted per installation ID
+	seen   map[string]string
+}
+
+func (s *appStandIn) verifyJWT(auth string) error {
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT %s", auth)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(s.key, crypto.SHA256, sum[:], sig)
+	if err != nil {
+		return fmt.Errorf("verifying JWT signature: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		Iss string `json:"iss"`
+		Exp int64  `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return err
+	}
+	if claims.Iss != "42" || claims.Exp < time.Now().Unix() {
+		return fmt.Errorf("unexpected claims %+v", claims)
+	}
+	return nil
+}
+
+func (s *appStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.minting != nil && r.Method == http.MethodPost {
+		<-s.minting
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/app/installations":
+		if err := s.verifyJWT(r.Header.Get("Authorization")); err != nil {
+			s.t.Error(err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id": 1, "account": {"login": "Chia-Network"}}, {"id": 2, "account": {"login": "Chia-Mineos"}}]`))
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/app/installations/"):
+		if err := s.verifyJWT(r.Header.Get("Authorization")); err != nil {
+			s.t.Error(err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/app/installations/"), "/access_tokens")
+		s.minted[id]++
+		_ = json.NewEncoder(w).Encode(installationToken{
+			Token:     fmt.Sprintf("installation-%s-token-%d", id, s.minted[id]),
+			ExpiresAt: time.Now().Add(s.tokenTTL),
+		})
+	default:
+		s.seen[r.URL.Path] = r.Header.Get("Authorization")
+		if r.Header.Get(headerRouteOwner) != "" {
+			s.t.Errorf("route owner header leaked to %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func newAppTestTransport(t *testing.T, tokenTTL time.Duration, withFallback bool) (*appTransport, *appStandIn) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	standIn := &appStandIn{t: t, key: &key.PublicKey, tokenTTL: tokenTTL, minted: make(map[string]int), seen: make(map[string]string)}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fallback http.RoundTripper
+	if withFallback {
+		fallback = newTokenPool(http.DefaultTransport, []string{"personal-token"})
+	}
+	transport, err := newAppTransport(42, keyPEM, baseURL, http.DefaultTransport, fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport, standIn
+}
+
+func TestAppTransportRoutesByOwner(t *testing.T) {
+	transport, standIn := newAppTestTransport(t, time.Hour, true)
+	client := &http.Client{Transport: transport}
+	base := transport.baseURL.String()
+
+	requests := []*http.Request{}
+	for _, path := range []string{"repos/Chia-Network/chia-blockchain/commits", "orgs/chia-mineos/repos", "repos/someone-else/farmer/commits", "repos/Chia-Network/go-chia-libs/commits"} {
+		req, _ := http.NewRequest(http.MethodGet, base+path, nil)
+		requests = append(requests, req)
+	}
+	graphQLReq, _ := http.NewRequest(http.MethodPost, base+"graphql", strings.NewReader(`{}`))
+	graphQLReq.Header.Set(headerRouteOwner, "Chia-Mineos")
+	requests = append(requests, graphQLReq)
+
+	for _, req := range requests {
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	expect := map[string]string{
+		"/repos/Chia-Network/chia-blockchain/commits": "token installation-1-token-1",
+		"/repos/Chia-Network/go-chia-libs/commits":    "token installation-1-token-1",
+		"/orgs/chia-mineos/repos":                     "token installation-2-token-1",
+		"/graphql":                                    "token installation-2-token-1",
+		"/repos/someone-else/farmer/commits":          "Bearer personal-token",
+	}
+	for path, auth := range expect {
+		if standIn.seen[path] != auth {
+			t.Errorf("Result fail for %s. Received %s, Expected %s", path, standIn.seen[path], auth)
+		}
+	}
+	// Tokens are reused until they're close to expiring
+	if standIn.minted["1"] != 1 || standIn.minted["2"] != 1 {
+		t.Errorf("Result fail. Received minted tokens %v, Expected one per installation", standIn.minted)
+	}
+}
+
+func TestAppTransportRefreshesTokens(t *testing.T) {
+	// Tokens that expire within installationTokenSlack are replaced on the next request
+	transport, standIn := newAppTestTransport(t, installationTokenSlack-time.Second, false)
+	client := &http.Client{Transport: transport}
+
+	for i := 1; i <= 2; i++ {
+		resp, err := client.Get(transport.baseURL.String() + "repos/Chia-Network/chia-blockchain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		expect := fmt.Sprintf("token installation-1-token-%d", i)
+		if auth := standIn.seen["/repos/Chia-Network/chia-blockchain"]; auth != expect {
+			t.Errorf("Result fail for request %d. Received %s, Expected %s", i, auth, expect)
+		}
+	}
+
+	// Without a fallback, owners the app isn't installed on use the lowest installation ID
+	resp, err := client.Get(transport.baseURL.String() + "repos/someone-else/farmer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if auth := standIn.seen["/repos/someone-else/farmer"]; !strings.HasPrefix(auth, "token installation-1-") {
+		t.Errorf("Result fail. Received %s, Expected an installation 1 token", auth)
+	}
+}
+
+func TestAppTransportMintsWithoutBlocking(t *testing.T) {
+	transport, standIn := newAppTestTransport(t, time.Hour, false)
+	client := &http.Client{Transport: transport}
+
+	// Installation 1 has a token before minting starts to stall
+	resp, err := client.Get(transport.baseURL.String() + "repos/Chia-Network/chia-blockchain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	standIn.minting = make(chan struct{})
+
+	minted := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(transport.baseURL.String() + "repos/Chia-Mineos/mineos")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		minted <- err
+	}()
+
+	// Requests for installation 1 go through while installation 2 is waiting on its token
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(transport.baseURL.String() + "repos/Chia-Network/chia-blockchain/commits")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Result fail. Request with a cached token waited on another installation's token being minted")
+	}
+
+	close(standIn.minting)
+	if err := <-minted; err != nil {
+		t.Fatal(err)
+	}
+	if auth := standIn.seen["/repos/Chia-Mineos/mineos"]; auth != "token installation-2-token-1" {
+		t.Errorf("Result fail. Received %s, Expected token installation-2-token-1", auth)
+	}
+}
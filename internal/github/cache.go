@@ -0,0 +1,233 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// headerFromCache marks a response that was rebuilt from the cache after a 304, following the convention go-github already recognizes
+const headerFromCache = "X-From-Cache"
+
+// ErrNotModified is returned by ListRepositoryCommitsByPage when the first page of commits is unchanged since the last listing that was
+// handled through to its final page, meaning there is nothing new to process for the repository
+var ErrNotModified = errors.New("not modified since the last request")
+
+// CacheStats counts the conditional requests made through the cache
+type CacheStats struct {
+	Hits   int64 // Requests answered with a 304 and served from the cache
+	Misses int64 // Requests that returned a new response body
+}
+
+// HitRate returns the share of requests that were served from the cache as a percentage
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses) * 100
+}
+
+var cacheHits, cacheMisses atomic.Int64
+
+// TakeCacheStats returns the cache hits and misses counted since it was last called, and resets the counters
+func TakeCacheStats() CacheStats {
+	return CacheStats{
+		Hits:   cacheHits.Swap(0),
+		Misses: cacheMisses.Swap(0),
+	}
+}
+
+// cacheEntry is what's stored for each cached URL
+type cacheEntry struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Link         string `json:"link"` // Pagination links, which aren't always repeated on a 304
+	Body         []byte `json:"body"`
+}
+
+// diskCache stores cache entries as one JSON file per key in a directory
+type diskCache struct {
+	dir string
+}
+
+func (c *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *diskCache) get(key string) (cacheEntry, bool) {
+	var e cacheEntry
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return e, false
+	}
+	err = json.Unmarshal(data, &e)
+	if err != nil {
+		log.Warnf("ignoring unreadable GitHub cache entry for %s: %v", key, err)
+		return e, false
+	}
+	return e, true
+}
+
+func (c *diskCache) set(key string, e cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding GitHub cache entry for %s: %v", key, err)
+	}
+
+	// Write to a temp file and rename over the old entry so a crash never leaves a partial entry behind
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating GitHub cache entry for %s: %v", key, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing GitHub cache entry for %s: %v", key, err)
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+type deferredKey struct{}
+
+// deferredEntries holds the cache entries of responses whose data hasn't been stored by the caller yet.
+// Caching a response before its data is stored would let a later conditional request skip data that was never stored
+type deferredEntries struct {
+	mu      sync.Mutex
+	cache   *diskCache
+	entries map[string]cacheEntry
+}
+
+// withDeferredEntries returns a copy of ctx whose requests through the cache only have their responses cached once save is called on the returned entries
+func withDeferredEntries(ctx context.Context) (context.Context, *deferredEntries) {
+	d := &deferredEntries{entries: make(map[string]cacheEntry)}
+	return context.WithValue(ctx, deferredKey{}, d), d
+}
+
+func (d *deferredEntries) add(cache *diskCache, key string, e cacheEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cache = cache
+	d.entries[key] = e
+}
+
+// save caches the responses held back by withDeferredEntries
+func (d *deferredEntries) save() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, e := range d.entries {
+		err := d.cache.set(key, e)
+		if err != nil {
+			log.Warnf("failed to cache GitHub response: %v", err)
+		}
+	}
+	d.entries = make(map[string]cacheEntry)
+}
+
+// cachingTransport makes GET requests conditional on the ETag and Last-Modified validators of the last response for the same URL.
+// A 304 doesn't count against the rate limit, and is handed back to the client as the cached 200 response marked with headerFromCache.
+type cachingTransport struct {
+	base  http.RoundTripper
+	cache *diskCache
+}
+
+func newCachingTransport(base http.RoundTripper, dir string) (*cachingTransport, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("creating GitHub cache directory %s: %v", dir, err)
+	}
+	return &cachingTransport{base: base, cache: &diskCache{dir: dir}}, nil
+}
+
+// cacheKey identifies a request in the cache. The since and until parameters of a commit listing move forward every pass, so they're left
+// out of the key; GitHub's ETags are derived from the response body, so a dormant repo's empty listing still validates across passes.
+func cacheKey(req *http.Request) string {
+	u := *req.URL
+	q := u.Query()
+	q.Del("since")
+	q.Del("until")
+	u.RawQuery = q.Encode()
+	return req.Method + " " + u.String()
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	entry, cached := t.cache.get(key)
+	if cached {
+		// RoundTrippers must not modify the request they're given
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		cacheHits.Add(1)
+		_ = resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Status = http.StatusText(http.StatusOK)
+		resp.Header.Set(headerFromCache, "1")
+		if resp.Header.Get("Link") == "" && entry.Link != "" {
+			resp.Header.Set("Link", entry.Link)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		resp.ContentLength = int64(len(entry.Body))
+		return resp, nil
+	case resp.StatusCode == http.StatusOK:
+		cacheMisses.Add(1)
+		if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading response body for %s: %v", key, err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		e := cacheEntry{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Link:         resp.Header.Get("Link"),
+			Body:         body,
+		}
+		if d, ok := req.Context().Value(deferredKey{}).(*deferredEntries); ok {
+			d.add(t.cache, key, e)
+			return resp, nil
+		}
+		err = t.cache.set(key, e)
+		if err != nil {
+			log.Warnf("failed to cache GitHub response: %v", err)
+		}
+		return resp, nil
+	default:
+		return resp, nil
+	}
+}
@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+func TestCachingTransport(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"empty"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"empty"`)
+		w.Header().Set("Link", `<https://api.github.com/next>; rel="next"`)
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	transport, err := newCachingTransport(http.DefaultTransport, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+	TakeCacheStats()
+
+	// The second request moves the since and until parameters forward like the collector does between passes
+	for i, url := range []string{
+		server.URL + "/repos/o/r/commits?page=1&since=2023-01-01T00:00:00Z&until=2023-02-01T00:00:00Z",
+		server.URL + "/repos/o/r/commits?page=1&since=2023-02-01T00:00:00Z&until=2023-03-01T00:00:00Z",
+	} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || string(body) != "[]" {
+			t.Errorf("Result fail for request %d. Received %d %s, Expected 200 []", i, resp.StatusCode, body)
+		}
+		if fromCache := resp.Header.Get(headerFromCache) != ""; fromCache != (i == 1) {
+			t.Errorf("Result fail for request %d. Received from cache %v", i, fromCache)
+		}
+		if resp.Header.Get("Link") == "" {
+			t.Errorf("Result fail for request %d. Pagination links were not kept", i)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("Result fail. Received %d requests at the server, Expected 2", requests)
+	}
+	stats := TakeCacheStats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.HitRate() != 50 {
+		t.Errorf("Result fail. Received %+v, Expected 1 hit and 1 miss", stats)
+	}
+}
+
+func TestCachingTransportRetriesFailedPages(t *testing.T) {
+	var page2Requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		etag := `"page-` + page + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if page == "2" {
+			page2Requests++
+			_, _ = w.Write([]byte(`[{"sha":"b"}]`))
+			return
+		}
+		w.Header().Set("Link", `<`+"http://"+r.Host+r.URL.Path+`?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(`[{"sha":"a"}]`))
+	}))
+	defer server.Close()
+
+	transport, err := newCachingTransport(http.DefaultTransport, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAPI(Options{BaseURL: server.URL}, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first pass fails to store page 2, like a db write failing part way through a repo
+	storeErr := errors.New("store failed")
+	var stored []string
+	list := func(failPage2 bool) error {
+		_, err := a.listRepositoryCommitsByPage(context.Background(), "o", "r", "", time.Time{}, time.Time{}, func(page []*github.RepositoryCommit, last bool) error {
+			if last && failPage2 {
+				return storeErr
+			}
+			for _, c := range page {
+				stored = append(stored, c.GetSHA())
+			}
+			return nil
+		})
+		return err
+	}
+	if err := list(true); !errors.Is(err, storeErr) {
+		t.Fatalf("Result fail. Received %v, Expected %v", err, storeErr)
+	}
+
+	// The next pass must fetch page 2 again instead of stopping at an unchanged page 1
+	if err := list(false); err != nil {
+		t.Fatalf("Result fail. Received %v, Expected page 2 to be fetched again", err)
+	}
+	if page2Requests != 2 {
+		t.Errorf("Result fail. Received %d requests for page 2, Expected 2", page2Requests)
+	}
+	if expected := []string{"a", "a", "b"}; !slices.Equal(stored, expected) {
+		t.Errorf("Result fail. Received %v, Expected %v", stored, expected)
+	}
+
+	// Once a pass has stored every page, an unchanged page 1 means there's nothing new
+	if err := list(false); !errors.Is(err, ErrNotModified) {
+		t.Errorf("Result fail. Received %v, Expected %v", err, ErrNotModified)
+	}
+}
@@ -3,114 +3,198 @@ package github
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v52/github"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/oauth2"
+
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// The GitHub APIs that this package can collect data with
+const (
+	APIREST    = "rest"
+	APIGraphQL = "graphql"
 )
 
+// Options configures the GitHub API client constructed by Init
+type Options struct {
+	// GitHub API tokens. Requests rotate between them by remaining quota, and exhausted tokens sit out until their rate limit resets.
+	// When a GitHub App is configured these are only used for owners the app isn't installed on
+	Tokens []string
+
+	API     string // The GitHub API to collect data with, one of "rest" or "graphql" (default: rest)
+	BaseURL string // Optional REST API base URL, for a local stand-in. The GraphQL endpoint is "graphql" under it
+
+	// Optional GitHub App to authenticate as. Installation tokens are minted and refreshed automatically,
+	// and each request uses the installation on the owner of the repo or org it's for
+	AppID             int64
+	AppPrivateKeyFile string
+
+	// Optional directory to cache ETags, Last-Modified dates, and response bodies in so REST requests can be made conditional.
+	// Caching is disabled when empty. GraphQL requests can't be made conditional and are never cached.
+	CacheDir string
+}
+
+// api is implemented by each GitHub API this package supports. Every implementation produces the go-github types consumed by the rest of the application
+type api interface {
+	getRepository(ctx context.Context, owner string, repo string) (*github.Repository, int, error)
+	getRepositories(ctx context.Context, fullNames []string) (map[string]*github.Repository, error)
+	listRepositoryCommitsByPage(ctx context.Context, owner string, repo string, branch string, start time.Time, end time.Time, fn func(page []*github.RepositoryCommit, last bool) error) (int, error)
+	listRepositoriesByOrg(ctx context.Context, org string, visibility string) ([]*github.Repository, error)
+	getUser(ctx context.Context, login string) (*github.User, []string, int, error)
+	searchRepositories(ctx context.Context, query string, limit int) ([]*github.Repository, error)
+	searchCode(ctx context.Context, query string, limit int) ([]string, error)
+}
+
 var (
-	client *github.Client
+	client api
 )
 
-// Init constructs a github API client for this package taking in a token
-func Init(token string) {
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client = github.NewClient(tc)
+// Init constructs a github API client for this package from a set of options
+func Init(opts Options) error {
+	baseURL, err := apiBaseURL(opts)
+	if err != nil {
+		return err
+	}
+
+	// Every request to GitHub, including retries with another token and minting app installation tokens, gets its own span
+	var base http.RoundTripper = newTracingTransport(http.DefaultTransport)
+
+	// Authenticate as a GitHub App when one is configured, keeping the token pool (if any) as the fallback for owners without an installation
+	transport := base
+	var tokenTransport http.RoundTripper
+	if len(opts.Tokens) > 0 {
+		p := newTokenPool(base, opts.Tokens)
+		poolMu.Lock()
+		pool = p
+		poolMu.Unlock()
+		tokenTransport = p
+		transport = p
+	}
+	if opts.AppID != 0 {
+		key, err := os.ReadFile(opts.AppPrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("reading GitHub App private key: %v", err)
+		}
+		transport, err = newAppTransport(opts.AppID, key, baseURL, base, tokenTransport)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.CacheDir != "" {
+		transport, err = newCachingTransport(transport, opts.CacheDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	c, err := newAPI(opts, &http.Client{Transport: transport})
+	if err != nil {
+		return err
+	}
+	client = c
+	return nil
 }
 
-// GetRepository gets a repository by owner and repo name
-func GetRepository(owner string, repo string) (*github.Repository, int, error) {
-	log.Debugf("Querying GetRepository for %s/%s", owner, repo)
-	// Get page of repo commits
-	r, resp, err := client.Repositories.Get(context.Background(), owner, repo)
-	var statusCode int
-	if resp != nil {
-		statusCode = resp.StatusCode
+// apiBaseURL returns the REST API base URL to use from opts, with the trailing slash go-github requires
+func apiBaseURL(opts Options) (*url.URL, error) {
+	if opts.BaseURL == "" {
+		return github.NewClient(nil).BaseURL, nil
 	}
+	baseURL, err := url.Parse(strings.TrimSuffix(opts.BaseURL, "/") + "/")
 	if err != nil {
-		return nil, statusCode, fmt.Errorf("GetRepository for %s/%s returned error: \n%v", owner, repo, err)
+		return nil, fmt.Errorf("parsing GitHub base URL %s: %v", opts.BaseURL, err)
 	}
+	return baseURL, nil
+}
 
-	log.Debugf("Queried GetRepository for %s/%s", owner, repo)
-	return r, statusCode, nil
+// newAPI constructs the api implementation selected in opts on top of an authenticated http client
+func newAPI(opts Options, hc *http.Client) (api, error) {
+	baseURL, err := apiBaseURL(opts)
+	if err != nil {
+		return nil, err
+	}
+	restClient := github.NewClient(hc)
+	restClient.BaseURL = baseURL
+	graphQLURL := baseURL.JoinPath("graphql").String()
+
+	switch opts.API {
+	case "", APIREST:
+		return &restAPI{client: restClient}, nil
+	case APIGraphQL:
+		return &graphQLAPI{httpClient: hc, endpoint: graphQLURL, rest: &restAPI{client: restClient}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported GitHub API \"%s\", expected one of %s or %s", opts.API, APIREST, APIGraphQL)
+	}
 }
 
-// ListRepositoryCommits gets all commits for a repository for a specified duration
-func ListRepositoryCommits(owner string, repo string, start time.Time, end time.Time) ([]*github.RepositoryCommit, int, error) {
-	var commits []*github.RepositoryCommit
-	var statusCode int
-	var page, perPage int = 1, 100
-	for {
-		data := github.CommitsListOptions{
-			Since: start,
-			Until: end,
-			ListOptions: github.ListOptions{
-				Page:    page,
-				PerPage: perPage,
-			},
-		}
+// GetRepository gets a repository by owner and repo name
+func GetRepository(ctx context.Context, owner string, repo string) (*github.Repository, int, error) {
+	return client.getRepository(ctx, owner, repo)
+}
 
-		log.Debugf("Querying ListRepositoryCommits for %s/%s, page %d", owner, repo, page)
-		// Get page of repo commits
-		r, resp, err := client.Repositories.ListCommits(context.Background(), owner, repo, &data)
-		statusCode = resp.StatusCode
-		if err != nil {
-			return nil, statusCode, fmt.Errorf("ListRepositoriesByOrg returned error: \n%v", err)
-		}
+// GetUser gets a user's public profile, along with the logins of the organizations they're a public member of
+func GetUser(ctx context.Context, login string) (*github.User, []string, int, error) {
+	return client.getUser(ctx, login)
+}
 
-		// Add page to commits slice
-		commits = append(commits, r...)
+// GetRepositories gets a batch of repositories by their "owner/repo" full names, returning a map keyed by the requested full name.
+// Repositories that could not be found are absent from the map.
+func GetRepositories(ctx context.Context, fullNames []string) (map[string]*github.Repository, error) {
+	return client.getRepositories(ctx, fullNames)
+}
 
-		// Break if out of pages, or flip page
-		if resp.NextPage == 0 {
-			break
-		}
-		page++
+// ListRepositoryCommits gets all commits for a repository for a specified duration
+func ListRepositoryCommits(ctx context.Context, owner string, repo string, start time.Time, end time.Time) ([]*github.RepositoryCommit, int, error) {
+	var commits []*github.RepositoryCommit
+	statusCode, err := ListRepositoryCommitsByPage(ctx, owner, repo, start, end, func(page []*github.RepositoryCommit, last bool) error {
+		commits = append(commits, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, statusCode, err
 	}
 
-	log.Debugf("Queried ListRepositoryCommits for %s/%s, with %d results", owner, repo, len(commits))
 	return commits, statusCode, nil
 }
 
-// ListRepositoriesByOrg gets all repositories in a GitHub organization with a visibility filter setting
-func ListRepositoriesByOrg(org string, visibility string) ([]*github.Repository, error) {
-	// Chech cache
-	var repos []*github.Repository
-	var page, perPage int = 1, 100
-	for {
-		data := github.RepositoryListByOrgOptions{
-			ListOptions: github.ListOptions{
-				Page:    page,
-				PerPage: perPage,
-			},
-		}
-		if visibility != "" {
-			data.Type = visibility
-		}
+// ListRepositoryCommitsByPage gets all commits for a repository for a specified duration, handing each page of results to fn as it arrives.
+// fn is called at least once, and last is true on the final page. An error returned from fn stops pagination and is returned to the caller.
+// When caching is enabled and the first page is unchanged since the last listing fn handled through to the final page without error, fn is not called
+// and ErrNotModified is returned. Listings that stopped part way are requested in full again, so every page after the failed one is fetched.
+func ListRepositoryCommitsByPage(ctx context.Context, owner string, repo string, start time.Time, end time.Time, fn func(page []*github.RepositoryCommit, last bool) error) (int, error) {
+	return client.listRepositoryCommitsByPage(ctx, owner, repo, "", start, end, fn)
+}
 
-		log.Debugf("Querying ListRepositoriesByOrg for %s of type (%s), page %d", org, visibility, page)
-		// Get page of organization repos
-		r, resp, err := client.Repositories.ListByOrg(context.Background(), org, &data)
-		if err != nil {
-			return nil, fmt.Errorf("ListRepositoriesByOrg returned error: \n%v", err)
-		}
+// ListBranchCommitsByPage is ListRepositoryCommitsByPage for a branch other than the default branch, which is used when branch is empty.
+// A branch that doesn't exist is an error, rather than a 404, so it isn't mistaken for a repo that doesn't exist
+func ListBranchCommitsByPage(ctx context.Context, owner string, repo string, branch string, start time.Time, end time.Time, fn func(page []*github.RepositoryCommit, last bool) error) (int, error) {
+	return client.listRepositoryCommitsByPage(ctx, owner, repo, branch, start, end, fn)
+}
 
-		// Add page to repos slice
-		repos = append(repos, r...)
+// ListRepositoriesByOrg gets all repositories in a GitHub organization with a visibility filter setting
+func ListRepositoriesByOrg(ctx context.Context, org string, visibility string) ([]*github.Repository, error) {
+	return client.listRepositoriesByOrg(ctx, org, visibility)
+}
 
-		// Break if out of pages, or flip page
-		if resp.NextPage == 0 {
-			break
-		}
-		page++
-	}
+// SearchRepositories searches for repositories, returning up to limit of the most recently updated matches. GitHub returns at most 1000 results for a search
+func SearchRepositories(ctx context.Context, query string, limit int) ([]*github.Repository, error) {
+	return client.searchRepositories(ctx, query, limit)
+}
+
+// SearchCode searches code, returning the "owner/repo" full names of up to limit distinct repositories with matching files.
+// GitHub returns at most 1000 results for a search
+func SearchCode(ctx context.Context, query string, limit int) ([]string, error) {
+	return client.searchCode(ctx, query, limit)
+}
 
-	log.Debugf("Queried ListRepositoriesByOrg for %s of type %s, with %d results", org, visibility, len(repos))
-	return repos, nil
+// repoLogger returns the logger for ctx with the owner and repo fields of a repo
+func repoLogger(ctx context.Context, owner string, repo string) *log.Entry {
+	return logging.FromContext(ctx).WithFields(log.Fields{logging.FieldOwner: owner, logging.FieldRepo: repo})
 }
@@ -0,0 +1,677 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// graphQLBatchSize is the number of repositories requested in one query by getRepositories
+const graphQLBatchSize = 25
+
+// repositoryFragment holds the repository fields queried by every GraphQL query in this file
+const repositoryFragment = `
+fragment RepositoryFields on Repository {
+	databaseId
+	id
+	name
+	owner { login }
+	nameWithOwner
+	url
+	description
+	isFork
+	isArchived
+	isDisabled
+	stargazerCount
+	forkCount
+	watchers { totalCount }
+	primaryLanguage { name }
+	licenseInfo { key name spdxId }
+	repositoryTopics(first: 20) { nodes { topic { name } } }
+	defaultBranchRef { name }
+	parent { databaseId nameWithOwner name owner { login } }
+	createdAt
+	pushedAt
+}`
+
+// commitHistoryFields selects a page of a ref's commit history
+const commitHistoryFields = `
+		target {
+			... on Commit {
+				history(first: 100, since: $since, until: $until, after: $cursor) {
+					pageInfo { hasNextPage endCursor }
+					nodes {
+						oid
+						url
+						additions
+						deletions
+						author { name email date user { login } }
+					}
+				}
+			}
+		}`
+
+const commitHistoryQuery = `
+query($owner: String!, $name: String!, $since: GitTimestamp, $until: GitTimestamp, $cursor: String) {
+	repository(owner: $owner, name: $name) {
+		...RepositoryFields
+		defaultBranchRef {` + commitHistoryFields + `
+		}
+	}
+}` + repositoryFragment
+
+const branchHistoryQuery = `
+query($owner: String!, $name: String!, $branch: String!, $since: GitTimestamp, $until: GitTimestamp, $cursor: String) {
+	repository(owner: $owner, name: $name) {
+		...RepositoryFields
+		branchRef: ref(qualifiedName: $branch) {` + commitHistoryFields + `
+		}
+	}
+}` + repositoryFragment
+
+const repositoryQuery = `
+query($owner: String!, $name: String!) {
+	repository(owner: $owner, name: $name) { ...RepositoryFields }
+}` + repositoryFragment
+
+const organizationRepositoriesQuery = `
+query($org: String!, $privacy: RepositoryPrivacy, $isFork: Boolean, $cursor: String) {
+	organization(login: $org) {
+		repositories(first: 100, after: $cursor, privacy: $privacy, isFork: $isFork) {
+			pageInfo { hasNextPage endCursor }
+			nodes { ...RepositoryFields }
+		}
+	}
+}` + repositoryFragment
+
+const userQuery = `
+query($login: String!) {
+	user(login: $login) {
+		databaseId
+		login
+		name
+		company
+		location
+		createdAt
+		organizations(first: 100) { nodes { login } }
+	}
+}`
+
+const searchRepositoriesQuery = `
+query($query: String!, $first: Int!, $cursor: String) {
+	search(query: $query, type: REPOSITORY, first: $first, after: $cursor) {
+		pageInfo { hasNextPage endCursor }
+		nodes { ... on Repository { ...RepositoryFields } }
+	}
+}` + repositoryFragment
+
+// graphQLAPI collects data through the GitHub GraphQL API (v4)
+type graphQLAPI struct {
+	httpClient *http.Client
+	endpoint   string
+	rest       *restAPI // For code search, which the GraphQL API doesn't have
+}
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+type graphQLError struct {
+	Type    string `json:"type"`
+	Path    []any  `json:"path"`
+	Message string `json:"message"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// graphQLRef is a branch, along with a page of its commit history when it was queried
+type graphQLRef struct {
+	Name   string `json:"name"`
+	Target *struct {
+		History *struct {
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+			Nodes    []graphQLCommit `json:"nodes"`
+		} `json:"history"`
+	} `json:"target"`
+}
+
+// graphQLRepository mirrors repositoryFragment
+type graphQLRepository struct {
+	DatabaseID    int64                  `json:"databaseId"`
+	ID            string                 `json:"id"`
+	Name          string                 `json:"name"`
+	Owner         struct{ Login string } `json:"owner"`
+	NameWithOwner string                 `json:"nameWithOwner"`
+	URL           string                 `json:"url"`
+	Description   string                 `json:"description"`
+	IsFork        bool                   `json:"isFork"`
+	IsArchived    bool                   `json:"isArchived"`
+	IsDisabled    bool                   `json:"isDisabled"`
+	Stargazers    int                    `json:"stargazerCount"`
+	Forks         int                    `json:"forkCount"`
+	Watchers      struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"watchers"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	LicenseInfo *struct {
+		Key    string `json:"key"`
+		Name   string `json:"name"`
+		SPDXID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	DefaultBranchRef *graphQLRef `json:"defaultBranchRef"`
+	BranchRef        *graphQLRef `json:"branchRef"` // Only queried by branchHistoryQuery
+	Parent           *struct {
+		DatabaseID    int64                  `json:"databaseId"`
+		NameWithOwner string                 `json:"nameWithOwner"`
+		Name          string                 `json:"name"`
+		Owner         struct{ Login string } `json:"owner"`
+	} `json:"parent"`
+	CreatedAt *time.Time `json:"createdAt"`
+	PushedAt  *time.Time `json:"pushedAt"`
+}
+
+type graphQLCommit struct {
+	OID       string `json:"oid"`
+	URL       string `json:"url"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Author    *struct {
+		Name  string    `json:"name"`
+		Email string    `json:"email"`
+		Date  time.Time `json:"date"`
+		User  *struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"author"`
+}
+
+// toGitHub converts a GraphQL repository to the go-github type the rest of the application consumes
+func (r *graphQLRepository) toGitHub() *github.Repository {
+	repo := &github.Repository{
+		ID:              github.Int64(r.DatabaseID),
+		NodeID:          github.String(r.ID),
+		Name:            github.String(r.Name),
+		FullName:        github.String(r.NameWithOwner),
+		Owner:           &github.User{Login: github.String(r.Owner.Login)},
+		HTMLURL:         github.String(r.URL),
+		Description:     github.String(r.Description),
+		Fork:            github.Bool(r.IsFork),
+		Archived:        github.Bool(r.IsArchived),
+		Disabled:        github.Bool(r.IsDisabled),
+		StargazersCount: github.Int(r.Stargazers),
+		ForksCount:      github.Int(r.Forks),
+		// The REST API's watchers count is a legacy alias of stargazers, and people watching the repo are its subscribers
+		WatchersCount:    github.Int(r.Stargazers),
+		SubscribersCount: github.Int(r.Watchers.TotalCount),
+		Topics:           []string{},
+	}
+	if r.PrimaryLanguage != nil {
+		repo.Language = github.String(r.PrimaryLanguage.Name)
+	}
+	if r.LicenseInfo != nil {
+		repo.License = &github.License{
+			Key:    github.String(r.LicenseInfo.Key),
+			Name:   github.String(r.LicenseInfo.Name),
+			SPDXID: github.String(r.LicenseInfo.SPDXID),
+		}
+	}
+	for _, t := range r.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, t.Topic.Name)
+	}
+	if r.DefaultBranchRef != nil {
+		repo.DefaultBranch = github.String(r.DefaultBranchRef.Name)
+	}
+	if r.Parent != nil {
+		repo.Parent = &github.Repository{
+			ID:       github.Int64(r.Parent.DatabaseID),
+			Name:     github.String(r.Parent.Name),
+			FullName: github.String(r.Parent.NameWithOwner),
+			Owner:    &github.User{Login: github.String(r.Parent.Owner.Login)},
+		}
+	}
+	if r.CreatedAt != nil {
+		repo.CreatedAt = &github.Timestamp{Time: *r.CreatedAt}
+	}
+	if r.PushedAt != nil {
+		repo.PushedAt = &github.Timestamp{Time: *r.PushedAt}
+	}
+	return repo
+}
+
+// graphQLUser mirrors the user fields in userQuery
+type graphQLUser struct {
+	DatabaseID    int64      `json:"databaseId"`
+	Login         string     `json:"login"`
+	Name          *string    `json:"name"`
+	Company       *string    `json:"company"`
+	Location      *string    `json:"location"`
+	CreatedAt     *time.Time `json:"createdAt"`
+	Organizations struct {
+		Nodes []struct {
+			Login string `json:"login"`
+		} `json:"nodes"`
+	} `json:"organizations"`
+}
+
+// toGitHub converts a GraphQL user to the go-github type the rest of the application consumes, along with their organization logins
+func (u *graphQLUser) toGitHub() (*github.User, []string) {
+	user := &github.User{
+		ID:       github.Int64(u.DatabaseID),
+		Login:    github.String(u.Login),
+		Name:     u.Name,
+		Company:  u.Company,
+		Location: u.Location,
+		// Only users can be looked up with the user field, organizations and bots are not found
+		Type: github.String("User"),
+	}
+	if u.CreatedAt != nil {
+		user.CreatedAt = &github.Timestamp{Time: *u.CreatedAt}
+	}
+	var orgs []string
+	for _, o := range u.Organizations.Nodes {
+		orgs = append(orgs, o.Login)
+	}
+	return user, orgs
+}
+
+// toGitHub converts a GraphQL commit to the go-github type the rest of the application consumes
+func (c *graphQLCommit) toGitHub() *github.RepositoryCommit {
+	commit := &github.RepositoryCommit{
+		SHA:     github.String(c.OID),
+		HTMLURL: github.String(c.URL),
+		Commit:  &github.Commit{SHA: github.String(c.OID)},
+		Stats: &github.CommitStats{
+			Additions: github.Int(c.Additions),
+			Deletions: github.Int(c.Deletions),
+			Total:     github.Int(c.Additions + c.Deletions),
+		},
+	}
+	if c.Author != nil {
+		commit.Commit.Author = &github.CommitAuthor{
+			Name:  github.String(c.Author.Name),
+			Email: github.String(c.Author.Email),
+			Date:  &github.Timestamp{Time: c.Author.Date},
+		}
+		if c.Author.User != nil {
+			commit.Author = &github.User{Login: github.String(c.Author.User.Login)}
+		}
+	}
+	return commit
+}
+
+// query posts a GraphQL query and decodes the data in the response into out
+// Returns the HTTP status code, any errors listed in the response body, and an error if the request itself failed
+func (a *graphQLAPI) query(ctx context.Context, q string, vars map[string]any, out any) (int, []graphQLError, error) {
+	body, err := json.Marshal(graphQLRequest{Query: q, Variables: vars})
+	if err != nil {
+		return 0, nil, fmt.Errorf("encoding GraphQL request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating GraphQL request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The owner isn't part of the URL for GraphQL, so pass it along for GitHub App installation routing. tracingTransport drops it before the request is sent
+	if owner, ok := vars["owner"].(string); ok {
+		req.Header.Set(headerRouteOwner, owner)
+	} else if org, ok := vars["org"].(string); ok {
+		req.Header.Set(headerRouteOwner, org)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("sending GraphQL request: %v", err)
+	}
+	defer func(b io.ReadCloser) {
+		err := b.Close()
+		if err != nil {
+			log.Errorf("error closing GraphQL response body: %v", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, nil, fmt.Errorf("GraphQL request returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var gr graphQLResponse
+	err = json.NewDecoder(resp.Body).Decode(&gr)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("decoding GraphQL response: %v", err)
+	}
+	if len(gr.Data) > 0 && string(gr.Data) != "null" {
+		err = json.Unmarshal(gr.Data, out)
+		if err != nil {
+			return resp.StatusCode, gr.Errors, fmt.Errorf("decoding GraphQL response data: %v", err)
+		}
+	}
+
+	return resp.StatusCode, gr.Errors, nil
+}
+
+// checkGraphQLErrors turns the errors listed in a GraphQL response into a status code and error
+// NOT_FOUND errors are reported as a 404 to match the REST API
+func checkGraphQLErrors(statusCode int, errs []graphQLError) (int, error) {
+	if len(errs) == 0 {
+		return statusCode, nil
+	}
+	var msgs []string
+	for _, e := range errs {
+		if e.Type == "NOT_FOUND" {
+			return http.StatusNotFound, fmt.Errorf("GraphQL query returned not found: %s", e.Message)
+		}
+		msgs = append(msgs, e.Message)
+	}
+	return statusCode, fmt.Errorf("GraphQL query returned errors: %s", strings.Join(msgs, "; "))
+}
+
+func (a *graphQLAPI) getRepository(ctx context.Context, owner string, repo string) (*github.Repository, int, error) {
+	logger := repoLogger(ctx, owner, repo)
+	start := time.Now()
+	logger.Debug("Querying GetRepository with GraphQL")
+	var data struct {
+		Repository *graphQLRepository `json:"repository"`
+	}
+	statusCode, errs, err := a.query(ctx, repositoryQuery, map[string]any{"owner": owner, "name": repo}, &data)
+	if err != nil {
+		return nil, statusCode, fmt.Errorf("GetRepository for %s/%s returned error: \n%v", owner, repo, err)
+	}
+	statusCode, err = checkGraphQLErrors(statusCode, errs)
+	if err != nil {
+		return nil, statusCode, fmt.Errorf("GetRepository for %s/%s returned error: \n%v", owner, repo, err)
+	}
+	if data.Repository == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("GetRepository for %s/%s returned no repository", owner, repo)
+	}
+
+	logger.WithFields(logging.Since(start)).Debug("Queried GetRepository with GraphQL")
+	return data.Repository.toGitHub(), statusCode, nil
+}
+
+// getRepositories requests up to graphQLBatchSize repositories per query using aliased repository fields
+func (a *graphQLAPI) getRepositories(ctx context.Context, fullNames []string) (map[string]*github.Repository, error) {
+	repos := make(map[string]*github.Repository)
+	for start := 0; start < len(fullNames); start += graphQLBatchSize {
+		batch := fullNames[start:min(start+graphQLBatchSize, len(fullNames))]
+
+		var (
+			params []string
+			fields []string
+			vars   = make(map[string]any)
+		)
+		for i, fullName := range batch {
+			owner, repo, ok := strings.Cut(fullName, "/")
+			if !ok {
+				return repos, fmt.Errorf("expected repository name in owner/repo format, got \"%s\"", fullName)
+			}
+			params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!", i, i))
+			fields = append(fields, fmt.Sprintf("r%d: repository(owner: $o%d, name: $n%d) { ...RepositoryFields }", i, i, i))
+			vars[fmt.Sprintf("o%d", i)] = owner
+			vars[fmt.Sprintf("n%d", i)] = repo
+		}
+		q := fmt.Sprintf("query(%s) {\n\t%s\n}", strings.Join(params, ", "), strings.Join(fields, "\n\t")) + repositoryFragment
+
+		logging.FromContext(ctx).Debugf("Querying GetRepositories for %d repositories with GraphQL", len(batch))
+		var data map[string]*graphQLRepository
+		statusCode, errs, err := a.query(ctx, q, vars, &data)
+		if err != nil {
+			return repos, fmt.Errorf("GetRepositories returned error: \n%v", err)
+		}
+		// Missing repositories come back as NOT_FOUND errors alongside the ones that were found, so only other errors fail the batch
+		for _, e := range errs {
+			if e.Type != "NOT_FOUND" {
+				_, err = checkGraphQLErrors(statusCode, errs)
+				return repos, fmt.Errorf("GetRepositories returned error: \n%v", err)
+			}
+		}
+
+		for i, fullName := range batch {
+			if r := data[fmt.Sprintf("r%d", i)]; r != nil {
+				repos[fullName] = r.toGitHub()
+			}
+		}
+	}
+
+	logging.FromContext(ctx).Debugf("Queried GetRepositories for %d repositories with GraphQL, found %d", len(fullNames), len(repos))
+	return repos, nil
+}
+
+func (a *graphQLAPI) listRepositoryCommitsByPage(ctx context.Context, owner string, repo string, branch string, start time.Time, end time.Time, fn func(page []*github.RepositoryCommit, last bool) error) (int, error) {
+	logger := repoLogger(ctx, owner, repo)
+	query := commitHistoryQuery
+	if branch != "" {
+		query = branchHistoryQuery
+	}
+	var total, statusCode, page int
+	var cursor string
+	for {
+		page++
+		vars := map[string]any{
+			"owner": owner,
+			"name":  repo,
+		}
+		// Zero times are left out like the REST API leaves them out, rather than sent as the year 1
+		if !start.IsZero() {
+			vars["since"] = start.UTC().Format(time.RFC3339)
+		}
+		if !end.IsZero() {
+			vars["until"] = end.UTC().Format(time.RFC3339)
+		}
+		if branch != "" {
+			vars["branch"] = "refs/heads/" + branch
+		}
+		if cursor != "" {
+			vars["cursor"] = cursor
+		}
+
+		pageLogger := logger.WithField(logging.FieldPage, page)
+		pageLogger.Debug("Querying ListRepositoryCommits with GraphQL")
+		requested := time.Now()
+		var data struct {
+			Repository *graphQLRepository `json:"repository"`
+		}
+		var errs []graphQLError
+		var err error
+		statusCode, errs, err = a.query(withPage(ctx, page), query, vars, &data)
+		if err != nil {
+			return statusCode, fmt.Errorf("ListRepositoryCommits returned error: \n%v", err)
+		}
+		statusCode, err = checkGraphQLErrors(statusCode, errs)
+		if err != nil {
+			return statusCode, fmt.Errorf("ListRepositoryCommits returned error: \n%v", err)
+		}
+		if data.Repository == nil {
+			return http.StatusNotFound, fmt.Errorf("ListRepositoryCommits for %s/%s returned no repository", owner, repo)
+		}
+
+		// A repository without a default branch (ie. an empty repo) has no history to page through
+		ref := data.Repository.DefaultBranchRef
+		if branch != "" {
+			ref = data.Repository.BranchRef
+			if ref == nil {
+				return statusCode, fmt.Errorf("ListRepositoryCommits found no branch %s in %s/%s", branch, owner, repo)
+			}
+		}
+		var (
+			r        []*github.RepositoryCommit
+			pageInfo graphQLPageInfo
+		)
+		if ref != nil && ref.Target != nil && ref.Target.History != nil {
+			for _, c := range ref.Target.History.Nodes {
+				r = append(r, c.toGitHub())
+			}
+			pageInfo = ref.Target.History.PageInfo
+		}
+		total += len(r)
+		pageLogger.WithFields(logging.Since(requested)).Debugf("Queried ListRepositoryCommits with GraphQL, with %d results", len(r))
+
+		// Hand page to the caller, noting whether this is the last one
+		last := !pageInfo.HasNextPage
+		err = fn(r, last)
+		if err != nil {
+			return statusCode, err
+		}
+
+		// Break if out of pages, or move the cursor along
+		if last {
+			break
+		}
+		cursor = pageInfo.EndCursor
+	}
+
+	logger.Debugf("Queried ListRepositoryCommits with GraphQL for every page, with %d results", total)
+	return statusCode, nil
+}
+
+func (a *graphQLAPI) listRepositoriesByOrg(ctx context.Context, org string, visibility string) ([]*github.Repository, error) {
+	// Map the REST API's repository type filter on to the GraphQL privacy and fork filters
+	vars := map[string]any{"org": org}
+	switch visibility {
+	case "", "all":
+	case "public":
+		vars["privacy"] = "PUBLIC"
+	case "private":
+		vars["privacy"] = "PRIVATE"
+	case "forks":
+		vars["isFork"] = true
+	case "sources":
+		vars["isFork"] = false
+	default:
+		return nil, fmt.Errorf("visibility \"%s\" is not supported by the GraphQL API", visibility)
+	}
+
+	var repos []*github.Repository
+	var page int
+	for {
+		page++
+		logging.FromContext(ctx).WithField(logging.FieldPage, page).Debugf("Querying ListRepositoriesByOrg for %s of type (%s) with GraphQL", org, visibility)
+		var data struct {
+			Organization *struct {
+				Repositories struct {
+					PageInfo graphQLPageInfo     `json:"pageInfo"`
+					Nodes    []graphQLRepository `json:"nodes"`
+				} `json:"repositories"`
+			} `json:"organization"`
+		}
+		statusCode, errs, err := a.query(withPage(ctx, page), organizationRepositoriesQuery, vars, &data)
+		if err != nil {
+			return nil, fmt.Errorf("ListRepositoriesByOrg returned error: \n%v", err)
+		}
+		_, err = checkGraphQLErrors(statusCode, errs)
+		if err != nil {
+			return nil, fmt.Errorf("ListRepositoriesByOrg returned error: \n%v", err)
+		}
+		if data.Organization == nil {
+			return nil, fmt.Errorf("ListRepositoriesByOrg returned no organization for %s", org)
+		}
+
+		// Add page to repos slice
+		for i := range data.Organization.Repositories.Nodes {
+			repos = append(repos, data.Organization.Repositories.Nodes[i].toGitHub())
+		}
+
+		// Break if out of pages, or move the cursor along
+		pageInfo := data.Organization.Repositories.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		vars["cursor"] = pageInfo.EndCursor
+	}
+
+	logging.FromContext(ctx).Debugf("Queried ListRepositoriesByOrg for %s of type %s with GraphQL, with %d results", org, visibility, len(repos))
+	return repos, nil
+}
+
+func (a *graphQLAPI) getUser(ctx context.Context, login string) (*github.User, []string, int, error) {
+	logging.FromContext(ctx).Debugf("Querying GetUser for %s with GraphQL", login)
+	var data struct {
+		User *graphQLUser `json:"user"`
+	}
+	statusCode, errs, err := a.query(ctx, userQuery, map[string]any{"login": login}, &data)
+	if err != nil {
+		return nil, nil, statusCode, fmt.Errorf("GetUser for %s returned error: \n%v", login, err)
+	}
+	statusCode, err = checkGraphQLErrors(statusCode, errs)
+	if err != nil {
+		return nil, nil, statusCode, fmt.Errorf("GetUser for %s returned error: \n%v", login, err)
+	}
+	if data.User == nil {
+		return nil, nil, http.StatusNotFound, fmt.Errorf("GetUser for %s returned no user", login)
+	}
+
+	user, orgs := data.User.toGitHub()
+	logging.FromContext(ctx).Debugf("Queried GetUser for %s with GraphQL, with %d organizations", login, len(orgs))
+	return user, orgs, statusCode, nil
+}
+
+func (a *graphQLAPI) searchRepositories(ctx context.Context, query string, limit int) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	vars := map[string]any{"query": query}
+	var page int
+	for len(repos) < limit {
+		page++
+		vars["first"] = min(100, limit-len(repos))
+		logging.FromContext(ctx).WithField(logging.FieldPage, page).Debugf("Querying SearchRepositories for \"%s\" with GraphQL", query)
+		var data struct {
+			Search struct {
+				PageInfo graphQLPageInfo     `json:"pageInfo"`
+				Nodes    []graphQLRepository `json:"nodes"`
+			} `json:"search"`
+		}
+		statusCode, errs, err := a.query(withPage(ctx, page), searchRepositoriesQuery, vars, &data)
+		if err != nil {
+			return nil, fmt.Errorf("SearchRepositories for \"%s\" returned error: \n%v", query, err)
+		}
+		_, err = checkGraphQLErrors(statusCode, errs)
+		if err != nil {
+			return nil, fmt.Errorf("SearchRepositories for \"%s\" returned error: \n%v", query, err)
+		}
+
+		for i := range data.Search.Nodes {
+			repos = append(repos, data.Search.Nodes[i].toGitHub())
+		}
+
+		// Break if out of pages, or move the cursor along
+		if !data.Search.PageInfo.HasNextPage {
+			break
+		}
+		vars["cursor"] = data.Search.PageInfo.EndCursor
+	}
+
+	logging.FromContext(ctx).Debugf("Queried SearchRepositories for \"%s\" with GraphQL, with %d results", query, len(repos))
+	return repos, nil
+}
+
+func (a *graphQLAPI) searchCode(ctx context.Context, query string, limit int) ([]string, error) {
+	return a.rest.searchCode(ctx, query, limit)
+}
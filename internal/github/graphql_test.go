@@ -0,0 +1,325 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+// newGraphQLStandIn starts a stand-in for the GitHub GraphQL API that answers each request with a recorded response from testdata/graphql
+// pick chooses the recording to send back for a decoded request
+func newGraphQLStandIn(t *testing.T, pick func(req graphQLRequest) string) *graphQLAPI {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req graphQLRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Errorf("decoding request body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		recording, err := os.ReadFile(filepath.Join("testdata", "graphql", pick(req)))
+		if err != nil {
+			t.Errorf("reading recorded response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(recording)
+	}))
+	t.Cleanup(server.Close)
+
+	a, err := newAPI(Options{API: APIGraphQL, BaseURL: server.URL}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.(*graphQLAPI)
+}
+
+func TestGraphQLListRepositoryCommitsByPage(t *testing.T) {
+	start := time.Date(2017, time.August, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2023, time.May, 3, 0, 0, 0, 0, time.UTC)
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		if req.Variables["owner"] != "Chia-Network" || req.Variables["name"] != "go-chia-libs" {
+			t.Errorf("unexpected repository variables %v", req.Variables)
+		}
+		if req.Variables["since"] != "2017-08-01T00:00:00Z" || req.Variables["until"] != "2023-05-03T00:00:00Z" {
+			t.Errorf("unexpected time range variables %v", req.Variables)
+		}
+		if req.Variables["cursor"] == "cursor-page-2" {
+			return "commits_page2.json"
+		}
+		return "commits_page1.json"
+	})
+
+	var (
+		pages []int
+		lasts []bool
+		cmts  []*github.RepositoryCommit
+	)
+	statusCode, err := a.listRepositoryCommitsByPage(context.Background(), "Chia-Network", "go-chia-libs", "", start, end, func(page []*github.RepositoryCommit, last bool) error {
+		pages = append(pages, len(page))
+		lasts = append(lasts, last)
+		cmts = append(cmts, page...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusOK {
+		t.Errorf("Result fail. Received status %d, Expected %d", statusCode, http.StatusOK)
+	}
+	if len(pages) != 2 || pages[0] != 2 || pages[1] != 1 || lasts[0] || !lasts[1] {
+		t.Fatalf("Result fail. Received pages %v with last flags %v, Expected [2 1] with [false true]", pages, lasts)
+	}
+
+	first := cmts[0]
+	if first.GetSHA() != "1111111111111111111111111111111111111111" || first.GetAuthor().GetLogin() != "alice" {
+		t.Errorf("Result fail. Received sha %s by %s", first.GetSHA(), first.GetAuthor().GetLogin())
+	}
+	if first.GetCommit().GetAuthor().GetEmail() != "alice@example.com" {
+		t.Errorf("Result fail. Received email %s", first.GetCommit().GetAuthor().GetEmail())
+	}
+	if !first.GetCommit().GetAuthor().GetDate().Time.Equal(time.Date(2023, time.May, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("Result fail. Received date %v", first.GetCommit().GetAuthor().GetDate())
+	}
+	if first.GetStats().GetAdditions() != 10 || first.GetStats().GetDeletions() != 2 || first.GetStats().GetTotal() != 12 {
+		t.Errorf("Result fail. Received stats %v", first.GetStats())
+	}
+	// A commit whose email isn't linked to a GitHub account has no author user
+	if cmts[1].Author != nil {
+		t.Errorf("Result fail. Expected no author user for unlinked commit, Received %v", cmts[1].Author)
+	}
+	if cmts[2].GetAuthor().GetLogin() != "bob" {
+		t.Errorf("Result fail. Received login %s on page 2", cmts[2].GetAuthor().GetLogin())
+	}
+}
+
+func TestGraphQLListBranchCommitsByPage(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		if !strings.Contains(req.Query, "ref(qualifiedName: $branch)") {
+			t.Errorf("Result fail. Expected the branch history query, Received %s", req.Query)
+		}
+		if req.Variables["branch"] == "refs/heads/missing" {
+			return "branch_not_found.json"
+		}
+		if req.Variables["branch"] != "refs/heads/develop" {
+			t.Errorf("unexpected branch variable %v", req.Variables["branch"])
+		}
+		// There's no start to the range, so since is left out instead of sent as 0001-01-01T00:00:00Z
+		if since, ok := req.Variables["since"]; ok {
+			t.Errorf("Result fail. Received since %v, Expected no since variable", since)
+		}
+		return "branch_commits.json"
+	})
+
+	var cmts []*github.RepositoryCommit
+	_, err := a.listRepositoryCommitsByPage(context.Background(), "Chia-Network", "go-chia-libs", "develop", time.Time{}, time.Now(), func(page []*github.RepositoryCommit, last bool) error {
+		cmts = append(cmts, page...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmts) != 1 || cmts[0].GetSHA() != "3333333333333333333333333333333333333333" {
+		t.Errorf("Result fail. Received %d commits, Expected 3333333 from the develop branch", len(cmts))
+	}
+
+	// A missing branch is an error, but not a 404 for the repo
+	statusCode, err := a.listRepositoryCommitsByPage(context.Background(), "Chia-Network", "go-chia-libs", "missing", time.Time{}, time.Now(), func(page []*github.RepositoryCommit, last bool) error {
+		t.Error("Result fail. Received a page for a missing branch")
+		return nil
+	})
+	if err == nil || statusCode == http.StatusNotFound {
+		t.Errorf("Result fail. Received status %d and error %v, Expected a non-404 error", statusCode, err)
+	}
+}
+
+func TestGraphQLListRepositoryCommitsNotFound(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		return "repository_not_found.json"
+	})
+
+	called := false
+	statusCode, err := a.listRepositoryCommitsByPage(context.Background(), "Chia-Network", "does-not-exist", "", time.Time{}, time.Now(), func(page []*github.RepositoryCommit, last bool) error {
+		called = true
+		return nil
+	})
+	if err == nil {
+		t.Error("Result fail. Expected an error for a missing repository")
+	}
+	if statusCode != http.StatusNotFound {
+		t.Errorf("Result fail. Received status %d, Expected %d", statusCode, http.StatusNotFound)
+	}
+	if called {
+		t.Error("Result fail. Page callback was called for a missing repository")
+	}
+}
+
+func TestGraphQLGetRepositories(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		if !strings.Contains(req.Query, "r2: repository(owner: $o2, name: $n2)") {
+			t.Errorf("expected 3 aliased repositories in one query, Received %s", req.Query)
+		}
+		if req.Variables["o1"] != "Chia-Network" || req.Variables["n1"] != "does-not-exist" {
+			t.Errorf("unexpected variables %v", req.Variables)
+		}
+		return "repositories_batch.json"
+	})
+
+	repos, err := a.getRepositories(context.Background(), []string{"Chia-Network/chia-blockchain", "Chia-Network/does-not-exist", "Flax-Network/flax-blockchain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("Result fail. Received %d repositories, Expected 2", len(repos))
+	}
+	if _, ok := repos["Chia-Network/does-not-exist"]; ok {
+		t.Error("Result fail. Missing repository was present in results")
+	}
+
+	chia := repos["Chia-Network/chia-blockchain"]
+	if chia.GetID() != 654321 || chia.GetStargazersCount() != 10800 || chia.GetLanguage() != "Python" || chia.GetLicense().GetSPDXID() != "Apache-2.0" {
+		t.Errorf("Result fail. Received %v", chia)
+	}
+	if len(chia.Topics) != 2 || chia.Topics[1] != "chia" {
+		t.Errorf("Result fail. Received topics %v", chia.Topics)
+	}
+
+	flax := repos["Flax-Network/flax-blockchain"]
+	if !flax.GetFork() || !flax.GetArchived() || flax.GetParent().GetFullName() != "Chia-Network/chia-blockchain" || flax.GetParent().GetID() != 200 {
+		t.Errorf("Result fail. Received %v", flax)
+	}
+	if flax.Language != nil || flax.License != nil || flax.PushedAt != nil {
+		t.Errorf("Result fail. Expected null fields to stay nil, Received %v", flax)
+	}
+}
+
+func TestGraphQLListRepositoriesByOrg(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		if req.Variables["org"] != "chia-network" || req.Variables["privacy"] != "PUBLIC" {
+			t.Errorf("unexpected variables %v", req.Variables)
+		}
+		return "organization_repositories.json"
+	})
+
+	repos, err := a.listRepositoriesByOrg(context.Background(), "chia-network", "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].GetHTMLURL() != "https://github.com/Chia-Network/chia-blockchain" || repos[0].GetFork() {
+		t.Errorf("Result fail. Received %v", repos)
+	}
+
+	_, err = a.listRepositoriesByOrg(context.Background(), "chia-network", "member")
+	if err == nil {
+		t.Error("Result fail. Expected an error for an unsupported visibility")
+	}
+}
+
+func TestGraphQLRouteOwnerWithTokensOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(headerRouteOwner) != "" {
+			t.Errorf("Result fail. Received %s header %s, Expected none without a GitHub App", headerRouteOwner, r.Header.Get(headerRouteOwner))
+		}
+		if r.Header.Get("Authorization") != "Bearer pat" {
+			t.Errorf("Result fail. Received Authorization %s, Expected Bearer pat", r.Header.Get("Authorization"))
+		}
+		recording, err := os.ReadFile(filepath.Join("testdata", "graphql", "organization_repositories.json"))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(recording)
+	}))
+	defer server.Close()
+
+	// The transports Init sets up when only personal access tokens are configured
+	transport := newTokenPool(newTracingTransport(server.Client().Transport), []string{"pat"})
+	a, err := newAPI(Options{API: APIGraphQL, BaseURL: server.URL}, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = a.(*graphQLAPI).listRepositoriesByOrg(context.Background(), "chia-network", "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraphQLCancelled(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		t.Errorf("Result fail. Expected no request with a cancelled context, Received %v", req.Variables)
+		return "user.json"
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err := a.getUser(ctx, "alice")
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Result fail. Received %v, Expected %v", err, context.Canceled)
+	}
+}
+
+func TestGraphQLGetUser(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		if req.Variables["login"] != "alice" {
+			t.Errorf("unexpected variables %v", req.Variables)
+		}
+		return "user.json"
+	})
+
+	user, orgs, statusCode, err := a.getUser(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusOK {
+		t.Errorf("Result fail. Received status %d, Expected %d", statusCode, http.StatusOK)
+	}
+	if user.GetID() != 1001 || user.GetName() != "Alice Example" || user.GetCompany() != "@Chia-Network" || user.GetType() != "User" {
+		t.Errorf("Result fail. Received %v", user)
+	}
+	if user.Location != nil {
+		t.Errorf("Result fail. Expected null location to stay nil, Received %s", user.GetLocation())
+	}
+	if !user.GetCreatedAt().Time.Equal(time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Result fail. Received created at %v", user.GetCreatedAt())
+	}
+	if len(orgs) != 2 || orgs[0] != "Chia-Network" || orgs[1] != "chia-mineos" {
+		t.Errorf("Result fail. Received orgs %v", orgs)
+	}
+}
+
+func TestGraphQLSearchRepositories(t *testing.T) {
+	a := newGraphQLStandIn(t, func(req graphQLRequest) string {
+		if req.Variables["query"] != "topic:chia-blockchain" || req.Variables["first"] != float64(50) {
+			t.Errorf("unexpected variables %v", req.Variables)
+		}
+		return "search_repositories.json"
+	})
+
+	repos, err := a.searchRepositories(context.Background(), "topic:chia-blockchain", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].GetFullName() != "someone/chialisp-toolkit" || repos[0].GetStargazersCount() != 42 {
+		t.Fatalf("Result fail. Received %v", repos)
+	}
+	expect := time.Date(2026, time.October, 2, 17, 45, 0, 0, time.UTC)
+	if !repos[0].GetPushedAt().Time.Equal(expect) {
+		t.Errorf("Result fail. Received pushed at %v, Expected %v", repos[0].GetPushedAt(), expect)
+	}
+}
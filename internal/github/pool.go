@@ -0,0 +1,238 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+var (
+	tokenRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ecosystem_activity_github_token_requests_total",
+		Help: "Requests sent to the GitHub API per pooled token",
+	}, []string{"token", "resource"})
+	tokenRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ecosystem_activity_github_token_rate_limit_remaining",
+		Help: "Requests remaining in the current rate limit window per pooled token, as last reported by GitHub",
+	}, []string{"token", "resource"})
+)
+
+// tokenRate is the rate limit state of one token for one API resource (core, graphql, search)
+type tokenRate struct {
+	known     bool // false until GitHub has reported the limit for this token
+	remaining int
+	reset     time.Time
+}
+
+// pooledToken is one token in a tokenPool
+type pooledToken struct {
+	token    string
+	name     string // The token's position in the pool, ie. token-2. Safe to log and use as a metric label, as it holds no part of the token
+	rates    map[string]*tokenRate
+	requests map[string]int64
+}
+
+// TokenUsage reports the requests sent with one pooled token and its remaining quota for the core API
+type TokenUsage struct {
+	Name      string
+	Requests  int64
+	Remaining int // -1 when GitHub hasn't reported a limit for the token yet
+	Reset     time.Time
+}
+
+// tokenPool spreads requests across several tokens, always picking the token with the most remaining quota for the API resource being
+// requested. Tokens that run out are parked until their rate limit window resets, and requests wait for the earliest reset when all are parked.
+type tokenPool struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	tokens []*pooledToken
+}
+
+var (
+	poolMu sync.Mutex
+	pool   *tokenPool // The pool in use by Init, for TakeTokenUsage
+)
+
+func newTokenPool(base http.RoundTripper, tokens []string) *tokenPool {
+	p := &tokenPool{base: base}
+	for i, t := range tokens {
+		p.tokens = append(p.tokens, &pooledToken{
+			token:    t,
+			name:     fmt.Sprintf("token-%d", i+1),
+			rates:    make(map[string]*tokenRate),
+			requests: make(map[string]int64),
+		})
+	}
+	return p
+}
+
+// rateLimitResource returns the rate limit bucket a request is counted against
+func rateLimitResource(req *http.Request) string {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	default:
+		return "core"
+	}
+}
+
+// pick chooses the token to send the next request for a resource with, skipping any in exclude.
+// When no token can be used it returns nil, along with the earliest reset time of the parked tokens (if any)
+func (p *tokenPool) pick(resource string, now time.Time, exclude map[*pooledToken]bool) (*pooledToken, time.Time) {
+	var (
+		best          *pooledToken
+		bestRemaining int
+		earliest      time.Time
+	)
+	for _, t := range p.tokens {
+		// Tokens GitHub hasn't reported on yet are assumed to have a full quota so they get tried
+		remaining := 5000
+		if r, ok := t.rates[resource]; ok && r.known {
+			remaining = r.remaining
+			if remaining <= 0 && now.Before(r.reset) {
+				if earliest.IsZero() || r.reset.Before(earliest) {
+					earliest = r.reset
+				}
+				continue
+			}
+			if remaining <= 0 {
+				// The window has reset since this token was parked
+				remaining = 5000
+			}
+		}
+		if exclude[t] {
+			continue
+		}
+		if best == nil || remaining > bestRemaining {
+			best, bestRemaining = t, remaining
+		}
+	}
+	return best, earliest
+}
+
+// update records the rate limit headers GitHub sent back for a token
+func (p *tokenPool) update(t *pooledToken, resource string, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	r, ok := t.rates[resource]
+	if !ok {
+		r = &tokenRate{}
+		t.rates[resource] = r
+	}
+	r.known = true
+	r.remaining = remaining
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		r.reset = time.Unix(reset, 0)
+	}
+	tokenRemaining.WithLabelValues(t.name, resource).Set(float64(remaining))
+
+	if remaining == 0 {
+		log.Warnf("GitHub %s rate limit exhausted for %s, parking it until %s", resource, t.name, r.reset.Format(time.RFC3339))
+	}
+}
+
+// isRateLimited reports whether a response was refused because the token's quota ran out
+func isRateLimited(resp *http.Response) bool {
+	return (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req)
+	tried := make(map[*pooledToken]bool)
+	for {
+		p.mu.Lock()
+		t, earliest := p.pick(resource, time.Now(), tried)
+		p.mu.Unlock()
+
+		if t == nil {
+			if earliest.IsZero() {
+				return nil, fmt.Errorf("every GitHub token was refused for this request without a rate limit reset time")
+			}
+			// Every token is parked, so wait for the first one to come back
+			wait := time.Until(earliest) + time.Second
+			logging.FromContext(req.Context()).Warnf("every GitHub token is out of %s quota, waiting %s for the earliest reset", resource, wait.Round(time.Second))
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-time.After(wait):
+			}
+			tried = make(map[*pooledToken]bool)
+			continue
+		}
+
+		// RoundTrippers must not modify the request they're given, and a retried request needs a fresh body
+		r := req.Clone(req.Context())
+		if req.Body != nil && req.GetBody != nil && len(tried) > 0 {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		r.Header.Set("Authorization", "Bearer "+t.token)
+
+		resp, err := p.base.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+
+		p.mu.Lock()
+		t.requests[resource]++
+		tokenRequests.WithLabelValues(t.name, resource).Inc()
+		p.update(t, resource, resp)
+		p.mu.Unlock()
+
+		// Retry a refused request with another token, as long as the body can be sent again
+		if isRateLimited(resp) && (req.Body == nil || req.GetBody != nil) {
+			tried[t] = true
+			_ = resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// usage reports the core API usage of every token in the pool since it was last called, and resets the request counts
+func (p *tokenPool) usage() []TokenUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var usage []TokenUsage
+	for _, t := range p.tokens {
+		u := TokenUsage{Name: t.name, Remaining: -1}
+		for _, n := range t.requests {
+			u.Requests += n
+		}
+		if r, ok := t.rates["core"]; ok && r.known {
+			u.Remaining = r.remaining
+			u.Reset = r.reset
+		}
+		usage = append(usage, u)
+		t.requests = make(map[string]int64)
+	}
+	return usage
+}
+
+// TakeTokenUsage returns the requests sent with each configured token since it was last called, along with each token's remaining quota
+func TakeTokenUsage() []TokenUsage {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	if pool == nil {
+		return nil
+	}
+	return pool.usage()
+}
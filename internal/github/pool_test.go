@@ -0,0 +1,74 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenPoolRotation(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	remaining := map[string]int{"one": 10, "two": 3000, "three": 1}
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		seen = append(seen, token)
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+		if remaining[token] == 0 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		remaining[token]--
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining[token]))
+	}))
+	defer server.Close()
+
+	p := newTokenPool(http.DefaultTransport, []string{"one", "two", "three"})
+	client := &http.Client{Transport: p}
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(server.URL + "/repos/o/r")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Result fail. Received status %d", resp.StatusCode)
+		}
+	}
+
+	// Each token is tried once while its quota is unknown, then the one with the most remaining quota is used
+	for i := 0; i < 5; i++ {
+		get()
+	}
+	expect := []string{"one", "two", "three", "two", "two"}
+	if strings.Join(seen, ",") != strings.Join(expect, ",") {
+		t.Errorf("Result fail. Received %v, Expected %v", seen, expect)
+	}
+
+	// A token that's refused for running out is parked and the request is retried with the next best token
+	p.tokens[1].rates["core"].remaining = 5000
+	remaining["two"] = 0
+	seen = nil
+	get()
+	get()
+	expect = []string{"two", "one", "one"}
+	if strings.Join(seen, ",") != strings.Join(expect, ",") {
+		t.Errorf("Result fail. Received %v, Expected %v", seen, expect)
+	}
+
+	usage := p.usage()
+	if len(usage) != 3 || usage[0].Requests != 3 || usage[1].Requests != 4 || usage[1].Remaining != 0 || usage[2].Remaining != 0 {
+		t.Errorf("Result fail. Received %+v", usage)
+	}
+	if len(usage) == 3 && usage[1].Name != "token-2" {
+		t.Errorf("Result fail. Received name %s, Expected token-2", usage[1].Name)
+	}
+	if usage = p.usage(); usage[0].Requests != 0 {
+		t.Errorf("Result fail. Expected request counts to reset, Received %+v", usage)
+	}
+}
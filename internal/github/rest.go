@@ -0,0 +1,260 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// restAPI collects data through the GitHub REST API (v3)
+type restAPI struct {
+	client *github.Client
+}
+
+func (a *restAPI) getRepository(ctx context.Context, owner string, repo string) (*github.Repository, int, error) {
+	logger := repoLogger(ctx, owner, repo)
+	start := time.Now()
+	logger.Debug("Querying GetRepository")
+	// Get page of repo commits
+	r, resp, err := a.client.Repositories.Get(ctx, owner, repo)
+	var statusCode int
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	if err != nil {
+		return nil, statusCode, fmt.Errorf("GetRepository for %s/%s returned error: \n%v", owner, repo, err)
+	}
+
+	logger.WithFields(logging.Since(start)).Debug("Queried GetRepository")
+	return r, statusCode, nil
+}
+
+// getRepositories has no batch endpoint to use in the REST API, so this makes one request per repository
+func (a *restAPI) getRepositories(ctx context.Context, fullNames []string) (map[string]*github.Repository, error) {
+	repos := make(map[string]*github.Repository)
+	for _, fullName := range fullNames {
+		owner, repo, ok := strings.Cut(fullName, "/")
+		if !ok {
+			return repos, fmt.Errorf("expected repository name in owner/repo format, got \"%s\"", fullName)
+		}
+		r, statusCode, err := a.getRepository(ctx, owner, repo)
+		if statusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return repos, err
+		}
+		repos[fullName] = r
+	}
+	return repos, nil
+}
+
+func (a *restAPI) listRepositoryCommitsByPage(ctx context.Context, owner string, repo string, branch string, start time.Time, end time.Time, fn func(page []*github.RepositoryCommit, last bool) error) (int, error) {
+	logger := repoLogger(ctx, owner, repo)
+	var total, statusCode int
+	var page, perPage int = 1, 100
+
+	// Each page is only cached once fn has handled it, so a page that fails is requested in full again next pass.
+	// The first page is held back until the final page has been handled, since an unchanged first page skips the rest of the listing
+	var firstPage *deferredEntries
+	for {
+		pageCtx, deferred := withDeferredEntries(ctx)
+		if page == 1 {
+			firstPage = deferred
+		}
+		data := github.CommitsListOptions{
+			SHA:   branch,
+			Since: start,
+			Until: end,
+			ListOptions: github.ListOptions{
+				Page:    page,
+				PerPage: perPage,
+			},
+		}
+
+		pageLogger := logger.WithField(logging.FieldPage, page)
+		pageLogger.Debug("Querying ListRepositoryCommits")
+		requested := time.Now()
+		// Get page of repo commits
+		r, resp, err := a.client.Repositories.ListCommits(pageCtx, owner, repo, &data)
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		if err != nil && statusCode == 404 && branch != "" {
+			// The commits endpoint 404s for a branch that doesn't exist as well as for a repo that doesn't exist
+			if _, repoStatusCode, _ := a.getRepository(ctx, owner, repo); repoStatusCode != 404 {
+				return 0, fmt.Errorf("ListRepositoryCommits found no branch %s in %s/%s: \n%v", branch, owner, repo, err)
+			}
+		}
+		if err != nil {
+			return statusCode, fmt.Errorf("ListRepositoryCommits returned error: \n%v", err)
+		}
+
+		// An unchanged first page means there's nothing new in this repo since the last pass
+		if page == 1 && resp.Header.Get(headerFromCache) != "" {
+			pageLogger.WithFields(logging.Since(requested)).Debug("ListRepositoryCommits was not modified since the last request")
+			return statusCode, ErrNotModified
+		}
+		total += len(r)
+		pageLogger.WithFields(logging.Since(requested)).Debugf("Queried ListRepositoryCommits, with %d results", len(r))
+
+		// Hand page to the caller, noting whether this is the last one
+		last := resp.NextPage == 0
+		err = fn(r, last)
+		if err != nil {
+			return statusCode, err
+		}
+		if page != 1 {
+			deferred.save()
+		}
+
+		// Break if out of pages, or flip page
+		if last {
+			break
+		}
+		page++
+	}
+	firstPage.save()
+
+	logger.Debugf("Queried ListRepositoryCommits for every page, with %d results", total)
+	return statusCode, nil
+}
+
+func (a *restAPI) listRepositoriesByOrg(ctx context.Context, org string, visibility string) ([]*github.Repository, error) {
+	// Chech cache
+	var repos []*github.Repository
+	var page, perPage int = 1, 100
+	for {
+		data := github.RepositoryListByOrgOptions{
+			ListOptions: github.ListOptions{
+				Page:    page,
+				PerPage: perPage,
+			},
+		}
+		if visibility != "" {
+			data.Type = visibility
+		}
+
+		logging.FromContext(ctx).WithField(logging.FieldPage, page).Debugf("Querying ListRepositoriesByOrg for %s of type (%s)", org, visibility)
+		// Get page of organization repos
+		r, resp, err := a.client.Repositories.ListByOrg(ctx, org, &data)
+		if err != nil {
+			return nil, fmt.Errorf("ListRepositoriesByOrg returned error: \n%v", err)
+		}
+
+		// Add page to repos slice
+		repos = append(repos, r...)
+
+		// Break if out of pages, or flip page
+		if resp.NextPage == 0 {
+			break
+		}
+		page++
+	}
+
+	logging.FromContext(ctx).Debugf("Queried ListRepositoriesByOrg for %s of type %s, with %d results", org, visibility, len(repos))
+	return repos, nil
+}
+
+func (a *restAPI) getUser(ctx context.Context, login string) (*github.User, []string, int, error) {
+	logging.FromContext(ctx).Debugf("Querying GetUser for %s", login)
+	u, resp, err := a.client.Users.Get(ctx, login)
+	var statusCode int
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	if err != nil {
+		return nil, nil, statusCode, fmt.Errorf("GetUser for %s returned error: \n%v", login, err)
+	}
+
+	// Only public organization memberships are visible for other users
+	var orgs []string
+	var page, perPage int = 1, 100
+	for {
+		r, resp, err := a.client.Organizations.List(ctx, login, &github.ListOptions{Page: page, PerPage: perPage})
+		if err != nil {
+			return nil, nil, statusCode, fmt.Errorf("GetUser organizations for %s returned error: \n%v", login, err)
+		}
+		for _, o := range r {
+			orgs = append(orgs, o.GetLogin())
+		}
+
+		// Break if out of pages, or flip page
+		if resp.NextPage == 0 {
+			break
+		}
+		page++
+	}
+
+	logging.FromContext(ctx).Debugf("Queried GetUser for %s, with %d organizations", login, len(orgs))
+	return u, orgs, statusCode, nil
+}
+
+func (a *restAPI) searchRepositories(ctx context.Context, query string, limit int) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	var page, perPage int = 1, 100
+	for len(repos) < limit {
+		logging.FromContext(ctx).WithField(logging.FieldPage, page).Debugf("Querying SearchRepositories for \"%s\"", query)
+		r, resp, err := a.client.Search.Repositories(ctx, query, &github.SearchOptions{
+			Sort:        "updated",
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("SearchRepositories for \"%s\" returned error: \n%v", query, err)
+		}
+		repos = append(repos, r.Repositories...)
+
+		// Break if out of pages, or flip page
+		if resp.NextPage == 0 {
+			break
+		}
+		page++
+	}
+	if len(repos) > limit {
+		repos = repos[:limit]
+	}
+
+	logging.FromContext(ctx).Debugf("Queried SearchRepositories for \"%s\", with %d results", query, len(repos))
+	return repos, nil
+}
+
+func (a *restAPI) searchCode(ctx context.Context, query string, limit int) ([]string, error) {
+	var fullNames []string
+	seen := make(map[string]bool)
+	var page, perPage int = 1, 100
+	for len(fullNames) < limit {
+		logging.FromContext(ctx).WithField(logging.FieldPage, page).Debugf("Querying SearchCode for \"%s\"", query)
+		r, resp, err := a.client.Search.Code(ctx, query, &github.SearchOptions{
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("SearchCode for \"%s\" returned error: \n%v", query, err)
+		}
+		// Many files in one repository can match, so only the first match for each repository is kept
+		for _, c := range r.CodeResults {
+			fullName := c.GetRepository().GetFullName()
+			if fullName == "" || seen[fullName] {
+				continue
+			}
+			seen[fullName] = true
+			fullNames = append(fullNames, fullName)
+		}
+
+		// Break if out of pages, or flip page
+		if resp.NextPage == 0 {
+			break
+		}
+		page++
+	}
+	if len(fullNames) > limit {
+		fullNames = fullNames[:limit]
+	}
+
+	logging.FromContext(ctx).Debugf("Queried SearchCode for \"%s\", with %d repositories", query, len(fullNames))
+	return fullNames, nil
+}
@@ -0,0 +1,88 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chia-network/ecosystem-activity/internal/tracing"
+)
+
+// tracingTransport starts a span for each HTTP request sent to GitHub, with the page being requested and the rate limit GitHub reports back.
+// It sits under the token pool and app transports, so a request retried with another token gets a span per attempt. Being the last transport
+// before the network, it also drops the headerRouteOwner hint, which the app transport needs but GitHub shouldn't see when there's no app configured
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func newTracingTransport(base http.RoundTripper) *tracingTransport {
+	return &tracingTransport{base: base}
+}
+
+type pageKey struct{}
+
+// withPage returns a copy of ctx noting the page of results the requests made with it are for.
+// REST requests carry their page in the URL, so this is only needed for GraphQL requests, which page with cursors
+func withPage(ctx context.Context, page int) context.Context {
+	return context.WithValue(ctx, pageKey{}, page)
+}
+
+// requestPage returns the page of results a request is for, or 0 if it isn't paginated
+func requestPage(req *http.Request) int {
+	if page, ok := req.Context().Value(pageKey{}).(int); ok {
+		return page
+	}
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil {
+		return 0
+	}
+	return page
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req)
+	ctx, span := tracing.Tracer().Start(req.Context(), "github.request",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.String()),
+			attribute.String("github.rate_limit.resource", resource),
+		),
+	)
+	defer span.End()
+	if page := requestPage(req); page != 0 {
+		span.SetAttributes(attribute.Int("github.page", page))
+	}
+
+	// RoundTrippers must not modify the request they're given, and WithContext shares the headers
+	out := req.WithContext(ctx)
+	if out.Header.Get(headerRouteOwner) != "" {
+		out.Header = out.Header.Clone()
+		out.Header.Del(headerRouteOwner)
+	}
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		span.SetAttributes(attribute.Int("github.rate_limit.remaining", remaining))
+	}
+	if limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil {
+		span.SetAttributes(attribute.Int("github.rate_limit.limit", limit))
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		span.SetAttributes(attribute.Int64("github.rate_limit.reset", reset))
+	}
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		if r.URL.Path == "/repos/o/gone/commits" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newTracingTransport(http.DefaultTransport)}
+	get := func(ctx context.Context, path string) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	get(context.Background(), "/repos/o/r/commits?page=3&per_page=100")
+	get(withPage(context.Background(), 2), "/graphql")
+	get(context.Background(), "/repos/o/gone/commits")
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Result fail. Received %d spans, Expected %d", len(spans), 3)
+	}
+	tests := []struct {
+		page       int64
+		resource   string
+		statusCode int64
+		status     codes.Code
+	}{
+		{3, "core", 200, codes.Unset},
+		{2, "graphql", 200, codes.Unset},
+		{0, "core", 404, codes.Error},
+	}
+	for i, tt := range tests {
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range spans[i].Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		if page := attrs["github.page"].AsInt64(); page != tt.page {
+			t.Errorf("Result fail for span %d page. Received %v, Expected %v", i, page, tt.page)
+		}
+		if resource := attrs["github.rate_limit.resource"].AsString(); resource != tt.resource {
+			t.Errorf("Result fail for span %d resource. Received %v, Expected %v", i, resource, tt.resource)
+		}
+		if remaining := attrs["github.rate_limit.remaining"].AsInt64(); remaining != 4321 {
+			t.Errorf("Result fail for span %d remaining. Received %v, Expected %v", i, remaining, 4321)
+		}
+		if reset := attrs["github.rate_limit.reset"].AsInt64(); reset != 1700000000 {
+			t.Errorf("Result fail for span %d reset. Received %v, Expected %v", i, reset, 1700000000)
+		}
+		if statusCode := attrs["http.response.status_code"].AsInt64(); statusCode != tt.statusCode {
+			t.Errorf("Result fail for span %d status code. Received %v, Expected %v", i, statusCode, tt.statusCode)
+		}
+		if status := spans[i].Status().Code; status != tt.status {
+			t.Errorf("Result fail for span %d status. Received %v, Expected %v", i, status, tt.status)
+		}
+	}
+}
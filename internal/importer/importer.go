@@ -0,0 +1,303 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/utils"
+)
+
+// batchSize is the number of valid commits looked up and written to the db at a time
+const batchSize = 500
+
+// Options controls how an import handles rows
+type Options struct {
+	Format        string // One of the import formats
+	CreateMissing bool   // Create repos and users that aren't in the db yet, instead of rejecting their commits
+	DryRun        bool   // Validate and count everything without writing to the db
+}
+
+// Summary counts what happened to every row of an import
+type Summary struct {
+	Rows       int // Records read from the file
+	Imported   int // Commits written to the db, or that would be for a dry run
+	Duplicates int // Commits already in the db, or earlier in the file
+	Bots       int // Commits by usernames matching the bot list, which aren't imported
+	Invalid    int // Rows that failed validation
+	Unknown    int // Commits to repos or by users that aren't in the db, without CreateMissing
+	NewRepos   int // Repos created, or that would be for a dry run
+	NewUsers   int // Users created, or that would be for a dry run
+}
+
+// Rejected is the number of rows that weren't imported
+func (s Summary) Rejected() int {
+	return s.Duplicates + s.Bots + s.Invalid + s.Unknown
+}
+
+// String formats the summary for logs
+func (s Summary) String() string {
+	return fmt.Sprintf("%d rows: %d imported, %d duplicates, %d bots, %d invalid, %d unknown repo or user; %d new repos, %d new users",
+		s.Rows, s.Imported, s.Duplicates, s.Bots, s.Invalid, s.Unknown, s.NewRepos, s.NewUsers)
+}
+
+// store is the db access an import needs, so imports can be tested without a db
+type store interface {
+	// repoID looks up a repo by its current name or a former one
+	repoID(owner, repo string) (int, bool, error)
+	// existingUsers returns the lowercased usernames of those that are in the users table
+	existingUsers(usernames []string) (map[string]bool, error)
+	// existingSHAs returns the SHAs that are already in the commits table for a repo
+	existingSHAs(repoID int, shas []string) (map[string]bool, error)
+	// write inserts commits in one transaction, creating their users and any repos missing from repoIDs. It returns the IDs of the repos it created by repoKey
+	write(cmts []Commit, repoIDs map[string]int) (map[string]int, error)
+}
+
+// importer holds the state of one import across batches
+type importer struct {
+	opts    Options
+	store   store
+	rejects *csv.Writer
+	summary Summary
+
+	batch    []Commit
+	records  map[int]Record // The records of the batch's commits by line, for the reject file
+	inFile   map[string]bool
+	repoIDs  map[string]int  // Repos known to be in the db by repoKey
+	newRepos map[string]bool // Repos to create, or that would be created for a dry run
+	noRepos  map[string]bool // Repos that aren't in the db, without CreateMissing
+	users    map[string]bool // Lowercased usernames known to be in the db
+	newUsers map[string]bool
+	noUsers  map[string]bool
+}
+
+// Run imports the commits from r, writing every row that isn't imported to rejects as CSV with its line number and the reason.
+// Rows are validated and looked up in batches, so invalid or unknown rows never stop the rest of the file from being imported
+func Run(ctx context.Context, r io.Reader, rejects io.Writer, opts Options) (Summary, error) {
+	return run(r, rejects, opts, dbStore{ctx: ctx})
+}
+
+func run(r io.Reader, rejects io.Writer, opts Options, s store) (Summary, error) {
+	imp := &importer{
+		opts:     opts,
+		store:    s,
+		rejects:  csv.NewWriter(rejects),
+		records:  make(map[int]Record),
+		inFile:   make(map[string]bool),
+		repoIDs:  make(map[string]int),
+		newRepos: make(map[string]bool),
+		noRepos:  make(map[string]bool),
+		users:    make(map[string]bool),
+		newUsers: make(map[string]bool),
+		noUsers:  make(map[string]bool),
+	}
+	err := imp.rejects.Write([]string{"line", "reason", "record"})
+	if err != nil {
+		return imp.summary, fmt.Errorf("error writing reject file header: %v", err)
+	}
+
+	read := readCSV
+	switch opts.Format {
+	case FormatCSV:
+	case FormatJSONL:
+		read = readJSONL
+	default:
+		return imp.summary, fmt.Errorf("unsupported import format \"%s\", expected one of csv or jsonl", opts.Format)
+	}
+
+	err = read(r, imp.add)
+	if err == nil {
+		err = imp.flush()
+	}
+	imp.rejects.Flush()
+	if flushErr := imp.rejects.Error(); flushErr != nil && err == nil {
+		err = fmt.Errorf("error writing reject file: %v", flushErr)
+	}
+	return imp.summary, err
+}
+
+// add validates one record and adds it to the batch, flushing the batch when it's full
+func (imp *importer) add(rec Record) error {
+	imp.summary.Rows++
+
+	c, err := validate(rec)
+	if err != nil {
+		imp.summary.Invalid++
+		return imp.reject(rec, err.Error())
+	}
+	if utils.MatchesBot(c.Username) {
+		imp.summary.Bots++
+		return imp.reject(rec, fmt.Sprintf("username %s matches the bot list", c.Username))
+	}
+	key := c.repoKey() + "@" + c.SHA
+	if imp.inFile[key] {
+		imp.summary.Duplicates++
+		return imp.reject(rec, "duplicate of an earlier row")
+	}
+	imp.inFile[key] = true
+
+	imp.batch = append(imp.batch, c)
+	imp.records[c.Line] = rec
+	if len(imp.batch) >= batchSize {
+		return imp.flush()
+	}
+	return nil
+}
+
+// reject writes a row that isn't imported to the reject file
+func (imp *importer) reject(rec Record, reason string) error {
+	err := imp.rejects.Write(append([]string{strconv.Itoa(rec.Line), reason}, rec.Raw...))
+	if err != nil {
+		return fmt.Errorf("error writing reject file: %v", err)
+	}
+	return nil
+}
+
+// flush looks up the batch's repos, users, and existing commits, rejecting unknown and duplicate commits and writing the rest
+func (imp *importer) flush() error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+	batch, records := imp.batch, imp.records
+	imp.batch, imp.records = nil, make(map[int]Record)
+
+	err := imp.resolveRepos(batch)
+	if err != nil {
+		return err
+	}
+	err = imp.resolveUsers(batch)
+	if err != nil {
+		return err
+	}
+
+	// Reject commits to unknown repos or by unknown users, and group the rest by existing repo to check for duplicates
+	var known []Commit
+	shasByRepo := make(map[int][]string)
+	for _, c := range batch {
+		switch {
+		case imp.noRepos[c.repoKey()]:
+			imp.summary.Unknown++
+			err = imp.reject(records[c.Line], fmt.Sprintf("unknown repo %s/%s", c.Owner, c.Repo))
+		case imp.noUsers[strings.ToLower(c.Username)]:
+			imp.summary.Unknown++
+			err = imp.reject(records[c.Line], fmt.Sprintf("unknown user %s", c.Username))
+		default:
+			known = append(known, c)
+			if id, ok := imp.repoIDs[c.repoKey()]; ok {
+				shasByRepo[id] = append(shasByRepo[id], c.SHA)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	existing := make(map[int]map[string]bool)
+	for id, shas := range shasByRepo {
+		existing[id], err = imp.store.existingSHAs(id, shas)
+		if err != nil {
+			return err
+		}
+	}
+
+	var toWrite []Commit
+	for _, c := range known {
+		if id, ok := imp.repoIDs[c.repoKey()]; ok && existing[id][c.SHA] {
+			imp.summary.Duplicates++
+			err = imp.reject(records[c.Line], "already imported")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		toWrite = append(toWrite, c)
+	}
+	imp.summary.Imported += len(toWrite)
+
+	if imp.opts.DryRun || len(toWrite) == 0 {
+		return nil
+	}
+
+	created, err := imp.store.write(toWrite, imp.repoIDs)
+	if err != nil {
+		return err
+	}
+	for key, id := range created {
+		imp.repoIDs[key] = id
+		delete(imp.newRepos, key)
+	}
+	for _, c := range toWrite {
+		username := strings.ToLower(c.Username)
+		imp.users[username] = true
+		delete(imp.newUsers, username)
+	}
+	return nil
+}
+
+// resolveRepos looks up the batch's repos that haven't been seen yet
+func (imp *importer) resolveRepos(batch []Commit) error {
+	for _, c := range batch {
+		key := c.repoKey()
+		if _, ok := imp.repoIDs[key]; ok || imp.newRepos[key] || imp.noRepos[key] {
+			continue
+		}
+		id, ok, err := imp.store.repoID(c.Owner, c.Repo)
+		if err != nil {
+			return err
+		}
+		switch {
+		case ok:
+			imp.repoIDs[key] = id
+		case imp.opts.CreateMissing:
+			imp.newRepos[key] = true
+			imp.summary.NewRepos++
+			log.Debugf("repo %s/%s isn't in the repos table and will be created", c.Owner, c.Repo)
+		default:
+			imp.noRepos[key] = true
+		}
+	}
+	return nil
+}
+
+// resolveUsers looks up the batch's users that haven't been seen yet
+func (imp *importer) resolveUsers(batch []Commit) error {
+	lookup := make(map[string]string)
+	for _, c := range batch {
+		username := strings.ToLower(c.Username)
+		if imp.users[username] || imp.newUsers[username] || imp.noUsers[username] {
+			continue
+		}
+		lookup[username] = c.Username
+	}
+	if len(lookup) == 0 {
+		return nil
+	}
+
+	usernames := make([]string, 0, len(lookup))
+	for _, u := range lookup {
+		usernames = append(usernames, u)
+	}
+	sort.Strings(usernames)
+	found, err := imp.store.existingUsers(usernames)
+	if err != nil {
+		return err
+	}
+	for username := range lookup {
+		switch {
+		case found[username]:
+			imp.users[username] = true
+		case imp.opts.CreateMissing:
+			imp.newUsers[username] = true
+			imp.summary.NewUsers++
+		default:
+			imp.noUsers[username] = true
+		}
+	}
+	return nil
+}
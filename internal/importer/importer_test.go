@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStore is an in-memory store holding one repo, Chia-Network/chia-blockchain with ID 1, one user, alice, and one commit
+type fakeStore struct {
+	written []Commit
+	created []string
+}
+
+func (f *fakeStore) repoID(owner, repo string) (int, bool, error) {
+	if strings.EqualFold(owner, "Chia-Network") && strings.EqualFold(repo, "chia-blockchain") {
+		return 1, true, nil
+	}
+	return 0, false, nil
+}
+
+func (f *fakeStore) existingUsers(usernames []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	for _, u := range usernames {
+		if strings.EqualFold(u, "alice") {
+			found["alice"] = true
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeStore) existingSHAs(repoID int, shas []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for _, sha := range shas {
+		if repoID == 1 && sha == "0000000000000000000000000000000000000001" {
+			existing[sha] = true
+		}
+	}
+	return existing, nil
+}
+
+func (f *fakeStore) write(cmts []Commit, repoIDs map[string]int) (map[string]int, error) {
+	created := make(map[string]int)
+	for _, c := range cmts {
+		if _, ok := repoIDs[c.repoKey()]; !ok {
+			if _, ok := created[c.repoKey()]; !ok {
+				created[c.repoKey()] = 100 + len(created)
+				f.created = append(f.created, c.Owner+"/"+c.Repo)
+			}
+		}
+	}
+	f.written = append(f.written, cmts...)
+	return created, nil
+}
+
+func runFile(t *testing.T, name string, opts Options) (Summary, *fakeStore, [][]string) {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	s := &fakeStore{}
+	var rejects bytes.Buffer
+	summary, err := run(f, &rejects, opts, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := csv.NewReader(&rejects)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary, s, rows[1:]
+}
+
+func TestRunCSV(t *testing.T) {
+	summary, s, rejects := runFile(t, "commits.csv", Options{Format: FormatCSV})
+
+	expect := Summary{Rows: 9, Imported: 2, Duplicates: 2, Bots: 1, Invalid: 2, Unknown: 2}
+	if summary != expect {
+		t.Errorf("Result fail. Received %+v, Expected %+v", summary, expect)
+	}
+	if len(s.written) != 2 || !s.written[0].Date.Equal(time.Date(2023, time.January, 2, 15, 4, 5, 0, time.UTC)) || s.written[1].SHA != "e1b2c3d4e5f60718293a4b5c6d7e8f9012345678" {
+		t.Errorf("Result fail. Received written commits %+v", s.written)
+	}
+
+	// Rejects keep the line number, the reason, and the original fields
+	reasons := make(map[string]string)
+	for _, r := range rejects {
+		reasons[r[0]] = r[1]
+	}
+	expectReasons := map[string]string{
+		"3":  "duplicate of an earlier row",
+		"4":  "unknown user bob",
+		"5":  `invalid date "not a date"`,
+		"6":  `invalid SHA "not-a-sha"`,
+		"7":  "username dependabot[bot] matches the bot list",
+		"9":  "unknown repo someone/new-tool",
+		"10": "already imported",
+	}
+	if !reflect.DeepEqual(reasons, expectReasons) {
+		t.Errorf("Result fail. Received %v, Expected %v", reasons, expectReasons)
+	}
+	if rejects[0][2] != "Chia-Network" || len(rejects[0]) != 7 {
+		t.Errorf("Result fail. Received reject %v, Expected the original fields", rejects[0])
+	}
+}
+
+func TestRunCreateMissing(t *testing.T) {
+	summary, s, _ := runFile(t, "commits.csv", Options{Format: FormatCSV, CreateMissing: true})
+
+	expect := Summary{Rows: 9, Imported: 4, Duplicates: 2, Bots: 1, Invalid: 2, NewRepos: 1, NewUsers: 1}
+	if summary != expect {
+		t.Errorf("Result fail. Received %+v, Expected %+v", summary, expect)
+	}
+	if len(s.written) != 4 || !reflect.DeepEqual(s.created, []string{"someone/new-tool"}) {
+		t.Errorf("Result fail. Received written commits %+v and created repos %v", s.written, s.created)
+	}
+}
+
+func TestRunJSONLDryRun(t *testing.T) {
+	summary, s, rejects := runFile(t, "commits.jsonl", Options{Format: FormatJSONL, CreateMissing: true, DryRun: true})
+
+	expect := Summary{Rows: 4, Imported: 3, Invalid: 1, NewRepos: 1, NewUsers: 1}
+	if summary != expect {
+		t.Errorf("Result fail. Received %+v, Expected %+v", summary, expect)
+	}
+	if len(s.written) != 0 {
+		t.Errorf("Result fail. Received written commits %+v, Expected none for a dry run", s.written)
+	}
+	if len(rejects) != 1 || rejects[0][0] != "5" || !strings.HasPrefix(rejects[0][1], "invalid JSON") {
+		t.Errorf("Result fail. Received rejects %v", rejects)
+	}
+}
+
+func TestReadCSVHeaders(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect Record
+	}{
+		// Export column names, with the repo given as owner/repo
+		{"date,sha,repo,username\n2026-09-01,abc1234,Chia-Network/chia-blockchain,alice\n",
+			Record{Line: 2, Repo: "Chia-Network/chia-blockchain", Username: "alice", SHA: "abc1234", Date: "2026-09-01"}},
+		// The old reporting tool's columns, without a header
+		{"Chia-Network,chia-blockchain,alice,abc1234,2026-09-01\n",
+			Record{Line: 1, Owner: "Chia-Network", Repo: "chia-blockchain", Username: "alice", SHA: "abc1234", Date: "2026-09-01"}},
+	}
+	for _, test := range tests {
+		var records []Record
+		err := readCSV(strings.NewReader(test.input), func(r Record) error {
+			r.Raw = nil
+			records = append(records, r)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || !reflect.DeepEqual(records[0], test.expect) {
+			t.Errorf("Result fail. Received %+v, Expected %+v", records, test.expect)
+		}
+	}
+
+	err := readCSV(strings.NewReader("owner,repo,sha\nChia-Network,chia-blockchain,abc1234\n"), func(Record) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "username, date") {
+		t.Errorf("Result fail. Received %v, Expected an error naming the missing columns", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := validate(Record{Repo: "Chia-Network/chia-blockchain", Username: "alice", SHA: "A1B2C3D4E5F60718293A4B5C6D7E8F9012345678", Date: "2026-09-01 10:00:00 -0500"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Owner != "Chia-Network" || c.Repo != "chia-blockchain" || c.SHA != "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678" || !c.Date.Equal(time.Date(2026, time.September, 1, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("Result fail. Received %+v", c)
+	}
+
+	_, err = validate(Record{Owner: "Chia-Network", SHA: "abc1234"})
+	if err == nil || err.Error() != "missing repo, username, date" {
+		t.Errorf("Result fail. Received %v, Expected missing repo, username, date", err)
+	}
+
+	// Only full SHA-1 and SHA-256 hashes are accepted, an abbreviated hash would be counted again when the collector stores the full one
+	for _, sha := range []string{"abc1234", "a1b2c3d4e5f60718293a4b5c6d7e8f901234567", strings.Repeat("a", 41), strings.Repeat("a", 63)} {
+		_, err = validate(Record{Repo: "Chia-Network/chia-blockchain", Username: "alice", SHA: sha, Date: "2026-09-01"})
+		if expected := fmt.Sprintf("invalid SHA \"%s\"", sha); err == nil || err.Error() != expected {
+			t.Errorf("Result fail for %s. Received %v, Expected %s", sha, err, expected)
+		}
+	}
+	if _, err = validate(Record{Repo: "Chia-Network/chia-blockchain", Username: "alice", SHA: strings.Repeat("a", 64), Date: "2026-09-01"}); err != nil {
+		t.Errorf("Result fail. Received %v, Expected a SHA-256 hash to be accepted", err)
+	}
+}
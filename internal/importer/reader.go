@@ -0,0 +1,230 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// The formats commits can be imported from
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// The fields of an imported commit
+const (
+	fieldOwner    = "owner"
+	fieldRepo     = "repo"
+	fieldUsername = "username"
+	fieldSHA      = "sha"
+	fieldDate     = "date"
+)
+
+// fieldAliases maps normalized column names to fields. Names are normalized by normalizeColumn, so "Commit Author" matches "commitauthor"
+var fieldAliases = map[string]string{
+	"owner":        fieldOwner,
+	"repoowner":    fieldOwner,
+	"org":          fieldOwner,
+	"organization": fieldOwner,
+	"repo":         fieldRepo,
+	"repository":   fieldRepo,
+	"reponame":     fieldRepo,
+	"username":     fieldUsername,
+	"user":         fieldUsername,
+	"login":        fieldUsername,
+	"author":       fieldUsername,
+	"authorlogin":  fieldUsername,
+	"commitauthor": fieldUsername,
+	"sha":          fieldSHA,
+	"commitsha":    fieldSHA,
+	"hash":         fieldSHA,
+	"commit":       fieldSHA,
+	"date":         fieldDate,
+	"commitdate":   fieldDate,
+	"committedat":  fieldDate,
+	"timestamp":    fieldDate,
+}
+
+// legacyColumns is the column order of CSVs from the old reporting tool, used for files without a recognizable header
+var legacyColumns = []string{fieldOwner, fieldRepo, fieldUsername, fieldSHA, fieldDate}
+
+// Record is one commit read from an import file, before it's validated
+type Record struct {
+	Line     int // The line number in the file, starting at 1
+	Owner    string
+	Repo     string
+	Username string
+	SHA      string
+	Date     string
+	Raw      []string // The fields of a CSV line, or the text of a JSON Lines line, for the reject file
+
+	ParseError string // Set when the line couldn't be parsed at all
+}
+
+// DetectFormat returns the import format for a file path by its extension, defaulting to CSV
+func DetectFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return FormatCSV
+	}
+}
+
+// normalizeColumn lowercases a column name and strips everything but letters and digits
+func normalizeColumn(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// detectColumns maps each field to its index in a CSV header. ok is false when the header doesn't name any field
+func detectColumns(header []string) (columns map[string]int, ok bool, err error) {
+	columns = make(map[string]int)
+	for i, name := range header {
+		field, known := fieldAliases[normalizeColumn(name)]
+		if !known {
+			continue
+		}
+		if _, dup := columns[field]; dup {
+			return nil, true, fmt.Errorf("more than one column in the header is a %s column", field)
+		}
+		columns[field] = i
+	}
+	if len(columns) == 0 {
+		return nil, false, nil
+	}
+	return columns, true, checkColumns(columns)
+}
+
+// checkColumns returns an error naming any required fields that are missing. The owner can be left out when repos are given as owner/repo
+func checkColumns(columns map[string]int) error {
+	var missing []string
+	for _, field := range []string{fieldRepo, fieldUsername, fieldSHA, fieldDate} {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("couldn't find a column for %s in the header", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// setField sets one field of a record
+func (r *Record) setField(field, value string) {
+	value = strings.TrimSpace(value)
+	switch field {
+	case fieldOwner:
+		r.Owner = value
+	case fieldRepo:
+		r.Repo = value
+	case fieldUsername:
+		r.Username = value
+	case fieldSHA:
+		r.SHA = value
+	case fieldDate:
+		r.Date = value
+	}
+}
+
+// readCSV calls fn with every record in a CSV, detecting its columns from the header. CSVs without a recognizable header are read in the old reporting tool's column order
+func readCSV(r io.Reader, fn func(Record) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var columns map[string]int
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			// Malformed lines are passed on as invalid records, so the rest of the file can still be imported
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && columns != nil {
+				err = fn(Record{Line: parseErr.StartLine, Raw: fields, ParseError: parseErr.Err.Error()})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("error reading CSV: %v", err)
+		}
+
+		if columns == nil {
+			detected, ok, err := detectColumns(fields)
+			if err != nil {
+				return err
+			}
+			if ok {
+				columns = detected
+				continue
+			}
+			if len(fields) != len(legacyColumns) {
+				return fmt.Errorf("couldn't detect columns from the CSV header, expected column names including repo, username, sha, and date")
+			}
+			columns = make(map[string]int)
+			for i, field := range legacyColumns {
+				columns[field] = i
+			}
+		}
+
+		rec := Record{Line: line, Raw: fields}
+		for field, i := range columns {
+			if i < len(fields) {
+				rec.setField(field, fields[i])
+			}
+		}
+		err = fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONL calls fn with every record in a JSON Lines file, such as one written by the export command. Each object's keys are matched to fields like CSV columns
+func readJSONL(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		rec := Record{Line: line, Raw: []string{text}}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(text), &obj); err != nil {
+			rec.ParseError = fmt.Sprintf("invalid JSON: %v", err)
+		}
+		for key, value := range obj {
+			field, ok := fieldAliases[normalizeColumn(key)]
+			if !ok || value == nil {
+				continue
+			}
+			rec.setField(field, fmt.Sprint(value))
+		}
+		err := fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading JSON Lines: %v", err)
+	}
+	return nil
+}
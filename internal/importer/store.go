@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/db"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/db/users"
+)
+
+// dbStore is the store backed by the db package, making its statements with the import's context
+type dbStore struct {
+	ctx context.Context
+}
+
+func (s dbStore) repoID(owner, repo string) (int, bool, error) {
+	rows, err := repos.GetRowsByOwnerAndRepo(s.ctx, owner, repo)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(rows) == 0 {
+		// Commits from before a rename or transfer are imported to the repo's current row
+		rows, err = repos.GetRowsByAlias(s.ctx, owner, repo)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	if len(rows) == 0 {
+		return 0, false, nil
+	}
+	return rows[0].ID, true, nil
+}
+
+// readTx runs fn in a transaction that's rolled back afterwards, for the lookups that are only available as part of a transaction
+func readTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction for import lookups: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for import lookups: %v", err)
+		}
+	}(tx)
+	return fn(tx)
+}
+
+func (s dbStore) existingUsers(usernames []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	err := readTx(s.ctx, func(tx *sql.Tx) error {
+		ids, err := users.GetIDsByUsernames(s.ctx, tx, usernames)
+		for username := range ids {
+			found[username] = true
+		}
+		return err
+	})
+	return found, err
+}
+
+func (s dbStore) existingSHAs(repoID int, shas []string) (map[string]bool, error) {
+	var existing map[string]bool
+	err := readTx(s.ctx, func(tx *sql.Tx) error {
+		var err error
+		existing, err = commits.GetExistingSHAsByRepoID(s.ctx, tx, repoID, shas)
+		return err
+	})
+	return existing, err
+}
+
+func (s dbStore) write(cmts []Commit, repoIDs map[string]int) (map[string]int, error) {
+	tx, err := db.BeginTx(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction for import: %v", err)
+	}
+	defer func(tx *sql.Tx) {
+		// Rollback is a no-op once the transaction has been committed
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("error rolling back transaction for import: %v", err)
+		}
+	}(tx)
+
+	// Create missing repos, and group commits by repo
+	created := make(map[string]int)
+	byRepo := make(map[int][]Commit)
+	for _, c := range cmts {
+		key := c.repoKey()
+		id, ok := repoIDs[key]
+		if !ok {
+			id, ok = created[key]
+		}
+		if !ok {
+			id, err = repos.Create(s.ctx, tx, c.Owner, c.Repo)
+			if err != nil {
+				return nil, err
+			}
+			created[key] = id
+		}
+		byRepo[id] = append(byRepo[id], c)
+	}
+
+	// Add or widen the commit range of every author, then look up all of their IDs at once
+	userRanges := userCommitRanges(cmts)
+	err = users.UpsertCommitRanges(s.ctx, tx, userRanges)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, 0, len(userRanges))
+	for _, u := range userRanges {
+		usernames = append(usernames, u.Username)
+	}
+	userIDs, err := users.GetIDsByUsernames(s.ctx, tx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	for repoID, repoCmts := range byRepo {
+		// Check for duplicates again in this transaction, in case the collector added some of these commits since they were looked up.
+		// A commit the collector adds between this check and the insert is left out of the insert by the commits table's unique index on repo and SHA
+		shas := make([]string, 0, len(repoCmts))
+		for _, c := range repoCmts {
+			shas = append(shas, c.SHA)
+		}
+		existing, err := commits.GetExistingSHAsByRepoID(s.ctx, tx, repoID, shas)
+		if err != nil {
+			return nil, err
+		}
+
+		var earliest, latest time.Time
+		rows := make([]commits.Commit, 0, len(repoCmts))
+		for _, c := range repoCmts {
+			if existing[c.SHA] {
+				continue
+			}
+			userID, ok := userIDs[strings.ToLower(c.Username)]
+			if !ok {
+				return nil, fmt.Errorf("user %s was not found in users table after upserting", c.Username)
+			}
+			rows = append(rows, commits.Commit{RepoID: repoID, UserID: userID, Date: c.Date, SHA: c.SHA})
+			if earliest.IsZero() || c.Date.Before(earliest) {
+				earliest = c.Date
+			}
+			if latest.IsZero() || c.Date.After(latest) {
+				latest = c.Date
+			}
+		}
+		_, err = commits.SetNewRecords(s.ctx, tx, rows)
+		if err != nil {
+			return nil, err
+		}
+		err = repos.UpdateCommitRangeByID(s.ctx, tx, repoID, earliest, latest, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction for import: %v", err)
+	}
+	return created, nil
+}
+
+// userCommitRanges reduces commits to one User per author, holding the earliest and latest commit timestamps for that author
+func userCommitRanges(cmts []Commit) []users.User {
+	var us []users.User
+	index := make(map[string]int)
+	for _, c := range cmts {
+		key := strings.ToLower(c.Username)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(us)
+			us = append(us, users.User{Username: c.Username, FirstCommit: c.Date, LastCommit: c.Date})
+			continue
+		}
+		if us[i].FirstCommit.After(c.Date) {
+			us[i].FirstCommit = c.Date
+		}
+		if us[i].LastCommit.Before(c.Date) {
+			us[i].LastCommit = c.Date
+		}
+	}
+	return us
+}
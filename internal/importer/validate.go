@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// shaPattern matches full SHA-1 and SHA-256 commit hashes. Abbreviated hashes aren't accepted, since the collector stores the full hash
+// and would count the same commit again under it
+var shaPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// dateLayouts are the date formats accepted for commit dates, tried in order. Dates without a time zone are UTC
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700 MST", // Go's time.Time String format
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// Commit is a validated commit from an import file
+type Commit struct {
+	Line     int
+	Owner    string
+	Repo     string
+	Username string
+	SHA      string // Lowercased
+	Date     time.Time
+}
+
+// repoKey is the case-insensitive key of the commit's repo
+func (c Commit) repoKey() string {
+	return strings.ToLower(c.Owner + "/" + c.Repo)
+}
+
+// parseDate parses a commit date in any of the accepted layouts, returning it in UTC
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date \"%s\"", value)
+}
+
+// validate checks a record's fields, returning the commit it holds. Repos given as owner/repo without an owner are split
+func validate(rec Record) (Commit, error) {
+	if rec.ParseError != "" {
+		return Commit{}, fmt.Errorf("%s", rec.ParseError)
+	}
+
+	c := Commit{Line: rec.Line, Owner: rec.Owner, Repo: rec.Repo, Username: rec.Username}
+	if c.Owner == "" {
+		if owner, repo, ok := strings.Cut(c.Repo, "/"); ok {
+			c.Owner, c.Repo = owner, repo
+		}
+	}
+
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value string
+	}{{fieldOwner, c.Owner}, {fieldRepo, c.Repo}, {fieldUsername, c.Username}, {fieldSHA, rec.SHA}, {fieldDate, rec.Date}} {
+		if f.value == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) > 0 {
+		return Commit{}, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	if strings.Contains(c.Owner, "/") || strings.Contains(c.Repo, "/") {
+		return Commit{}, fmt.Errorf("invalid repo \"%s/%s\"", c.Owner, c.Repo)
+	}
+
+	c.SHA = strings.ToLower(rec.SHA)
+	if !shaPattern.MatchString(c.SHA) {
+		return Commit{}, fmt.Errorf("invalid SHA \"%s\"", rec.SHA)
+	}
+
+	date, err := parseDate(rec.Date)
+	if err != nil {
+		return Commit{}, err
+	}
+	if date.After(time.Now().Add(24 * time.Hour)) {
+		return Commit{}, fmt.Errorf("date \"%s\" is in the future", rec.Date)
+	}
+	c.Date = date
+	return c, nil
+}
@@ -0,0 +1,169 @@
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	leaderleases "github.com/chia-network/ecosystem-activity/internal/db/leader_leases"
+)
+
+// LeaseName is the lease replicas of this application compete for to run the collector and scheduled jobs
+const LeaseName = "collector"
+
+var isLeaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "ecosystem_activity_leader",
+	Help: "1 if this replica holds the leader lease and runs the collector and scheduled jobs, 0 otherwise",
+})
+
+// store holds leases, so elections can be tested without a db
+type store interface {
+	// tryAcquire takes or renews a lease for holder until ttl from now, returning whether holder has it
+	tryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// release gives up a lease if holder has it
+	release(ctx context.Context, name, holder string) error
+}
+
+// Options configures an Elector
+type Options struct {
+	Name string        // The lease to compete for (default: LeaseName)
+	ID   string        // Identifies this replica in the lease, unique among replicas (default: DefaultID)
+	TTL  time.Duration // How long the lease lasts without being renewed. It's renewed three times per TTL (default: 30s)
+}
+
+// Elector competes with the other replicas for a lease, and runs the leader's work while it holds it
+type Elector struct {
+	store   store
+	name    string
+	id      string
+	ttl     time.Duration
+	leading atomic.Bool
+}
+
+// New constructs an Elector backed by the leader_leases table
+func New(opts Options) (*Elector, error) {
+	return newElector(dbStore{}, opts)
+}
+
+func newElector(s store, opts Options) (*Elector, error) {
+	if opts.Name == "" {
+		opts.Name = LeaseName
+	}
+	if opts.ID == "" {
+		id, err := DefaultID()
+		if err != nil {
+			return nil, err
+		}
+		opts.ID = id
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 30 * time.Second
+	}
+	return &Elector{store: s, name: opts.Name, id: opts.ID, ttl: opts.TTL}, nil
+}
+
+// DefaultID identifies this replica by its hostname, which is the pod name in Kubernetes, with a random suffix so replicas sharing a hostname differ
+func DefaultID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("getting hostname for leader ID: %v", err)
+	}
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return "", fmt.Errorf("generating leader ID: %v", err)
+	}
+	return fmt.Sprintf("%s-%s", hostname, hex.EncodeToString(suffix)), nil
+}
+
+// ID returns the ID this replica holds the lease under
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader returns whether this replica currently holds the lease and is running the leader's work
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Run competes for the lease until ctx is cancelled. Each time this replica takes the lease, lead is started with a context that carries the lease
+// for leaderleases.Fence, and that's cancelled when the lease is lost, or can't be renewed, or ctx is cancelled. Run waits for lead to return before
+// competing again, and releases the lease before returning so another replica can take over without waiting for it to expire
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	renewEvery := e.ttl / 3
+	ticker := time.NewTicker(renewEvery)
+	defer ticker.Stop()
+
+	var term *term
+	for {
+		// A renewal that takes longer than the renewal interval is treated as lost, leaving time to stop before the lease expires
+		attemptCtx, cancelFunc := context.WithTimeout(ctx, renewEvery)
+		held, err := e.store.tryAcquire(attemptCtx, e.name, e.id, e.ttl)
+		cancelFunc()
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("error renewing leader lease %s for %s: %v", e.name, e.id, err)
+		}
+
+		switch {
+		case held && term == nil:
+			log.Infof("%s took the leader lease %s", e.id, e.name)
+			term = e.startTerm(ctx, lead)
+		case !held && term != nil && ctx.Err() == nil:
+			log.Warnf("%s lost the leader lease %s, stopping the leader's work", e.id, e.name)
+			e.endTerm(term)
+			term = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if term != nil {
+				e.endTerm(term)
+			}
+			releaseCtx, cancelFunc := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancelFunc()
+			err := e.store.release(releaseCtx, e.name, e.id)
+			if err != nil {
+				log.Errorf("error releasing leader lease %s for %s: %v", e.name, e.id, err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// term is one stretch of holding the lease
+type term struct {
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// startTerm starts the leader's work in its own goroutine, with a context carrying the lease so the work's transactions can be fenced on it
+func (e *Elector) startTerm(ctx context.Context, lead func(ctx context.Context)) *term {
+	leadCtx, cancelFunc := context.WithCancel(leaderleases.WithLease(ctx, e.name, e.id))
+	t := &term{cancel: cancelFunc}
+	e.leading.Store(true)
+	isLeaderGauge.Set(1)
+	t.done.Add(1)
+	go func() {
+		defer t.done.Done()
+		lead(leadCtx)
+	}()
+	return t
+}
+
+// endTerm stops the leader's work and waits for it to return
+func (e *Elector) endTerm(t *term) {
+	e.leading.Store(false)
+	isLeaderGauge.Set(0)
+	t.cancel()
+	t.done.Wait()
+}
@@ -0,0 +1,186 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore holds leases in memory, shared by several electors to stand in for replicas sharing a db
+type memoryStore struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+	down   map[string]bool // Holders that can't reach the store
+}
+
+type memoryLease struct {
+	holder  string
+	expires time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{leases: make(map[string]memoryLease), down: make(map[string]bool)}
+}
+
+func (s *memoryStore) tryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down[holder] {
+		return false, errors.New("store unreachable")
+	}
+	l := s.leases[name]
+	now := time.Now()
+	if l.holder != "" && l.holder != holder && now.Before(l.expires) {
+		return false, nil
+	}
+	s.leases[name] = memoryLease{holder: holder, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) release(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down[holder] {
+		return errors.New("store unreachable")
+	}
+	if s.leases[name].holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
+
+func (s *memoryStore) setDown(holder string, down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down[holder] = down
+}
+
+// replica is one elector running in the test, along with a count of the leaders doing the leader's work at once
+type replica struct {
+	elector *Elector
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func startReplicas(t *testing.T, s store, n int, active *atomic.Int32, maxActive *atomic.Int32) []*replica {
+	t.Helper()
+	var replicas []*replica
+	for i := range n {
+		e, err := newElector(s, Options{ID: fmt.Sprintf("replica-%d", i), TTL: 60 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &replica{elector: e, cancel: cancel, done: make(chan struct{})}
+		go func() {
+			defer close(r.done)
+			e.Run(ctx, func(ctx context.Context) {
+				n := active.Add(1)
+				for {
+					m := maxActive.Load()
+					if n <= m || maxActive.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				<-ctx.Done()
+				active.Add(-1)
+			})
+		}()
+		replicas = append(replicas, r)
+	}
+	t.Cleanup(func() {
+		for _, r := range replicas {
+			r.cancel()
+			<-r.done
+		}
+	})
+	return replicas
+}
+
+// waitForLeader waits for exactly one of the replicas to be the leader, returning it
+func waitForLeader(t *testing.T, replicas []*replica) *replica {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []*replica
+		for _, r := range replicas {
+			if r.elector.IsLeader() {
+				leaders = append(leaders, r)
+			}
+		}
+		if len(leaders) == 1 {
+			return leaders[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Result fail. Expected one leader within 2s")
+	return nil
+}
+
+func TestElectorHandsOverOnShutdown(t *testing.T) {
+	var active, maxActive atomic.Int32
+	replicas := startReplicas(t, newMemoryStore(), 3, &active, &maxActive)
+
+	first := waitForLeader(t, replicas)
+	first.cancel()
+	<-first.done
+	if first.elector.IsLeader() {
+		t.Errorf("Result fail. Expected %s to stop leading once it was shut down", first.elector.ID())
+	}
+
+	// The lease is released on shutdown, so another replica takes over well before it would have expired
+	var rest []*replica
+	for _, r := range replicas {
+		if r != first {
+			rest = append(rest, r)
+		}
+	}
+	start := time.Now()
+	second := waitForLeader(t, rest)
+	if waited := time.Since(start); waited > 60*time.Millisecond {
+		t.Errorf("Result fail. Received a handover after %s, Expected it before the lease expired", waited)
+	}
+	if second == first {
+		t.Errorf("Result fail. Received %s leading again after shutting down", second.elector.ID())
+	}
+	if m := maxActive.Load(); m != 1 {
+		t.Errorf("Result fail. Received %d leaders at once, Expected %d", m, 1)
+	}
+}
+
+func TestElectorStepsDownWhenRenewalFails(t *testing.T) {
+	var active, maxActive atomic.Int32
+	s := newMemoryStore()
+	replicas := startReplicas(t, s, 2, &active, &maxActive)
+
+	first := waitForLeader(t, replicas)
+	s.setDown(first.elector.ID(), true)
+
+	// The leader stops at its next failed renewal, and the other replica takes the lease once it expires
+	var other *replica
+	for _, r := range replicas {
+		if r != first {
+			other = r
+		}
+	}
+	if second := waitForLeader(t, []*replica{other}); second != other {
+		t.Errorf("Result fail. Received leader %s, Expected %s", second.elector.ID(), other.elector.ID())
+	}
+	if first.elector.IsLeader() {
+		t.Errorf("Result fail. Expected %s to step down when it couldn't renew the lease", first.elector.ID())
+	}
+	if m := maxActive.Load(); m != 1 {
+		t.Errorf("Result fail. Received %d leaders at once, Expected %d", m, 1)
+	}
+
+	// Once the old leader can reach the store again it waits its turn
+	s.setDown(first.elector.ID(), false)
+	time.Sleep(100 * time.Millisecond)
+	if first.elector.IsLeader() || !other.elector.IsLeader() {
+		t.Errorf("Result fail. Expected %s to keep the lease", other.elector.ID())
+	}
+}
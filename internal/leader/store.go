@@ -0,0 +1,19 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	leaderleases "github.com/chia-network/ecosystem-activity/internal/db/leader_leases"
+)
+
+// dbStore is the store backed by the leader_leases table
+type dbStore struct{}
+
+func (dbStore) tryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	return leaderleases.TryAcquire(ctx, name, holder, ttl)
+}
+
+func (dbStore) release(ctx context.Context, name, holder string) error {
+	return leaderleases.Release(ctx, name, holder)
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Structured field names shared by every package, so a log aggregator can filter on the same field wherever a line was logged
+const (
+	FieldRunID      = "run_id"      // The collector_runs row of the collector pass
+	FieldOwner      = "owner"       // The owner of a GitHub repo
+	FieldRepo       = "repo"        // The name of a GitHub repo, without its owner
+	FieldSHA        = "sha"         // A commit SHA
+	FieldPage       = "page"        // The page of a paginated GitHub request, from 1
+	FieldDurationMs = "duration_ms" // How long something took, in milliseconds
+	FieldErrorKind  = "error_kind"  // The kind of a collection error, one of the kinds in the repo_collection_errors table
+)
+
+// The supported log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// SetFormat sets the format of the standard logger, one of text or json
+func SetFormat(format string) error {
+	switch format {
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unsupported log format \"%s\", expected text or json", format)
+	}
+	return nil
+}
+
+type entryKey struct{}
+
+// WithFields returns a copy of ctx carrying fields in addition to any it already carries, for FromContext to log with
+func WithFields(ctx context.Context, fields log.Fields) context.Context {
+	return context.WithValue(ctx, entryKey{}, FromContext(ctx).WithFields(fields))
+}
+
+// FromContext returns a logger for the fields carried by ctx, or the standard logger if it doesn't carry any
+func FromContext(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(entryKey{}).(*log.Entry); ok {
+		return entry
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// Since returns the duration_ms field for the time since start
+func Since(start time.Time) log.Fields {
+	return log.Fields{FieldDurationMs: time.Since(start).Milliseconds()}
+}
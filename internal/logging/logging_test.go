@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestWithFields(t *testing.T) {
+	ctx := WithFields(context.Background(), log.Fields{FieldRunID: 7})
+	repoCtx := WithFields(ctx, log.Fields{FieldOwner: "Chia-Network", FieldRepo: "chia-blockchain"})
+
+	expected := log.Fields{FieldRunID: 7, FieldOwner: "Chia-Network", FieldRepo: "chia-blockchain"}
+	result := FromContext(repoCtx).Data
+	if len(result) != len(expected) {
+		t.Fatalf("Result fail. Received %v, Expected %v", result, expected)
+	}
+	for k, v := range expected {
+		if result[k] != v {
+			t.Errorf("Result fail for %s. Received %v, Expected %v", k, result[k], v)
+		}
+	}
+
+	// The parent context keeps only its own fields
+	if result := FromContext(ctx).Data; len(result) != 1 {
+		t.Errorf("Result fail. Received %v, Expected only %s", result, FieldRunID)
+	}
+	if result := FromContext(context.Background()).Data; len(result) != 0 {
+		t.Errorf("Result fail. Received %v, Expected no fields", result)
+	}
+}
+
+func TestSetFormatJSON(t *testing.T) {
+	std := log.StandardLogger()
+	out, formatter := std.Out, std.Formatter
+	t.Cleanup(func() {
+		std.SetOutput(out)
+		std.SetFormatter(formatter)
+	})
+
+	err := SetFormat(FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	std.SetOutput(&buf)
+	ctx := WithFields(context.Background(), log.Fields{FieldRunID: 7, FieldRepo: "chia-blockchain"})
+	FromContext(ctx).WithFields(log.Fields{FieldPage: 2}).Warn("page failed")
+
+	var line map[string]any
+	err = json.Unmarshal(buf.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("Result fail. Received %q, Expected a JSON line: %v", buf.String(), err)
+	}
+	expected := map[string]any{FieldRunID: 7.0, FieldRepo: "chia-blockchain", FieldPage: 2.0, "msg": "page failed", "level": "warning"}
+	for k, v := range expected {
+		if line[k] != v {
+			t.Errorf("Result fail for %s. Received %v, Expected %v", k, line[k], v)
+		}
+	}
+
+	if err := SetFormat("yaml"); err == nil {
+		t.Errorf("Result fail. Received no error, Expected one for an unsupported format")
+	}
+}
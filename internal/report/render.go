@@ -0,0 +1,62 @@
+package report
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	texttemplate "text/template"
+)
+
+// The formats a report can be rendered in
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// templateFuncs are shared by the Markdown and HTML templates
+var templateFuncs = map[string]any{
+	// signed formats a change with its sign, ie. "+3"
+	"signed": func(n int) string {
+		if n > 0 {
+			return fmt.Sprintf("+%d", n)
+		}
+		return fmt.Sprintf("%d", n)
+	},
+	// percent formats a metric's percent change, or a dash when there was nothing to compare to
+	"percent": func(m Metric) string {
+		if m.Previous == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%+.1f%%", m.PercentChange)
+	},
+}
+
+// Render writes a report to w in one of the report formats. HTML reports are a single self-contained file
+func Render(w io.Writer, r Report, format string) error {
+	switch format {
+	case FormatMarkdown:
+		t, err := texttemplate.New("report.md.tmpl").Funcs(templateFuncs).ParseFS(templates, "templates/report.md.tmpl")
+		if err != nil {
+			return fmt.Errorf("error parsing Markdown report template: %v", err)
+		}
+		return t.Execute(w, r)
+	case FormatHTML:
+		t, err := htmltemplate.New("report.html.tmpl").Funcs(templateFuncs).ParseFS(templates, "templates/report.html.tmpl")
+		if err != nil {
+			return fmt.Errorf("error parsing HTML report template: %v", err)
+		}
+		return t.Execute(w, r)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	default:
+		return fmt.Errorf("unsupported report format \"%s\", expected one of md, html, or json", format)
+	}
+}
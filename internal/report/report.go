@@ -0,0 +1,201 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+)
+
+// topLimit is the number of repos listed in each of the report's top repo tables
+const topLimit = 10
+
+// periodLayout is the format of a report period, a calendar month
+const periodLayout = "2006-01"
+
+// Report is a summary of ecosystem activity in one calendar month, compared to the month before it
+type Report struct {
+	Period      string         `json:"period"` // The month the report covers, ie. "2026-09"
+	Previous    string         `json:"previous_period"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Metrics     []Metric       `json:"metrics"`
+	TopRepos    []RepoActivity `json:"top_repos"`     // The repos with the most commits in the period
+	TopNewRepos []RepoActivity `json:"top_new_repos"` // The repos with the most commits in the period, of those whose first commit was in the period
+}
+
+// Metric is one headline number of a report, with its month-over-month change
+type Metric struct {
+	Name          string  `json:"name"`
+	Current       int     `json:"current"`
+	Previous      int     `json:"previous"`
+	Change        int     `json:"change"`
+	PercentChange float64 `json:"percent_change"` // 0 when the previous value was 0
+}
+
+// RepoActivity is the activity of one repo in a report's period
+type RepoActivity struct {
+	Owner        string `json:"owner"`
+	Repo         string `json:"repo"`
+	Commits      int    `json:"commits"`
+	Contributors int    `json:"contributors"`
+}
+
+// FullName returns the repo's owner/repo name
+func (r RepoActivity) FullName() string {
+	return fmt.Sprintf("%s/%s", r.Owner, r.Repo)
+}
+
+// ParsePeriod parses a report period in YYYY-MM format. An empty period is the last full month before now
+func ParsePeriod(period string, now time.Time) (time.Time, error) {
+	if period == "" {
+		return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	t, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing report period \"%s\", expected YYYY-MM: %v", period, err)
+	}
+	return t, nil
+}
+
+// Build generates the report for the month starting at period from the commits and repos tables.
+// Contributors are new in the month of their first commit, and returning when they commit after going churnDays without a commit.
+// Inherited commits are left out of both
+func Build(ctx context.Context, period time.Time, churnDays int) (Report, error) {
+	previous := period.AddDate(0, -1, 0)
+	cmts, err := commits.GetRowsBetween(ctx, previous.AddDate(0, 0, -churnDays), period.AddDate(0, 1, 0))
+	if err != nil {
+		return Report{}, err
+	}
+	firstCommits, err := commits.GetFirstCommitDatesByUser(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	repoRows, err := repos.GetAllRows(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	return build(period, cmts, firstCommits, repoRows, churnDays, time.Now().UTC()), nil
+}
+
+// monthStats are the headline numbers for one month
+type monthStats struct {
+	activeDevelopers, newContributors, returningContributors, commits, activeRepos int
+}
+
+// build generates the report for the month starting at period. cmts must cover at least the previous month and the churnDays before it,
+// and firstCommits holds the date of each user's first commit by user ID
+func build(period time.Time, cmts []commits.Commit, firstCommits map[int]time.Time, repoRows []repos.Repo, churnDays int, now time.Time) Report {
+	previous := period.AddDate(0, -1, 0)
+	current := stats(period, cmts, firstCommits, churnDays)
+	prior := stats(previous, cmts, firstCommits, churnDays)
+
+	r := Report{
+		Period:      period.Format(periodLayout),
+		Previous:    previous.Format(periodLayout),
+		GeneratedAt: now,
+		Metrics: []Metric{
+			metric("Monthly active developers", current.activeDevelopers, prior.activeDevelopers),
+			metric("New contributors", current.newContributors, prior.newContributors),
+			metric("Returning contributors", current.returningContributors, prior.returningContributors),
+			metric("Commits", current.commits, prior.commits),
+			metric("Active repos", current.activeRepos, prior.activeRepos),
+		},
+	}
+
+	// Activity per repo in the period
+	end := period.AddDate(0, 1, 0)
+	commitsByRepo := make(map[int]int)
+	authorsByRepo := make(map[int]map[int]bool)
+	for _, c := range cmts {
+		if c.Date.Before(period) || !c.Date.Before(end) {
+			continue
+		}
+		commitsByRepo[c.RepoID]++
+		if authorsByRepo[c.RepoID] == nil {
+			authorsByRepo[c.RepoID] = make(map[int]bool)
+		}
+		authorsByRepo[c.RepoID][c.UserID] = true
+	}
+	var all, newRepos []RepoActivity
+	for _, repo := range repoRows {
+		if commitsByRepo[repo.ID] == 0 {
+			continue
+		}
+		a := RepoActivity{Owner: repo.Owner, Repo: repo.Repo, Commits: commitsByRepo[repo.ID], Contributors: len(authorsByRepo[repo.ID])}
+		all = append(all, a)
+		if !repo.FirstCommit.Before(period) && repo.FirstCommit.Before(end) {
+			newRepos = append(newRepos, a)
+		}
+	}
+	r.TopRepos = topRepos(all)
+	r.TopNewRepos = topRepos(newRepos)
+	return r
+}
+
+// stats computes the headline numbers for the month starting at month
+func stats(month time.Time, cmts []commits.Commit, firstCommits map[int]time.Time, churnDays int) monthStats {
+	end := month.AddDate(0, 1, 0)
+	lookback := month.AddDate(0, 0, -churnDays)
+
+	var s monthStats
+	active := make(map[int]bool)
+	recentlyActive := make(map[int]bool)
+	activeRepos := make(map[int]bool)
+	for _, c := range cmts {
+		switch {
+		case !c.Date.Before(month) && c.Date.Before(end):
+			s.commits++
+			active[c.UserID] = true
+			activeRepos[c.RepoID] = true
+		case !c.Date.Before(lookback) && c.Date.Before(month):
+			recentlyActive[c.UserID] = true
+		}
+	}
+	s.activeDevelopers = len(active)
+	s.activeRepos = len(activeRepos)
+
+	for userID, first := range firstCommits {
+		if !first.Before(month) && first.Before(end) {
+			s.newContributors++
+			continue
+		}
+		// Committed this month after going at least churnDays without a commit
+		if active[userID] && first.Before(lookback) && !recentlyActive[userID] {
+			s.returningContributors++
+		}
+	}
+	return s
+}
+
+func metric(name string, current int, previous int) Metric {
+	m := Metric{Name: name, Current: current, Previous: previous, Change: current - previous}
+	if previous != 0 {
+		m.PercentChange = float64(current-previous) / float64(previous) * 100
+	}
+	return m
+}
+
+// topRepos sorts repos by commits, then contributors, then name, and keeps the first topLimit
+func topRepos(activity []RepoActivity) []RepoActivity {
+	sort.Slice(activity, func(i, j int) bool {
+		a, b := activity[i], activity[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		if a.Contributors != b.Contributors {
+			return a.Contributors > b.Contributors
+		}
+		return strings.ToLower(a.FullName()) < strings.ToLower(b.FullName())
+	})
+	if len(activity) > topLimit {
+		activity = activity[:topLimit]
+	}
+	if activity == nil {
+		activity = []RepoActivity{}
+	}
+	return activity
+}
@@ -0,0 +1,133 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+)
+
+// testReport builds a report for September 2026 from a small set of users, repos, and commits
+func testReport() Report {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2026, month, d, 12, 0, 0, 0, time.UTC)
+	}
+	firstCommits := map[int]time.Time{
+		1: day(time.January, 5),
+		2: day(time.August, 10),
+		3: day(time.September, 2),
+		// Last committed in March, so September's commit is a return
+		4: day(time.February, 1),
+	}
+	repoRows := []repos.Repo{
+		{ID: 1, Owner: "Chia-Network", Repo: "chia-blockchain", FirstCommit: day(time.January, 1)},
+		{ID: 2, Owner: "Chia-Network", Repo: "go-chia-libs", FirstCommit: day(time.February, 1)},
+		{ID: 3, Owner: "someone", Repo: "new-tool", FirstCommit: day(time.September, 2)},
+	}
+	cmts := []commits.Commit{
+		{RepoID: 2, UserID: 4, Date: day(time.March, 30)},
+		{RepoID: 1, UserID: 1, Date: day(time.July, 15)},
+		{RepoID: 1, UserID: 1, Date: day(time.August, 1)},
+		{RepoID: 1, UserID: 2, Date: day(time.August, 10)},
+		{RepoID: 1, UserID: 1, Date: day(time.September, 1)},
+		{RepoID: 1, UserID: 1, Date: day(time.September, 3)},
+		{RepoID: 1, UserID: 2, Date: day(time.September, 4)},
+		{RepoID: 3, UserID: 3, Date: day(time.September, 2)},
+		{RepoID: 2, UserID: 4, Date: day(time.September, 20)},
+		{RepoID: 1, UserID: 1, Date: day(time.October, 1)},
+	}
+	period := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	return build(period, cmts, firstCommits, repoRows, 90, time.Date(2026, time.October, 2, 9, 30, 0, 0, time.UTC))
+}
+
+func TestBuild(t *testing.T) {
+	r := testReport()
+
+	expect := []Metric{
+		{Name: "Monthly active developers", Current: 4, Previous: 2, Change: 2, PercentChange: 100},
+		{Name: "New contributors", Current: 1, Previous: 1, Change: 0},
+		{Name: "Returning contributors", Current: 1, Previous: 0, Change: 1},
+		{Name: "Commits", Current: 5, Previous: 2, Change: 3, PercentChange: 150},
+		{Name: "Active repos", Current: 3, Previous: 1, Change: 2, PercentChange: 200},
+	}
+	if !reflect.DeepEqual(r.Metrics, expect) {
+		t.Errorf("Result fail. Received %+v, Expected %+v", r.Metrics, expect)
+	}
+	if len(r.TopRepos) != 3 || r.TopRepos[0].FullName() != "Chia-Network/chia-blockchain" || r.TopRepos[0].Commits != 3 || r.TopRepos[0].Contributors != 2 {
+		t.Errorf("Result fail. Received top repos %+v", r.TopRepos)
+	}
+	if len(r.TopNewRepos) != 1 || r.TopNewRepos[0].FullName() != "someone/new-tool" {
+		t.Errorf("Result fail. Received top new repos %+v", r.TopNewRepos)
+	}
+}
+
+func TestStatsFirstCommits(t *testing.T) {
+	month := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	cmts := []commits.Commit{{RepoID: 5, UserID: 5, Date: month.AddDate(0, 0, 4)}}
+
+	// A user is new in the month of their own first commit, even when inherited commits backdate their first_commit in the users table
+	s := stats(month, cmts, map[int]time.Time{5: month.AddDate(0, 0, 4)}, 90)
+	if s.newContributors != 1 || s.returningContributors != 0 {
+		t.Errorf("Result fail. Received %+v, Expected 1 new contributor", s)
+	}
+	// and returning when their own first commit was long before
+	s = stats(month, cmts, map[int]time.Time{5: time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)}, 90)
+	if s.newContributors != 0 || s.returningContributors != 1 {
+		t.Errorf("Result fail. Received %+v, Expected 1 returning contributor", s)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	err := Render(&buf, testReport(), FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := os.ReadFile(filepath.Join("testdata", "report.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(expect) {
+		t.Errorf("Result fail. Received:\n%s\nExpected:\n%s", buf.String(), expect)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	err := Render(&buf, testReport(), FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	// Self-contained, so nothing is loaded from elsewhere
+	for _, external := range []string{"<link", "<script", "src=", "@import"} {
+		if strings.Contains(html, external) {
+			t.Errorf("Result fail. HTML report references an external resource with %s", external)
+		}
+	}
+	if !strings.Contains(html, `class="num up"`) || !strings.Contains(html, "someone/new-tool") {
+		t.Errorf("Result fail. Received:\n%s", html)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC)
+	period, err := ParsePeriod("", now)
+	if err != nil || !period.Equal(time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Result fail. Received %v %v, Expected the last full month", period, err)
+	}
+	period, err = ParsePeriod("2026-09", now)
+	if err != nil || !period.Equal(time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Result fail. Received %v %v, Expected 2026-09-01", period, err)
+	}
+	_, err = ParsePeriod("September", now)
+	if err == nil {
+		t.Error("Result fail. Expected an error for a malformed period")
+	}
+}
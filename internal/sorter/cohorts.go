@@ -0,0 +1,111 @@
+package sorter
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/cohorts"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// RunCohorts rebuilds the cohort_retention and churn_events tables from the days each user committed on
+func RunCohorts(ctx context.Context, retention config.Retention) {
+	log.Info("Running the cohort analysis for the cohort_retention and churn_events tables")
+	start := time.Now()
+
+	days, err := commits.GetActiveDaysByUser(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now().UTC()
+	rows := cohortRetention(days, now)
+	var events []cohorts.ChurnEvent
+	for userID, d := range days {
+		events = append(events, churnEvents(userID, d, now, retention.WithDefaults().ChurnDays)...)
+	}
+
+	err = cohorts.ReplaceAllRecords(ctx, rows, events)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	log.WithFields(logging.Since(start)).Infof("Computed retention for %d cohort months and %d churn events for %d users", len(rows), len(events), len(days))
+}
+
+// cohortRetention groups users by the month of their first commit, and counts the users of each cohort who committed in each month from then through the month containing now.
+// Each user's days must be in ascending order
+func cohortRetention(days map[int][]time.Time, now time.Time) []cohorts.Retention {
+	sizes := make(map[time.Time]int)
+	active := make(map[time.Time]map[int]int)
+	for _, d := range days {
+		if len(d) == 0 {
+			continue
+		}
+		cohort := monthStart(d[0])
+		sizes[cohort]++
+		if active[cohort] == nil {
+			active[cohort] = make(map[int]int)
+		}
+		seen := make(map[int]bool)
+		for _, day := range d {
+			n := monthsBetween(cohort, day)
+			if !seen[n] {
+				seen[n] = true
+				active[cohort][n]++
+			}
+		}
+	}
+
+	cohortMonths := make([]time.Time, 0, len(sizes))
+	for c := range sizes {
+		cohortMonths = append(cohortMonths, c)
+	}
+	sort.Slice(cohortMonths, func(i, j int) bool { return cohortMonths[i].Before(cohortMonths[j]) })
+
+	var rows []cohorts.Retention
+	for _, c := range cohortMonths {
+		for n := 0; n <= monthsBetween(c, now); n++ {
+			rows = append(rows, cohorts.Retention{
+				CohortMonth: c,
+				MonthsSince: n,
+				CohortSize:  sizes[c],
+				ActiveUsers: active[c][n],
+			})
+		}
+	}
+	return rows
+}
+
+// churnEvents finds the days a user churned, churnDays after a commit that wasn't followed by another within churnDays, and the days they returned with a commit after churning.
+// days must be in ascending order
+func churnEvents(userID int, days []time.Time, now time.Time, churnDays int) []cohorts.ChurnEvent {
+	var events []cohorts.ChurnEvent
+	for i, d := range days {
+		churnedAt := d.AddDate(0, 0, churnDays)
+		if i+1 < len(days) {
+			if days[i+1].After(churnedAt) {
+				events = append(events,
+					cohorts.ChurnEvent{UserID: userID, Event: cohorts.EventChurned, Date: churnedAt},
+					cohorts.ChurnEvent{UserID: userID, Event: cohorts.EventReturned, Date: days[i+1]},
+				)
+			}
+			continue
+		}
+		if now.After(churnedAt) {
+			events = append(events, cohorts.ChurnEvent{UserID: userID, Event: cohorts.EventChurned, Date: churnedAt})
+		}
+	}
+	return events
+}
+
+// monthsBetween returns the number of calendar months from a cohort month to the month of t
+func monthsBetween(cohort time.Time, t time.Time) int {
+	return (t.Year()-cohort.Year())*12 + int(t.Month()) - int(cohort.Month())
+}
@@ -0,0 +1,59 @@
+package sorter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/cohorts"
+)
+
+func TestCohortRetention(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	days := map[int][]time.Time{
+		1: {day(time.January, 3), day(time.January, 20), day(time.March, 1)},
+		2: {day(time.January, 15)},
+		3: {day(time.February, 2), day(time.March, 9)},
+	}
+	now := day(time.March, 20)
+
+	result := cohortRetention(days, now)
+	expect := []cohorts.Retention{
+		{CohortMonth: day(time.January, 1), MonthsSince: 0, CohortSize: 2, ActiveUsers: 2},
+		{CohortMonth: day(time.January, 1), MonthsSince: 1, CohortSize: 2, ActiveUsers: 0},
+		{CohortMonth: day(time.January, 1), MonthsSince: 2, CohortSize: 2, ActiveUsers: 1},
+		{CohortMonth: day(time.February, 1), MonthsSince: 0, CohortSize: 1, ActiveUsers: 1},
+		{CohortMonth: day(time.February, 1), MonthsSince: 1, CohortSize: 1, ActiveUsers: 1},
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf("Result fail. Received %+v, Expected %+v", result, expect)
+	}
+	if share := result[2].Share(); share != 50 {
+		t.Errorf("Result fail. Received share %v, Expected 50", share)
+	}
+}
+
+func TestChurnEvents(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	days := []time.Time{day(time.January, 1), day(time.January, 31), day(time.June, 1), day(time.July, 1)}
+
+	result := churnEvents(4, days, day(time.December, 1), 90)
+	expect := []cohorts.ChurnEvent{
+		{UserID: 4, Event: cohorts.EventChurned, Date: day(time.May, 1)},
+		{UserID: 4, Event: cohorts.EventReturned, Date: day(time.June, 1)},
+		{UserID: 4, Event: cohorts.EventChurned, Date: day(time.September, 29)},
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf("Result fail. Received %+v, Expected %+v", result, expect)
+	}
+
+	// Still within the window after the last commit, so not churned yet
+	result = churnEvents(4, days, day(time.August, 1), 90)
+	if len(result) != 2 {
+		t.Errorf("Result fail. Received %+v, Expected only the first churn and return", result)
+	}
+}
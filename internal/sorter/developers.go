@@ -0,0 +1,125 @@
+package sorter
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	developermonths "github.com/chia-network/ecosystem-activity/internal/db/developer_months"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// periodDays is the length of the "months" a rolling window is split into, so every month counts the same number of days
+const periodDays = 28
+
+// RunDeveloperMonths rebuilds the developer_months table, classifying every user in every month from their first commit through the current month
+// by the distinct days they committed on in the rolling window ending with that month. Users are classified again for each tag by their commits
+// to repos with the tag alone, so a tag's breakdown follows the same rules as the breakdown across every repo
+func RunDeveloperMonths(ctx context.Context, thresholds config.DeveloperClassification) {
+	log.Info("Running the developer classification for the developer_months table")
+	start := time.Now()
+
+	days, err := commits.GetActiveDaysByUser(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	tagDays, err := commits.GetActiveDaysByTagAndUser(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	now := time.Now().UTC()
+	rows := classifyMonths("", days, now, thresholds)
+	for tag, d := range tagDays {
+		rows = append(rows, classifyMonths(tag, d, now, thresholds)...)
+	}
+
+	err = developermonths.ReplaceAllRecords(ctx, rows)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	log.WithFields(logging.Since(start)).Infof("Classified %d developer months for %d users and %d tags", len(rows), len(days), len(tagDays))
+}
+
+// classifyMonths classifies every user in days, keyed by user ID, for each of their months, recording the tag the days are for on every row
+func classifyMonths(tag string, days map[int][]time.Time, now time.Time, thresholds config.DeveloperClassification) []developermonths.DeveloperMonth {
+	var rows []developermonths.DeveloperMonth
+	for _, userID := range slices.Sorted(maps.Keys(days)) {
+		for _, m := range classifyUserMonths(userID, days[userID], now, thresholds) {
+			m.Tag = tag
+			rows = append(rows, m)
+		}
+	}
+	return rows
+}
+
+// classifyUserMonths classifies one user for each month from the month of their first active day through the month containing now.
+// days must be in ascending order. The window for the current month ends at now rather than the end of the month.
+// Months without any activity in their window are left out
+func classifyUserMonths(userID int, days []time.Time, now time.Time, thresholds config.DeveloperClassification) []developermonths.DeveloperMonth {
+	if len(days) == 0 {
+		return nil
+	}
+	thresholds = thresholds.WithDefaults()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	var months []developermonths.DeveloperMonth
+	for month := monthStart(days[0]); month.Before(tomorrow); month = month.AddDate(0, 1, 0) {
+		end := month.AddDate(0, 1, 0)
+		if end.After(tomorrow) {
+			end = tomorrow
+		}
+		start := end.AddDate(0, 0, -thresholds.WindowDays)
+
+		// Active days in the window, [start, end)
+		from := sort.Search(len(days), func(i int) bool { return !days[i].Before(start) })
+		to := sort.Search(len(days), func(i int) bool { return !days[i].Before(end) })
+		activeDays := to - from
+		if activeDays == 0 {
+			continue
+		}
+		periods := make(map[int]bool)
+		for _, d := range days[from:to] {
+			periods[int(end.Sub(d).Hours()/24)/periodDays] = true
+		}
+
+		months = append(months, developermonths.DeveloperMonth{
+			UserID:         userID,
+			Month:          month,
+			ActiveDays:     activeDays,
+			ActiveMonths:   len(periods),
+			Classification: Classify(activeDays, len(periods), thresholds),
+		})
+	}
+	return months
+}
+
+// Classify returns the classification of a developer with a number of distinct active days, spread over a number of 28 day periods, in a rolling window.
+// Developers averaging at least the full-time days per 28 days are full-time, developers below that who were active in no more than the one-time
+// number of periods are one-time, and everyone else is part-time
+func Classify(activeDays int, activeMonths int, thresholds config.DeveloperClassification) string {
+	thresholds = thresholds.WithDefaults()
+	perMonth := float64(activeDays) * periodDays / float64(thresholds.WindowDays)
+	switch {
+	case perMonth >= float64(thresholds.FullTimeDays):
+		return developermonths.ClassificationFullTime
+	case activeMonths <= thresholds.OneTimeMaxMonths:
+		return developermonths.ClassificationOneTime
+	default:
+		return developermonths.ClassificationPartTime
+	}
+}
+
+// monthStart returns midnight UTC on the first day of a time's month
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,105 @@
+package sorter
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	developermonths "github.com/chia-network/ecosystem-activity/internal/db/developer_months"
+)
+
+func TestClassify(t *testing.T) {
+	thresholds := config.DeveloperClassification{}
+	tests := []struct {
+		activeDays   int
+		activeMonths int
+		expect       string
+	}{
+		{30, 3, developermonths.ClassificationFullTime},
+		{12, 1, developermonths.ClassificationOneTime},
+		{29, 3, developermonths.ClassificationPartTime},
+		{2, 2, developermonths.ClassificationPartTime},
+		{1, 1, developermonths.ClassificationOneTime},
+	}
+	for _, test := range tests {
+		result := Classify(test.activeDays, test.activeMonths, thresholds)
+		if result != test.expect {
+			t.Errorf("Result fail for %d days in %d months. Received %s, Expected %s", test.activeDays, test.activeMonths, result, test.expect)
+		}
+	}
+
+	// A shorter window needs fewer active days to average the same days per month
+	result := Classify(10, 1, config.DeveloperClassification{WindowDays: 28})
+	if result != developermonths.ClassificationFullTime {
+		t.Errorf("Result fail for a 28 day window. Received %s, Expected %s", result, developermonths.ClassificationFullTime)
+	}
+}
+
+func TestClassifyUserMonths(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	// One day in January, then a day in every period through March
+	days := []time.Time{day(time.January, 10), day(time.February, 1), day(time.February, 20), day(time.March, 15)}
+	now := time.Date(2023, time.May, 15, 12, 0, 0, 0, time.UTC)
+
+	months := classifyUserMonths(7, days, now, config.DeveloperClassification{})
+	expect := []developermonths.DeveloperMonth{
+		{UserID: 7, Month: day(time.January, 1), ActiveDays: 1, ActiveMonths: 1, Classification: developermonths.ClassificationOneTime},
+		{UserID: 7, Month: day(time.February, 1), ActiveDays: 3, ActiveMonths: 2, Classification: developermonths.ClassificationPartTime},
+		{UserID: 7, Month: day(time.March, 1), ActiveDays: 4, ActiveMonths: 3, Classification: developermonths.ClassificationPartTime},
+		{UserID: 7, Month: day(time.April, 1), ActiveDays: 2, ActiveMonths: 2, Classification: developermonths.ClassificationPartTime},
+		// The current month's window ends today, so it reaches back to February 21st
+		{UserID: 7, Month: day(time.May, 1), ActiveDays: 1, ActiveMonths: 1, Classification: developermonths.ClassificationOneTime},
+	}
+	if len(months) != len(expect) {
+		t.Fatalf("Result fail. Received %+v, Expected %+v", months, expect)
+	}
+	for i := range expect {
+		if months[i] != expect[i] {
+			t.Errorf("Result fail for month %d. Received %+v, Expected %+v", i, months[i], expect[i])
+		}
+	}
+}
+
+func TestClassifyMonthsByTag(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	now := time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)
+	// User 7 committed to a wallet repo in January only, and to other repos every week. User 8 only committed to the wallet repo
+	all := map[int][]time.Time{
+		7: {day(time.January, 3), day(time.January, 10), day(time.January, 17), day(time.February, 1), day(time.February, 8), day(time.March, 1)},
+		8: {day(time.March, 2)},
+	}
+	wallets := map[int][]time.Time{
+		7: {day(time.January, 10)},
+		8: {day(time.March, 2)},
+	}
+
+	rows := classifyMonths("wallets", wallets, now, config.DeveloperClassification{})
+	expect := []developermonths.DeveloperMonth{
+		{Tag: "wallets", UserID: 7, Month: day(time.January, 1), ActiveDays: 1, ActiveMonths: 1, Classification: developermonths.ClassificationOneTime},
+		{Tag: "wallets", UserID: 7, Month: day(time.February, 1), ActiveDays: 1, ActiveMonths: 1, Classification: developermonths.ClassificationOneTime},
+		{Tag: "wallets", UserID: 7, Month: day(time.March, 1), ActiveDays: 1, ActiveMonths: 1, Classification: developermonths.ClassificationOneTime},
+		{Tag: "wallets", UserID: 8, Month: day(time.March, 1), ActiveDays: 1, ActiveMonths: 1, Classification: developermonths.ClassificationOneTime},
+	}
+	if len(rows) != len(expect) {
+		t.Fatalf("Result fail. Received %+v, Expected %+v", rows, expect)
+	}
+	for i := range expect {
+		if rows[i] != expect[i] {
+			t.Errorf("Result fail for row %d. Received %+v, Expected %+v", i, rows[i], expect[i])
+		}
+	}
+
+	// Across every repo, user 7 is classified by all of their commits instead
+	untagged := classifyMonths("", all, now, config.DeveloperClassification{})
+	march := slices.IndexFunc(untagged, func(r developermonths.DeveloperMonth) bool {
+		return r.UserID == 7 && r.Month.Equal(day(time.March, 1))
+	})
+	if march == -1 || untagged[march].Tag != "" || untagged[march].Classification != developermonths.ClassificationPartTime {
+		t.Errorf("Result fail. Received %+v, Expected an untagged part-time row for user 7 in March", untagged)
+	}
+}
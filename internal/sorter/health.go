@@ -0,0 +1,155 @@
+package sorter
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	repohealth "github.com/chia-network/ecosystem-activity/internal/db/repo_health"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// RunRepoHealth computes the health of every repo from its trailing year of commits, storing today's snapshot in the repo_health table
+func RunRepoHealth(ctx context.Context, settings config.RepoHealth) {
+	log.Info("Running the repo health scoring for the repo_health table")
+	start := time.Now()
+
+	repoRows, err := repos.GetAllRows(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	now := time.Now().UTC()
+	cmts, err := commits.GetRowsBetween(ctx, now.AddDate(0, 0, -365), now)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	lastCommits, err := commits.GetLastCommitDatesByRepo(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	byRepo := make(map[int][]commits.Commit)
+	for _, c := range cmts {
+		byRepo[c.RepoID] = append(byRepo[c.RepoID], c)
+	}
+
+	rows := make([]repohealth.RepoHealth, 0, len(repoRows))
+	var abandoned int
+	for _, r := range repoRows {
+		h := computeRepoHealth(r, byRepo[r.ID], lastCommits[r.ID], now, settings)
+		if h.Abandoned {
+			abandoned++
+		}
+		rows = append(rows, h)
+	}
+
+	err = repohealth.SetRecords(ctx, rows)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	log.WithFields(logging.Since(start)).Infof("Scored the health of %d repos, %d are abandoned", len(rows), abandoned)
+}
+
+// computeRepoHealth computes the health of a repo from its commits in the trailing 365 days before now, and the date of its newest commit,
+// which is zero when it has none. Inherited commits are left out of both, so a fork is only as recent as the work done on it
+func computeRepoHealth(r repos.Repo, cmts []commits.Commit, lastCommit time.Time, now time.Time, settings config.RepoHealth) repohealth.RepoHealth {
+	settings = settings.WithDefaults()
+	h := repohealth.RepoHealth{
+		RepoID:              r.ID,
+		Owner:               r.Owner,
+		Repo:                r.Repo,
+		SnapshotDate:        time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		DaysSinceLastCommit: -1,
+	}
+
+	recent := now.AddDate(0, 0, -90)
+	prior := now.AddDate(0, 0, -180)
+	year := now.AddDate(0, 0, -365)
+	authors90 := make(map[int]bool)
+	authorCommits := make(map[int]int)
+	for _, c := range cmts {
+		if c.Date.Before(year) || c.Date.After(now) {
+			continue
+		}
+		authorCommits[c.UserID]++
+		switch {
+		case !c.Date.Before(recent):
+			h.Commits90d++
+			authors90[c.UserID] = true
+		case !c.Date.Before(prior):
+			h.CommitsPrior90d++
+		}
+	}
+	h.Contributors90d = len(authors90)
+	h.Contributors365d = len(authorCommits)
+	h.BusFactor = busFactor(authorCommits)
+	h.CommitTrend = commitTrend(h.Commits90d, h.CommitsPrior90d)
+
+	if !lastCommit.IsZero() {
+		h.DaysSinceLastCommit = max(int(now.Sub(lastCommit).Hours()/24), 0)
+	}
+	// Repos that were collected without finding a single commit are as abandoned as it gets
+	h.Abandoned = h.DaysSinceLastCommit >= settings.AbandonedDays || (h.DaysSinceLastCommit < 0 && !r.ImportedThrough.IsZero())
+	h.Score = healthScore(h, settings)
+	return h
+}
+
+// busFactor returns the smallest number of authors whose commits add up to at least half of all commits
+func busFactor(authorCommits map[int]int) int {
+	counts := make([]int, 0, len(authorCommits))
+	var total int
+	for _, n := range authorCommits {
+		counts = append(counts, n)
+		total += n
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+
+	var covered int
+	for i, n := range counts {
+		covered += n
+		if covered*2 >= total {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// commitTrend returns the percent change from the prior period's commits to the recent period's. Growth from no commits counts as 100%
+func commitTrend(recent int, prior int) float64 {
+	if prior == 0 {
+		if recent > 0 {
+			return 100
+		}
+		return 0
+	}
+	return float64(recent-prior) / float64(prior) * 100
+}
+
+// healthScore combines the components of a repo's health into a weighted score out of 100
+func healthScore(h repohealth.RepoHealth, settings config.RepoHealth) float64 {
+	w := settings.Weights
+	total := w.Contributors + w.BusFactor + w.Recency + w.Trend
+	if total <= 0 {
+		return 0
+	}
+
+	contributors := math.Min(float64(h.Contributors90d)/float64(settings.ContributorsTarget), 1)
+	bus := math.Min(float64(h.BusFactor)/float64(settings.BusFactorTarget), 1)
+	var recency float64
+	if h.DaysSinceLastCommit >= 0 {
+		recency = math.Max(1-float64(h.DaysSinceLastCommit)/float64(settings.AbandonedDays), 0)
+	}
+	trend := (math.Max(math.Min(h.CommitTrend, 100), -100) + 100) / 200
+
+	score := (w.Contributors*contributors + w.BusFactor*bus + w.Recency*recency + w.Trend*trend) / total * 100
+	return math.Round(score*10) / 10
+}
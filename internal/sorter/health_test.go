@@ -0,0 +1,82 @@
+package sorter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/config"
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+)
+
+func TestBusFactor(t *testing.T) {
+	tests := []struct {
+		authorCommits map[int]int
+		expect        int
+	}{
+		{map[int]int{}, 0},
+		{map[int]int{1: 10}, 1},
+		{map[int]int{1: 50, 2: 30, 3: 20}, 1},
+		{map[int]int{1: 40, 2: 30, 3: 20, 4: 10}, 2},
+		{map[int]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1}, 3},
+	}
+	for _, test := range tests {
+		result := busFactor(test.authorCommits)
+		if result != test.expect {
+			t.Errorf("Result fail for %v. Received %d, Expected %d", test.authorCommits, result, test.expect)
+		}
+	}
+}
+
+func TestComputeRepoHealth(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time {
+		return now.AddDate(0, 0, -d)
+	}
+	cmts := []commits.Commit{
+		{RepoID: 1, UserID: 1, Date: daysAgo(5)},
+		{RepoID: 1, UserID: 1, Date: daysAgo(10)},
+		{RepoID: 1, UserID: 2, Date: daysAgo(20)},
+		{RepoID: 1, UserID: 3, Date: daysAgo(120)},
+		{RepoID: 1, UserID: 4, Date: daysAgo(300)},
+	}
+	r := repos.Repo{ID: 1, Owner: "Chia-Network", Repo: "chia-blockchain", LastCommit: daysAgo(5), ImportedThrough: now}
+
+	h := computeRepoHealth(r, cmts, daysAgo(5), now, config.RepoHealth{})
+	if h.Contributors90d != 2 || h.Contributors365d != 4 || h.BusFactor != 2 {
+		t.Errorf("Result fail. Received %+v, Expected 2 contributors in 90 days, 4 in 365, and a bus factor of 2", h)
+	}
+	if h.Commits90d != 3 || h.CommitsPrior90d != 1 || h.CommitTrend != 200 {
+		t.Errorf("Result fail. Received %+v, Expected 3 commits after 1, a 200%% trend", h)
+	}
+	if h.DaysSinceLastCommit != 5 || h.Abandoned {
+		t.Errorf("Result fail. Received %+v, Expected 5 days since the last commit and not abandoned", h)
+	}
+	// 0.3 * 2/5 + 0.25 * 2/3 + 0.3 * 360/365 + 0.15 * 1, out of 100
+	if h.Score != 73.3 {
+		t.Errorf("Result fail. Received score %v, Expected 73.3", h.Score)
+	}
+
+	stale := repos.Repo{ID: 2, LastCommit: daysAgo(400), ImportedThrough: now}
+	h = computeRepoHealth(stale, nil, daysAgo(400), now, config.RepoHealth{})
+	if !h.Abandoned || h.Score != 7.5 {
+		t.Errorf("Result fail. Received %+v, Expected an abandoned repo scoring 7.5 for its flat trend", h)
+	}
+
+	// A fork's last_commit includes the commits it inherited from its parent, which don't make it any less abandoned
+	fork := repos.Repo{ID: 3, LastCommit: daysAgo(2), ImportedThrough: now}
+	h = computeRepoHealth(fork, nil, daysAgo(400), now, config.RepoHealth{})
+	if h.DaysSinceLastCommit != 400 || !h.Abandoned {
+		t.Errorf("Result fail. Received %+v, Expected 400 days since the fork's own last commit and abandoned", h)
+	}
+	h = computeRepoHealth(fork, nil, time.Time{}, now, config.RepoHealth{})
+	if h.DaysSinceLastCommit != -1 || !h.Abandoned {
+		t.Errorf("Result fail. Received %+v, Expected a fork without commits of its own to be abandoned", h)
+	}
+
+	// Weighting only recency scores a repo by how recently it was committed to
+	h = computeRepoHealth(r, cmts, daysAgo(5), now, config.RepoHealth{Weights: config.HealthWeights{Recency: 1}, AbandonedDays: 100})
+	if h.Score != 95 {
+		t.Errorf("Result fail. Received score %v, Expected 95", h.Score)
+	}
+}
@@ -0,0 +1,118 @@
+package sorter
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
+)
+
+// RunInheritedCommits flags every commit whose SHA is also in a repo it was inherited from, such as the history a fork of chia-blockchain
+// carries from upstream, so it's only counted once in the repo that's most likely the original
+func RunInheritedCommits(ctx context.Context) {
+	log.Info("Running the inherited commit detection for the commits table")
+	start := time.Now()
+
+	lineage, err := repos.GetAllLineage(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	copies, err := commits.GetSHACopies(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	flag, unflag := inheritedChanges(copies, lineage)
+	err = commits.SetInheritedByIDs(ctx, flag, true)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	err = commits.SetInheritedByIDs(ctx, unflag, false)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	log.WithFields(logging.Since(start)).Infof("Flagged %d commits as inherited and cleared the flag on %d", len(flag), len(unflag))
+}
+
+// inheritedChanges decides which copy of each SHA is the original and returns the IDs of the commits to flag as inherited and to unflag.
+// copies must be ordered by SHA. The original is the copy in the repo with the fewest tracked forks above it, then the oldest repo,
+// then the repo with the earliest first commit, then the lowest repo ID
+func inheritedChanges(copies []commits.SHACopy, lineage []repos.Lineage) (flag []int, unflag []int) {
+	byRepo := make(map[int]repos.Lineage, len(lineage))
+	for _, l := range lineage {
+		byRepo[l.RepoID] = l
+	}
+	depths := make(map[int]int, len(lineage))
+	for _, l := range lineage {
+		depths[l.RepoID] = forkDepth(l.RepoID, byRepo, make(map[int]bool))
+	}
+
+	for start := 0; start < len(copies); {
+		end := start + 1
+		for end < len(copies) && copies[end].SHA == copies[start].SHA {
+			end++
+		}
+		group := copies[start:end]
+		start = end
+
+		original := 0
+		for i := 1; i < len(group); i++ {
+			if originalBefore(group[i], group[original], byRepo, depths) {
+				original = i
+			}
+		}
+		for i, c := range group {
+			inherited := i != original
+			if inherited && !c.Inherited {
+				flag = append(flag, c.ID)
+			} else if !inherited && c.Inherited {
+				unflag = append(unflag, c.ID)
+			}
+		}
+	}
+	return flag, unflag
+}
+
+// forkDepth returns the number of forks between a repo and the root of its history: 0 for a repo that isn't a fork,
+// and one more than its parent's depth for a fork, counting an untracked parent as a root
+func forkDepth(repoID int, byRepo map[int]repos.Lineage, seen map[int]bool) int {
+	l := byRepo[repoID]
+	if l.ForkParent == "" {
+		return 0
+	}
+	if _, ok := byRepo[l.ForkParentID]; !ok || seen[repoID] {
+		return 1
+	}
+	seen[repoID] = true
+	return 1 + forkDepth(l.ForkParentID, byRepo, seen)
+}
+
+// originalBefore reports whether copy a is more likely than copy b to be the original commit
+func originalBefore(a, b commits.SHACopy, byRepo map[int]repos.Lineage, depths map[int]int) bool {
+	if depths[a.RepoID] != depths[b.RepoID] {
+		return depths[a.RepoID] < depths[b.RepoID]
+	}
+	la, lb := byRepo[a.RepoID], byRepo[b.RepoID]
+	if !la.CreatedAt.Equal(lb.CreatedAt) {
+		// Repos whose metadata hasn't been refreshed yet have no creation date, and lose to any repo with one
+		if la.CreatedAt.IsZero() || lb.CreatedAt.IsZero() {
+			return lb.CreatedAt.IsZero()
+		}
+		return la.CreatedAt.Before(lb.CreatedAt)
+	}
+	if !la.FirstCommit.Equal(lb.FirstCommit) && !la.FirstCommit.IsZero() && !lb.FirstCommit.IsZero() {
+		return la.FirstCommit.Before(lb.FirstCommit)
+	}
+	if a.RepoID != b.RepoID {
+		return a.RepoID < b.RepoID
+	}
+	return a.ID < b.ID
+}
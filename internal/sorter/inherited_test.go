@@ -0,0 +1,61 @@
+package sorter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chia-network/ecosystem-activity/internal/db/commits"
+	"github.com/chia-network/ecosystem-activity/internal/db/repos"
+)
+
+func TestInheritedChanges(t *testing.T) {
+	date := func(year int) time.Time {
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	lineage := []repos.Lineage{
+		{RepoID: 1, CreatedAt: date(2019)},                                                              // chia-blockchain
+		{RepoID: 2, ForkParent: "Chia-Network/chia-blockchain", ForkParentID: 1},                        // a fork created before its metadata was refreshed
+		{RepoID: 3, ForkParent: "Flax-Network/flax-blockchain", ForkParentID: 2, CreatedAt: date(2018)}, // a fork of a fork
+		{RepoID: 4, CreatedAt: date(2021)},                                                              // a copy of chia-blockchain that isn't a GitHub fork
+		{RepoID: 5, ForkParent: "Untracked/upstream", CreatedAt: date(2020)},                            // a fork of an untracked repo
+	}
+	copies := []commits.SHACopy{
+		{ID: 10, RepoID: 1, SHA: "a"},
+		{ID: 20, RepoID: 2, SHA: "a"},
+		{ID: 30, RepoID: 3, SHA: "a"},
+		{ID: 40, RepoID: 4, SHA: "a", Inherited: true},
+		{ID: 21, RepoID: 2, SHA: "b", Inherited: true},
+		{ID: 31, RepoID: 3, SHA: "b"},
+		{ID: 41, RepoID: 4, SHA: "c", Inherited: true}, // the other copy was deleted
+		{ID: 42, RepoID: 4, SHA: "d"},
+		{ID: 51, RepoID: 5, SHA: "d"},
+	}
+
+	flag, unflag := inheritedChanges(copies, lineage)
+	if expect := []int{20, 30, 31, 51}; !reflect.DeepEqual(flag, expect) {
+		t.Errorf("Result fail. Received %v, Expected %v", flag, expect)
+	}
+	if expect := []int{21, 41}; !reflect.DeepEqual(unflag, expect) {
+		t.Errorf("Result fail. Received %v, Expected %v", unflag, expect)
+	}
+}
+
+func TestForkDepth(t *testing.T) {
+	byRepo := map[int]repos.Lineage{
+		1: {RepoID: 1},
+		2: {RepoID: 2, ForkParent: "a/1", ForkParentID: 1},
+		3: {RepoID: 3, ForkParent: "a/2", ForkParentID: 2},
+		4: {RepoID: 4, ForkParent: "a/untracked"},
+		5: {RepoID: 5, ForkParent: "a/6", ForkParentID: 6},
+		6: {RepoID: 6, ForkParent: "a/5", ForkParentID: 5},
+	}
+	// A cycle, which GitHub shouldn't produce, only has to terminate
+	tests := map[int]int{1: 0, 2: 1, 3: 2, 4: 1, 5: 3}
+	for repoID, expect := range tests {
+		result := forkDepth(repoID, byRepo, make(map[int]bool))
+		if result != expect {
+			t.Errorf("Result fail for repo %d. Received %d, Expected %d", repoID, result, expect)
+		}
+	}
+}
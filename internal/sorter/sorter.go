@@ -1,30 +1,54 @@
 package sorter
 
 import (
+	"context"
+	"time"
+
 	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/chia-network/ecosystem-activity/internal/config"
 	"github.com/chia-network/ecosystem-activity/internal/db/commits"
 	sortedcommits "github.com/chia-network/ecosystem-activity/internal/db/sorted_commits"
+	"github.com/chia-network/ecosystem-activity/internal/logging"
 )
 
-// Schedule creates a cron for refreshing the sorted commit table
-func Schedule(schedule string) {
+// Schedule creates a cron for flagging inherited commits and refreshing the sorted commit table, followed by the tables computed from the commits table,
+// returning it so it can be stopped. The jobs run with ctx, and the ones left once it's cancelled, ie. when this replica loses the leader lease, are skipped
+func Schedule(ctx context.Context, schedule string, cfg config.Config) *cron.Cron {
 	log.Infof("registering sorter cron with schedule \"%s\"", schedule)
 	c := cron.New()
-	_, err := c.AddFunc(schedule, RunSortedCommits)
+	_, err := c.AddFunc(schedule, func() {
+		jobs := []func(){
+			func() { RunInheritedCommits(ctx) },
+			func() { RunSortedCommits(ctx) },
+			func() { RunDeveloperMonths(ctx, cfg.DeveloperClassification) },
+			func() { RunCohorts(ctx, cfg.Retention) },
+			func() { RunRepoHealth(ctx, cfg.RepoHealth) },
+		}
+		for _, job := range jobs {
+			if ctx.Err() != nil {
+				log.Info("Sorter stopped part way through its jobs, the rest run on the next schedule")
+				return
+			}
+			job()
+		}
+	})
 	if err != nil {
 		log.Errorf("error encountered registering sorter cron: %v", err)
 	}
 	c.Start()
+	return c
 }
 
-// RunSortedCommits deletes all records in the sorted_commits table, restarts the auto incrementer, and adds all the commits in ascending order from the commits table
-func RunSortedCommits() {
+// RunSortedCommits replaces all records in the sorted_commits table with all the commits in ascending order from the commits table, in one transaction.
+// Commits inherited from another tracked repo are left out, like they are from the other tables computed from commits
+func RunSortedCommits(ctx context.Context) {
 	log.Info("Running the commit sorter for the sorted_commits table")
+	start := time.Now()
 
-	// Gather all commits in the commits table in ascending order
-	allCommitsAsc, err := commits.GetAllRowsAscending()
+	// Gather all commits in the commits table that aren't inherited, in ascending order
+	allCommitsAsc, err := commits.GetAllRowsAscending(ctx)
 	if err != nil {
 		log.Error(err)
 		return
@@ -32,25 +56,18 @@ func RunSortedCommits() {
 
 	log.Debugf("Found %d commits to add to the sorted_commits table", len(allCommitsAsc))
 
-	// Deletes all records in the sorted commits table
-	// This is obviously an operation that can't be reversed except with an import,
-	// the loop below should re-add the rows in the correct order
-	err = sortedcommits.ResetAllRecords()
-	if err != nil {
-		log.Error(err)
-		return
-	}
-
-	// Create each record in the ascending datetime order as returned by the commits table
-	for i, commit := range allCommitsAsc {
-		log.Debugf("Adding commit ID %d, iteration %d", commit.ID, i)
-		err = sortedcommits.SetNewRecord(sortedcommits.SortedCommit{
+	// Replaces all records in the sorted commits table, numbering them in the ascending datetime order as returned by the commits table
+	rows := make([]sortedcommits.SortedCommit, 0, len(allCommitsAsc))
+	for _, commit := range allCommitsAsc {
+		rows = append(rows, sortedcommits.SortedCommit{
 			CommitID: commit.ID,
 			Date:     commit.Date,
 		})
-		if err != nil {
-			log.Error(err)
-			return
-		}
 	}
+	err = sortedcommits.ReplaceAllRecords(ctx, rows)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	log.WithFields(logging.Since(start)).Infof("Sorted %d commits into the sorted_commits table", len(allCommitsAsc))
 }
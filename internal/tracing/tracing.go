@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer every span in this application is started with
+const instrumentationName = "github.com/chia-network/ecosystem-activity"
+
+// Options configures trace export
+type Options struct {
+	Endpoint    string // The OTLP/HTTP endpoint to export spans to, ie. http://otel-collector:4318 (default: disabled)
+	ServiceName string // The service.name resource attribute spans are exported with (default: ecosystem-activity)
+}
+
+// Init exports spans over OTLP/HTTP to the endpoint in opts, returning a function that flushes and stops the exporter.
+// Without an endpoint tracing stays disabled, and spans are started against the no-op provider OpenTelemetry uses by default
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = "ecosystem-activity"
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP trace exporter for %s: %v", opts.Endpoint, err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("creating trace resource: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer to start spans with. It follows the provider set by Init, even when it's called first
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
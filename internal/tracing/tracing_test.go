@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestInitDisabled(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+
+	stop, err := Init(context.Background(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := otel.GetTracerProvider().(noop.TracerProvider); !ok {
+		t.Errorf("Result fail. Received provider %T, Expected tracing to stay disabled without an endpoint", otel.GetTracerProvider())
+	}
+	_, span := Tracer().Start(context.Background(), "collector.pass")
+	if span.SpanContext().IsValid() {
+		t.Errorf("Result fail. Expected a no-op span without an endpoint")
+	}
+	span.End()
+	if err := stop(context.Background()); err != nil {
+		t.Errorf("Result fail. Received %v, Expected no error", err)
+	}
+}
+
+func TestInitExports(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			requests.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	stop, err := Init(context.Background(), Options{Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	_, span := Tracer().Start(context.Background(), "collector.pass")
+	if !span.SpanContext().IsValid() {
+		t.Errorf("Result fail. Expected a recorded span with an endpoint set")
+	}
+	span.End()
+
+	// Spans are batched, and flushed when the exporter is stopped
+	err = stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Result fail. Received %d export requests, Expected %d", n, 1)
+	}
+}